@@ -0,0 +1,157 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/huntwj/gofugue/client/clog"
+	"github.com/huntwj/gofugue/wotmud/combat"
+)
+
+// severities are the severity words of hits as shown in a report.
+var severities = []struct{ word, name string }{
+	{combat.Tickle, "tickle"},
+	{combat.Barely, "barely"},
+	{combat.Normal, "normal"},
+	{combat.Hard, "hard"},
+	{combat.VeryHard, "very hard"},
+	{combat.ExtremelyHard, "extremely hard"},
+	{combat.Fragments, "into fragments"},
+}
+
+// readFights splits the log at path, which may be gzipped, into fights.
+func readFights(path string) ([]*combat.Fight, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	var analyzer combat.Analyzer
+	logReader := clog.NewReader(r)
+	for {
+		entry, err := logReader.Read()
+		if err == io.EOF {
+			return analyzer.Fights(), nil
+		} else if err != nil {
+			return nil, err
+		}
+		if !entry.Sent {
+			analyzer.Observe(entry.Line)
+		}
+	}
+}
+
+func writeTally(w io.Writer, label string, t *combat.Tally) {
+	attacks := t.Attacks()
+	fmt.Fprintf(w, "  %-7s %d attacks, %d hits (%.0f%%)", label, attacks, t.Landed(), 100*t.Ratio(combat.Hit))
+	if t.Landed() > 0 {
+		var hits []string
+		for _, severity := range severities {
+			if n := t.Hits[severity.word]; n > 0 {
+				hits = append(hits, fmt.Sprintf("%d %s", n, severity.name))
+			}
+		}
+		fmt.Fprintf(w, ": %s", strings.Join(hits, ", "))
+	}
+	fmt.Fprintln(w)
+	if attacks > 0 {
+		fmt.Fprintf(w, "          missed %.0f%%, parried %.0f%%, dodged %.0f%%, deflected %.0f%%\n",
+			100*t.Ratio(combat.Miss), 100*t.Ratio(combat.Parry), 100*t.Ratio(combat.Dodge), 100*t.Ratio(combat.Deflect))
+	}
+}
+
+func writeFight(w io.Writer, n int, f *combat.Fight) {
+	fmt.Fprintf(w, "Fight %d: %s, %d rounds", n, strings.Join(f.Opponents, ", "), f.Rounds)
+	if weapon := f.Weapon(); weapon != "" {
+		fmt.Fprintf(w, ", mostly %s", weapon)
+	}
+	if !f.Over {
+		fmt.Fprint(w, " (unfinished)")
+	}
+	fmt.Fprintln(w)
+	writeTally(w, "Dealt:", &f.Dealt)
+	writeTally(w, "Taken:", &f.Taken)
+	if f.StartHealth != "" {
+		fmt.Fprintf(w, "  Health: %s to %s, lowest %s, down %d\n", f.StartHealth, f.EndHealth, f.LowHealth, f.HealthLost())
+	}
+	if len(f.TargetHealth) > 0 {
+		var curve []string
+		name := ""
+		for _, target := range f.TargetHealth {
+			if target.Name != name {
+				name = target.Name
+				curve = append(curve, name+": "+target.Health)
+			} else {
+				curve = append(curve, target.Health)
+			}
+		}
+		fmt.Fprintf(w, "  Target: %s\n", strings.Join(curve, " > "))
+	}
+}
+
+// writeReport prints a summary of every fight followed by totals for each of
+// the player's weapons, so that weapons and stances can be compared.
+func writeReport(w io.Writer, fights []*combat.Fight) {
+	dealt := make(map[string]*combat.Tally)
+	taken := make(map[string]*combat.Tally)
+	rounds := make(map[string]int)
+	for idx, f := range fights {
+		writeFight(w, idx+1, f)
+
+		weapon := f.Weapon()
+		if weapon == "" {
+			weapon = "none"
+		}
+		if dealt[weapon] == nil {
+			dealt[weapon], taken[weapon] = &combat.Tally{}, &combat.Tally{}
+		}
+		dealt[weapon].Add(f.Dealt)
+		taken[weapon].Add(f.Taken)
+		rounds[weapon] += f.Rounds
+	}
+
+	weapons := make([]string, 0, len(dealt))
+	for weapon := range dealt {
+		weapons = append(weapons, weapon)
+	}
+	sort.Strings(weapons)
+	for _, weapon := range weapons {
+		fmt.Fprintf(w, "\nTotal with %s: %d rounds\n", weapon, rounds[weapon])
+		writeTally(w, "Dealt:", dealt[weapon])
+		writeTally(w, "Taken:", taken[weapon])
+	}
+}
+
+// analyze implements "gofugue analyze", printing the fights in each log.
+func analyze(w io.Writer, paths []string) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("usage: %s analyze <file.clog.gz>...", os.Args[0])
+	}
+	for _, path := range paths {
+		fights, err := readFights(path)
+		if err != nil {
+			return err
+		}
+		if len(paths) > 1 {
+			fmt.Fprintf(w, "== %s\n", path)
+		}
+		fmt.Fprintf(w, "%d fights\n", len(fights))
+		writeReport(w, fights)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+'use strict';
+
+// Library for node scripts run by gofugue. Messages are JSON-RPC 2.0 objects,
+// one per line, read from stdin and written to stdout. Because stdout carries
+// the protocol, connect() sends console.log output to stderr.
+
+const EventEmitter = require('events');
+const readline = require('readline');
+
+const VERSION = '2.0';
+
+class Gofugue extends EventEmitter {
+  constructor(input, output) {
+    super();
+    this.output = output;
+    this.nextId = 0;
+    this.pending = new Map();
+    this.methods = new Map();
+    this.callbacks = { trigger: new Map(), alias: new Map(), hook: new Map() };
+
+    this.reader = readline.createInterface({ input: input, terminal: false });
+    this.reader.on('line', (line) => this.receive(line));
+    this.reader.on('close', () => {
+      for (const { reject } of this.pending.values()) {
+        reject(new Error('gofugue connection closed'));
+      }
+      this.pending.clear();
+      this.emit('close');
+    });
+  }
+
+  write(msg) {
+    msg.jsonrpc = VERSION;
+    this.output.write(JSON.stringify(msg) + '\n');
+  }
+
+  receive(line) {
+    if (line.trim() === '') {
+      return;
+    }
+
+    let msg;
+    try {
+      msg = JSON.parse(line);
+    } catch (err) {
+      this.emit('error', err);
+      return;
+    }
+
+    if (msg.method === undefined) {
+      const call = this.pending.get(msg.id);
+      if (call) {
+        this.pending.delete(msg.id);
+        if (msg.error) {
+          call.reject(new Error(msg.error.message));
+        } else {
+          call.resolve(msg.result);
+        }
+      }
+      return;
+    }
+
+    if (msg.method === 'event') {
+      this.emit(msg.params.type, msg.params);
+      this.emit('event', msg.params);
+      return;
+    }
+    const callbacks = this.callbacks[msg.method];
+    if (callbacks !== undefined) {
+      const fn = callbacks.get(msg.params.name);
+      if (fn) {
+        fn(msg.params);
+      }
+      return;
+    }
+
+    this.dispatch(msg);
+  }
+
+  dispatch(msg) {
+    const handler = this.methods.get(msg.method);
+    const reply = (result, error) => {
+      if (msg.id !== undefined) {
+        this.write(error ? { id: msg.id, error: error } : { id: msg.id, result: result });
+      }
+    };
+    if (!handler) {
+      reply(null, { code: -32601, message: msg.method + ': no such method' });
+      return;
+    }
+    Promise.resolve()
+      .then(() => handler(msg.params))
+      .then((result) => reply(result === undefined ? null : result))
+      .catch((err) => reply(null, { code: -32603, message: String(err && err.message || err) }));
+  }
+
+  // call sends a request and returns a Promise for its result.
+  call(method, params) {
+    const id = ++this.nextId;
+    return new Promise((resolve, reject) => {
+      this.pending.set(id, { resolve, reject });
+      this.write({ id: id, method: method, params: params });
+    });
+  }
+
+  // method makes fn available to gofugue as a request handler.
+  method(name, fn) {
+    this.methods.set(name, fn);
+  }
+
+  // subscribe asks for 'line', 'prompt' and/or 'room' events, which are
+  // emitted on this object under their type.
+  subscribe(...events) {
+    return this.call('subscribe', { events: events });
+  }
+
+  unsubscribe(...events) {
+    return this.call('unsubscribe', { events: events });
+  }
+
+  // send sends text to a world, the foreground world when none is given.
+  send(text, world) {
+    return this.call('send', { text: text, world: world });
+  }
+
+  echo(text, world) {
+    return this.call('echo', { text: text, world: world });
+  }
+
+  setVariable(name, value) {
+    return this.call('setVariable', { name: name, value: String(value) });
+  }
+
+  getVariable(name) {
+    return this.call('getVariable', { name: name }).then((result) => result.value);
+  }
+
+  // addTrigger calls fn with { name, world, line, match } for every line
+  // matching the regular expression pattern, in Go syntax.
+  addTrigger(name, pattern, fn) {
+    this.callbacks.trigger.set(name, fn);
+    return this.call('addTrigger', { name: name, pattern: pattern });
+  }
+
+  removeTrigger(name) {
+    this.callbacks.trigger.delete(name);
+    return this.call('removeTrigger', { name: name });
+  }
+
+  // addAlias calls fn with { name, world, args } for input starting with the
+  // word name instead of sending it to the world.
+  addAlias(name, fn) {
+    this.callbacks.alias.set(name, fn);
+    return this.call('addAlias', { name: name });
+  }
+
+  removeAlias(name) {
+    this.callbacks.alias.delete(name);
+    return this.call('removeAlias', { name: name });
+  }
+
+  // addHook calls fn with { name, world, args } when the named hook, such as
+  // CONNECT, fires.
+  addHook(name, fn) {
+    this.callbacks.hook.set(name, fn);
+    return this.call('addHook', { name: name });
+  }
+
+  removeHook(name) {
+    this.callbacks.hook.delete(name);
+    return this.call('removeHook', { name: name });
+  }
+}
+
+let connection = null;
+
+// connect returns the connection to the gofugue process running this script.
+function connect() {
+  if (connection === null) {
+    console.log = console.error;
+    connection = new Gofugue(process.stdin, process.stdout);
+  }
+  return connection;
+}
+
+module.exports = { Gofugue, connect };
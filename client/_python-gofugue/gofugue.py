@@ -0,0 +1,167 @@
+"""Library for Python plugins run by gofugue.
+
+Messages are JSON-RPC 2.0 objects, one per line, read from stdin and written
+to stdout. Because stdout carries the protocol, print() output should go to
+stderr, which gofugue shows in its log.
+
+    import gofugue
+
+    g = gofugue.connect()
+
+    @g.trigger('tell', r"^(\\w+) tells you '(.*)'$")
+    def tell(ev):
+        g.send('tell %s afk' % ev['match'][1], ev['world'])
+
+    g.run()
+"""
+
+import collections
+import json
+import sys
+
+VERSION = '2.0'
+
+
+class Error(Exception):
+    """An error response from gofugue."""
+
+    def __init__(self, error):
+        Exception.__init__(self, error.get('message', 'unknown error'))
+        self.code = error.get('code')
+
+
+class Gofugue(object):
+    def __init__(self, input=sys.stdin, output=sys.stdout):
+        self.input = input
+        self.output = output
+        self.next_id = 0
+        self.backlog = collections.deque()
+        self.handlers = collections.defaultdict(list)
+        self.methods = {}
+
+    def write(self, msg):
+        msg['jsonrpc'] = VERSION
+        self.output.write(json.dumps(msg) + '\n')
+        self.output.flush()
+
+    def read(self):
+        while True:
+            line = self.input.readline()
+            if not line:
+                return None
+            line = line.strip()
+            if line:
+                return json.loads(line)
+
+    def call(self, method, **params):
+        """Send a request and wait for its result. Notifications arriving in
+        the meantime are handled by run() later."""
+        self.next_id += 1
+        request_id = self.next_id
+        self.write({'id': request_id, 'method': method, 'params': params})
+        while True:
+            msg = self.read()
+            if msg is None:
+                raise EOFError('gofugue connection closed')
+            if 'method' not in msg and msg.get('id') == request_id:
+                if 'error' in msg:
+                    raise Error(msg['error'])
+                return msg.get('result')
+            self.backlog.append(msg)
+
+    def notify(self, method, **params):
+        self.write({'method': method, 'params': params})
+
+    def on(self, kind, fn):
+        """Call fn with the params of every notification of kind: an event
+        type such as 'line', or 'trigger', 'alias' or 'hook'."""
+        self.handlers[kind].append(fn)
+
+    def method(self, name, fn):
+        """Make fn available to gofugue as a request handler."""
+        self.methods[name] = fn
+
+    def subscribe(self, *events):
+        return self.call('subscribe', events=list(events))
+
+    def unsubscribe(self, *events):
+        return self.call('unsubscribe', events=list(events))
+
+    def send(self, text, world=None):
+        return self.call('send', text=text, world=world or '')
+
+    def echo(self, text, world=None):
+        return self.call('echo', text=text, world=world or '')
+
+    def set_variable(self, name, value):
+        return self.call('setVariable', name=name, value=str(value))
+
+    def get_variable(self, name):
+        return self.call('getVariable', name=name)['value']
+
+    def _named(self, kind, name, fn):
+        self.on(kind, lambda params: params['name'] == name and fn(params))
+
+    def trigger(self, name, pattern):
+        """Decorator running the function for lines matching pattern."""
+        def register(fn):
+            self._named('trigger', name, fn)
+            self.call('addTrigger', name=name, pattern=pattern)
+            return fn
+        return register
+
+    def alias(self, name):
+        """Decorator running the function for input starting with name."""
+        def register(fn):
+            self._named('alias', name, fn)
+            self.call('addAlias', name=name)
+            return fn
+        return register
+
+    def hook(self, name):
+        """Decorator running the function when the named hook fires."""
+        def register(fn):
+            self._named('hook', name, fn)
+            self.call('addHook', name=name)
+            return fn
+        return register
+
+    def dispatch(self, msg):
+        method = msg.get('method')
+        if method is None:
+            return
+        params = msg.get('params') or {}
+        if method == 'event':
+            method = params.get('type')
+        if method in self.methods:
+            try:
+                reply = {'result': self.methods[method](params)}
+            except Exception as err:
+                reply = {'error': {'code': -32603, 'message': str(err)}}
+        else:
+            for fn in self.handlers.get(method, []):
+                fn(params)
+            reply = {'error': {'code': -32601, 'message': method + ': no such method'}}
+        if 'id' in msg:
+            reply['id'] = msg['id']
+            self.write(reply)
+
+    def run(self):
+        """Handle notifications until gofugue closes the connection."""
+        while True:
+            msg = self.backlog.popleft() if self.backlog else self.read()
+            if msg is None:
+                return
+            self.dispatch(msg)
+
+
+_connection = None
+
+
+def connect():
+    """Return the connection to the gofugue process running this script."""
+    global _connection
+    if _connection is None:
+        _connection = Gofugue(sys.stdin, sys.stdout)
+        sys.stdout = sys.stderr
+    return _connection
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"strings"
+	"sync"
+)
+
+// An AliasFunc runs in place of sending a command to the world when the first
+// word of the command is its alias. Session is the foreground world, which
+// may be nil, and args is the rest of the command.
+type AliasFunc func(s *Session, args string) error
+
+type aliasSet struct {
+	mu      sync.RWMutex
+	aliases map[string]*AliasFunc
+}
+
+// AddAlias - Run fn for input starting with the word name instead of sending
+// it to the world. An existing alias of the same name is replaced. The
+// returned func removes the alias unless it has been replaced since.
+func (c *Client) AddAlias(name string, fn AliasFunc) func() {
+	c.aliases.mu.Lock()
+	defer c.aliases.mu.Unlock()
+
+	if c.aliases.aliases == nil {
+		c.aliases.aliases = make(map[string]*AliasFunc)
+	}
+	a := &fn
+	c.aliases.aliases[name] = a
+
+	return func() {
+		c.aliases.mu.Lock()
+		defer c.aliases.mu.Unlock()
+
+		if c.aliases.aliases[name] == a {
+			delete(c.aliases.aliases, name)
+		}
+	}
+}
+
+// alias finds the alias for the first word of line, returning it with the
+// rest of the line.
+func (c *Client) alias(line string) (AliasFunc, string, bool) {
+	name, args := line, ""
+	if idx := strings.IndexAny(line, " \t"); idx >= 0 {
+		name, args = line[:idx], strings.TrimSpace(line[idx+1:])
+	}
+
+	c.aliases.mu.RLock()
+	defer c.aliases.mu.RUnlock()
+
+	fn, ok := c.aliases.aliases[name]
+	if !ok {
+		return nil, "", false
+	}
+	return *fn, args, true
+}
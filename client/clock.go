@@ -0,0 +1,58 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/clock"
+)
+
+// observeClock feeds a line into the world's clock, firing the SUN hook
+// with the phase of the day the game showed starting, and DARK and LIGHT as
+// the player's room goes dark or is lit.
+func (s *Session) observeClock(line wotmud.Line) {
+	for _, ev := range s.Clock.Observe(line, time.Now()) {
+		switch ev.Kind {
+		case clock.PhaseChanged:
+			s.client.FireHook(HookSun, s, ev.Phase.String())
+		case clock.Darkened:
+			s.client.FireHook(HookDark, s, "")
+		case clock.Lit:
+			s.client.FireHook(HookLight, s, "")
+		}
+	}
+}
+
+// Status - The fields describing the world for a status bar: the game hour
+// and phase of the day once they are known, and whether the room is dark.
+func (s *Session) Status() []string {
+	var fields []string
+	now := time.Now()
+	if hour, ok := s.Clock.Hour(now); ok {
+		fields = append(fields, fmt.Sprintf("%02d:00 %s", hour, s.Clock.Phase(now)))
+	}
+	if s.Clock.Dark() {
+		fields = append(fields, "dark")
+	}
+	return fields
+}
+
+// resolveClock supplies game_hour and game_phase, empty until the sun has
+// been seen to rise or set, and room_dark.
+func resolveClock(s *Session, name string) (string, bool) {
+	now := time.Now()
+	switch name {
+	case "game_hour":
+		if hour, ok := s.Clock.Hour(now); ok {
+			return strconv.Itoa(hour), true
+		}
+		return "", true
+	case "game_phase":
+		return s.Clock.Phase(now).String(), true
+	case "room_dark":
+		return boolValue(s.Clock.Dark()), true
+	}
+	return "", false
+}
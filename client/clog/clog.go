@@ -0,0 +1,107 @@
+package clog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/huntwj/gofugue/wotmud"
+)
+
+// secretMask stands in for a secret command, the same whatever its length so
+// that the log gives nothing away.
+const secretMask = "xxxxxxxx"
+
+// A Writer records a session in the .clog format: server output as it was
+// received, with each command sent to the server recorded as "<Sent: cmd >".
+// Secret commands such as passphrases are recorded as secretMask.
+type Writer struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+
+	// partial is the last line written if it was a prompt without a newline.
+	partial string
+}
+
+// NewWriter - Create a Writer recording to w
+func NewWriter(w io.Writer) *Writer {
+	writer := &Writer{w: w}
+	if closer, ok := w.(io.Closer); ok {
+		writer.closer = closer
+	}
+	return writer
+}
+
+// Create - Start a new log for a world in dir. Logs are named after the date
+// and the world, numbered so that earlier logs from the same day are kept.
+func Create(dir, world string, now time.Time) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	date := now.Format("2006-01-02")
+	for n := 1; n < 100; n++ {
+		name := fmt.Sprintf("%s_%02d_%s.clog", date, n, world)
+		f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return NewWriter(f), nil
+	}
+	return nil, fmt.Errorf("too many logs for %s on %s", world, date)
+}
+
+// Line - Record a line of server output
+func (w *Writer) Line(line wotmud.Line) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	text := line.Raw
+	if w.partial != "" {
+		text = "\r\n" + text
+	}
+	w.partial = ""
+	if line.Partial {
+		w.partial = line.Raw
+	} else {
+		text += "\r\n"
+	}
+
+	_, err := io.WriteString(w.w, text)
+	return err
+}
+
+// Sent - Record a command sent to the server. A command typed at a prompt is
+// recorded on the prompt's line.
+func (w *Writer) Sent(cmd string, secret bool) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if secret {
+		cmd = secretMask
+	}
+	sep := ""
+	if w.partial != "" && !strings.HasSuffix(w.partial, " ") {
+		sep = " "
+	}
+	w.partial = ""
+
+	_, err := fmt.Fprintf(w.w, "%s<Sent: %s >\n", sep, cmd)
+	return err
+}
+
+// Close - Finish the log
+func (w *Writer) Close() error {
+	if w.closer == nil {
+		return nil
+	}
+	return w.closer.Close()
+}
@@ -0,0 +1,112 @@
+package clog_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/huntwj/gofugue/client/clog"
+	"github.com/huntwj/gofugue/wotmud"
+)
+
+func partial(raw string) wotmud.Line {
+	line := wotmud.NewLine(raw)
+	line.Partial = true
+	return line
+}
+
+func TestWriterFormat(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	w := clog.NewWriter(&buf)
+
+	w.Line(partial("By what name do you wish to be known? "))
+	w.Sent("freddie", false)
+	w.Line(partial("Passphrase:"))
+	w.Sent("hunter2", true)
+	w.Line(wotmud.NewLine("Welcome to the Wheel of Time!  Type 'help' for information."))
+	w.Line(partial("* HP:Healthy MV:Full > "))
+	w.Line(wotmud.NewLine("A mirrored lantern has gone out!"))
+	w.Sent("s", false)
+	w.Sent("e", false)
+
+	expected := "By what name do you wish to be known? <Sent: freddie >\n" +
+		"Passphrase: <Sent: xxxxxxxx >\n" +
+		"Welcome to the Wheel of Time!  Type 'help' for information.\r\n" +
+		"* HP:Healthy MV:Full > \r\n" +
+		"A mirrored lantern has gone out!\r\n" +
+		"<Sent: s >\n" +
+		"<Sent: e >\n"
+	if buf.String() != expected {
+		t.Errorf("Unexpected log.\nExpected: %q\nFound:    %q", expected, buf.String())
+	}
+}
+
+func TestCreateNumbersLogs(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	now := time.Date(2017, 10, 22, 12, 0, 0, 0, time.UTC)
+
+	for _, expected := range []string{"2017-10-22_01_Freddie.clog", "2017-10-22_02_Freddie.clog"} {
+		w, err := clog.Create(dir, "Freddie", now)
+		if err != nil {
+			t.Fatalf("Could not create log: %v", err)
+		}
+		w.Sent("look", false)
+		if err := w.Close(); err != nil {
+			t.Errorf("Could not close log: %v", err)
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, expected))
+		if err != nil {
+			t.Fatalf("Expected log %s: %v", expected, err)
+		}
+		if string(data) != "<Sent: look >\n" {
+			t.Errorf("Unexpected log contents %q", data)
+		}
+	}
+}
+
+func TestReader(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	w := clog.NewWriter(&buf)
+	w.Line(partial("By what name do you wish to be known? "))
+	w.Sent("freddie", false)
+	w.Line(wotmud.NewLine("\x1b[32mYou slash the ancient tree's trunk hard.\x1b[0m"))
+	w.Line(partial("* HP:Healthy MV:Full > "))
+	w.Line(wotmud.NewLine("A mirrored lantern has gone out!"))
+	w.Sent("s", false)
+	buf.WriteString("^[32mYou slash the ancient tree's roots.\r\n")
+
+	r := clog.NewReader(&buf)
+	expected := []clog.Entry{
+		{Line: partial("By what name do you wish to be known? ")},
+		{Sent: true, Command: "freddie"},
+		{Line: wotmud.NewLine("\x1b[32mYou slash the ancient tree's trunk hard.\x1b[0m")},
+		{Line: wotmud.NewLine("* HP:Healthy MV:Full > ")},
+		{Line: wotmud.NewLine("A mirrored lantern has gone out!")},
+		{Sent: true, Command: "s"},
+		{Line: wotmud.NewLine("\x1b[32mYou slash the ancient tree's roots.")},
+	}
+	for _, want := range expected {
+		entry, err := r.Read()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if entry.Sent != want.Sent || entry.Command != want.Command ||
+			entry.Line.Raw != want.Line.Raw || entry.Line.Partial != want.Line.Partial ||
+			(entry.Line.PromptInfo == nil) != (want.Line.PromptInfo == nil) {
+			t.Errorf("Expected %+v but found %+v", want, entry)
+		}
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("Expected the end of the log but found %v", err)
+	}
+}
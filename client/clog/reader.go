@@ -0,0 +1,78 @@
+package clog
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/huntwj/gofugue/wotmud"
+)
+
+// An Entry is one record of a log: a line of server output or, when Sent is
+// set, the Command sent to the server.
+type Entry struct {
+	Line    wotmud.Line
+	Sent    bool
+	Command string
+}
+
+// A Reader reads a session back from the .clog format. Logs written by
+// TinyFugue show the escape character as ^[, sometimes without the [ that
+// follows it, and the Reader turns those back into ANSI codes.
+type Reader struct {
+	scanner *bufio.Scanner
+	queued  []Entry
+}
+
+// caretEscape matches an escape character as written by TinyFugue.
+var caretEscape = regexp.MustCompile(`\^\[\[?`)
+
+const sentPrefix, sentSuffix = "<Sent: ", " >"
+
+// NewReader - Create a Reader of the log in r
+func NewReader(r io.Reader) *Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	return &Reader{scanner: scanner}
+}
+
+// Read - The next entry of the log, or io.EOF at its end. A prompt that a
+// command was typed at comes back as a partial line before the command.
+func (r *Reader) Read() (Entry, error) {
+	if len(r.queued) > 0 {
+		entry := r.queued[0]
+		r.queued = r.queued[1:]
+		return entry, nil
+	}
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return Entry{}, err
+		}
+		return Entry{}, io.EOF
+	}
+
+	text := r.scanner.Text()
+	if strings.HasSuffix(text, "\r") {
+		return Entry{Line: wotmud.NewLine(decode(text[:len(text)-1]))}, nil
+	}
+
+	if idx := strings.LastIndex(text, sentPrefix); idx >= 0 && strings.HasSuffix(text, sentSuffix) {
+		sent := Entry{Sent: true, Command: text[idx+len(sentPrefix) : len(text)-len(sentSuffix)]}
+		if idx == 0 {
+			return sent, nil
+		}
+		line := wotmud.NewLine(decode(text[:idx]))
+		line.Partial = true
+		r.queued = append(r.queued, sent)
+		return Entry{Line: line}, nil
+	}
+	return Entry{Line: wotmud.NewLine(decode(text))}, nil
+}
+
+func decode(text string) string {
+	if !strings.Contains(text, "^[") {
+		return text
+	}
+	return caretEscape.ReplaceAllString(text, "\x1b[")
+}
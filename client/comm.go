@@ -0,0 +1,80 @@
+package client
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/comm"
+)
+
+// shopkeepers are the characters known to tell the player things when
+// trading, who are not worth replying to.
+var shopkeepers = map[string]bool{"Cyril": true, "Gudha": true}
+
+// observeComm keeps a line that is a message on a communication channel in
+// the client's history, noting who to reply to when it is a tell to the
+// player. It returns the message, or nil for other lines.
+func (s *Session) observeComm(line wotmud.Line) *comm.Message {
+	text := wotmud.StripANSI(line.Text())
+	msg := comm.Parse(text)
+	if msg == nil {
+		return nil
+	}
+
+	s.client.Comm.Add(comm.Entry{World: s.World.Name, Line: text, Message: *msg})
+	// Only players, whose names are a single word, can be told anything.
+	if msg.Channel == comm.Tell && msg.ToYou() && !strings.Contains(msg.Speaker, " ") && !shopkeepers[msg.Speaker] {
+		s.mu.Lock()
+		s.replyTo = msg.Speaker
+		s.mu.Unlock()
+	}
+	return msg
+}
+
+// ReplyTo - The player who last sent a tell to the world, if any
+func (s *Session) ReplyTo() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.replyTo
+}
+
+// cmdReply implements "/reply text", telling the player who last sent a tell
+// to the foreground world.
+func (c *Client) cmdReply(args string) error {
+	if args == "" {
+		return errors.New("usage: /reply text")
+	}
+	s := c.Foreground()
+	if s == nil {
+		return ErrNotConnected
+	}
+	name := s.ReplyTo()
+	if name == "" {
+		return errors.New("no one has sent you a tell")
+	}
+	return s.Send("tell " + name + " " + args)
+}
+
+// cmdComm implements "/comm [regexp]", showing the messages kept from every
+// world's communication channels, or those matching regexp.
+func (c *Client) cmdComm(args string) error {
+	var re *regexp.Regexp
+	if args != "" {
+		var err error
+		if re, err = regexp.Compile(args); err != nil {
+			return err
+		}
+	}
+
+	entries := c.Comm.Search(re)
+	if len(entries) == 0 {
+		c.message(nil, "No messages.")
+	}
+	for _, entry := range entries {
+		c.Echo(nil, "["+entry.World+"] "+entry.Line)
+	}
+	return nil
+}
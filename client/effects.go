@@ -0,0 +1,137 @@
+package client
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/huntwj/gofugue/tflang/interp"
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/effects"
+)
+
+// effectMessages are the lines that start and end an effect, as given to
+// /effect.
+type effectMessages struct {
+	name       string
+	start, end *regexp.Regexp
+}
+
+type effectList struct {
+	mu       sync.Mutex
+	messages []effectMessages
+}
+
+// observeEffects feeds a line into the world's effects, firing the EXPIRE
+// hook for those it ended.
+func (s *Session) observeEffects(line wotmud.Line) {
+	changes := s.Effects.Observe(line, time.Now())
+	if len(changes) > 0 {
+		s.expireEffects(changes)
+	}
+}
+
+// expireEffects fires the EXPIRE hook for the effects that ended and sets a
+// timer for the next one estimated to run out.
+func (s *Session) expireEffects(changes []effects.Change) {
+	for _, change := range changes {
+		if change.Ended {
+			s.client.FireHook(HookExpire, s, change.Name)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.expiry != nil {
+		s.expiry.Stop()
+		s.expiry = nil
+	}
+	if next, ok := s.Effects.Next(); ok && !s.closing {
+		s.expiry = time.AfterFunc(time.Until(next), func() {
+			s.expireEffects(s.Effects.Expire(time.Now()))
+		})
+	}
+}
+
+// cmdEffects implements "/effects", showing the effects the foreground
+// world's player is subjected to and the time estimated to be left on each.
+func (c *Client) cmdEffects(args string) error {
+	s := c.Foreground()
+	if s == nil {
+		return ErrNotConnected
+	}
+
+	active := s.Effects.Active()
+	if len(active) == 0 {
+		c.message(s, "No effects.")
+	}
+	now := time.Now()
+	for _, e := range active {
+		if left, ok := e.Remaining(now); ok {
+			c.message(s, "%s: about %v left", e.Name, left.Round(time.Second))
+		} else {
+			c.message(s, "%s", e.Name)
+		}
+	}
+	return nil
+}
+
+// cmdEffect implements "/effect -s'start' -e'end' name", recognizing lines
+// matching the regexps start and end, either of which may be left out, as
+// the named effect starting and ending in every world.
+func (c *Client) cmdEffect(args string) error {
+	opts, name, err := interp.ParseOptions(args)
+	if err != nil {
+		return err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || (opts['s'] == "" && opts['e'] == "") {
+		return errors.New("usage: /effect -s'start' -e'end' name")
+	}
+
+	m := effectMessages{name: name}
+	if m.start, err = compileOptional(opts['s']); err != nil {
+		return err
+	}
+	if m.end, err = compileOptional(opts['e']); err != nil {
+		return err
+	}
+
+	c.effects.mu.Lock()
+	c.effects.messages = append(c.effects.messages, m)
+	c.effects.mu.Unlock()
+	for _, s := range c.Sessions() {
+		s.Effects.Track(m.name, m.start, m.end)
+	}
+	return nil
+}
+
+// compileOptional compiles expr, giving nil for an empty one.
+func compileOptional(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile(expr)
+}
+
+// installEffects gives a new session the effect messages added with /effect.
+func (c *Client) installEffects(s *Session) {
+	c.effects.mu.Lock()
+	defer c.effects.mu.Unlock()
+
+	for _, m := range c.effects.messages {
+		s.Effects.Track(m.name, m.start, m.end)
+	}
+}
+
+// effectNames lists the active effects of the world s, separated by commas.
+func effectNames(s *Session) string {
+	var names []string
+	for _, e := range s.Effects.Active() {
+		names = append(names, e.Name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/combat"
+	"github.com/huntwj/gofugue/wotmud/comm"
+	"github.com/huntwj/gofugue/wotmud/mapper"
+)
+
+// EventType - The kind of thing an Event tells the user interface about
+type EventType int
+
+const (
+	// LineEvent - A complete line of output was received from a world
+	LineEvent EventType = iota
+	// PromptEvent - A world sent a new prompt
+	PromptEvent
+	// MessageEvent - The client has something to tell the user
+	MessageEvent
+	// ForegroundEvent - A different world was brought to the foreground
+	ForegroundEvent
+	// RoomEvent - The mapper saw the player enter a room
+	RoomEvent
+	// LogEvent - Diagnostic output, such as a plugin's stderr, for the log
+	// window
+	LogEvent
+	// CombatEvent - A world sent a combat message, such as a hit or a parry
+	CombatEvent
+	// CommEvent - A world sent a message on a communication channel, such as
+	// a chat or a tell
+	CommEvent
+)
+
+// An Event is something the user interface should show. Session is the world
+// the event belongs to and is nil for client messages that are not tied to a
+// world. For a LineEvent, Line is the line as received and Text is the line as
+// it should be shown, after substitutions and highlights; Gagged is set when
+// it should not be shown at all. A CombatEvent follows the LineEvent of the
+// combat message, which Attack describes, and a CommEvent follows that of a
+// message, which Comm describes; its Text is the shown line without the
+// prompt.
+type Event struct {
+	Type    EventType
+	Session *Session
+	Line    wotmud.Line
+	Text    string
+	Gagged  bool
+	Room    *mapper.Room
+	Attack  *combat.Event
+	Comm    *comm.Message
+}
+
+// A Listener is called with every event before it is handed to the user
+// interface. Listeners run on the goroutine of the world the event belongs
+// to and must not block.
+type Listener func(Event)
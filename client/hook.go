@@ -0,0 +1,186 @@
+package client
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/huntwj/gofugue/client/trigger"
+	"github.com/huntwj/gofugue/wotmud"
+)
+
+// Hook names. CONNECT, DISCONNECT and PROMPT match the TinyFugue hooks of the
+// same name; the others have no TinyFugue counterpart.
+const (
+	// HookConnect - Fired when a world has been connected, including after
+	// an automatic reconnect
+	HookConnect = "CONNECT"
+	// HookDisconnect - Fired when the connection to a world is closed or lost
+	HookDisconnect = "DISCONNECT"
+	// HookPrompt - Fired with the prompt when a world sends a new prompt
+	HookPrompt = "PROMPT"
+	// HookRoom - Fired with the room name when the mapper sees the player
+	// enter a room
+	HookRoom = "ROOM"
+	// HookCombat - Fired with the text of a combat message, such as a hit or
+	// a parry
+	HookCombat = "COMBAT"
+	// HookGroup - Fired with the names of the members of the player's group,
+	// separated by commas, when it changes
+	HookGroup = "GROUP"
+	// HookExpire - Fired with the name of an effect, such as NO QUIT, when it
+	// ends or is estimated to have run out
+	HookExpire = "EXPIRE"
+	// HookDismount - Fired with the name of the mount, if known, when the
+	// player stops riding, whether by choice or not
+	HookDismount = "DISMOUNT"
+	// HookSun - Fired with the phase of the day, such as "night", when the
+	// game shows it starting
+	HookSun = "SUN"
+	// HookDark - Fired when the player's room goes dark
+	HookDark = "DARK"
+	// HookLight - Fired when the player's room is lit again
+	HookLight = "LIGHT"
+	// HookDanger - Fired with the player's health when it falls to the level
+	// in EscapeHealthVar during a fight
+	HookDanger = "DANGER"
+	// HookFlee - Fired with the name of the room the player fled to
+	HookFlee = "FLEE"
+)
+
+// A HookFunc is run when the hook it was added for fires. Session is the
+// world the hook fired for and args describes the event.
+type HookFunc func(s *Session, args string)
+
+type hookSet struct {
+	mu    sync.RWMutex
+	hooks map[string][]*HookFunc
+}
+
+// AddHook - Run fn whenever the named hook fires. The returned func removes
+// it again.
+func (c *Client) AddHook(name string, fn HookFunc) func() {
+	c.hooks.mu.Lock()
+	defer c.hooks.mu.Unlock()
+
+	if c.hooks.hooks == nil {
+		c.hooks.hooks = make(map[string][]*HookFunc)
+	}
+	name = strings.ToUpper(name)
+	h := &fn
+	c.hooks.hooks[name] = append(c.hooks.hooks[name], h)
+
+	return func() {
+		c.hooks.mu.Lock()
+		defer c.hooks.mu.Unlock()
+
+		hooks := c.hooks.hooks[name]
+		for idx, existing := range hooks {
+			if existing == h {
+				c.hooks.hooks[name] = append(hooks[:idx:idx], hooks[idx+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// FireHook - Run every function added for the named hook
+func (c *Client) FireHook(name string, s *Session, args string) {
+	c.hooks.mu.RLock()
+	hooks := c.hooks.hooks[strings.ToUpper(name)]
+	c.hooks.mu.RUnlock()
+
+	for _, fn := range hooks {
+		(*fn)(s, args)
+	}
+}
+
+// A TriggerFunc is run for a line of output from the world s that matched a
+// trigger added with AddTrigger.
+type TriggerFunc func(s *Session, line wotmud.Line, match []string)
+
+type clientTrigger struct {
+	name    string
+	pattern *regexp.Regexp
+	fn      TriggerFunc
+	display *trigger.Display
+	world   string
+}
+
+type triggerList struct {
+	mu       sync.Mutex
+	triggers []*clientTrigger
+}
+
+func (t *clientTrigger) install(s *Session) {
+	if t.world != "" && !strings.EqualFold(t.world, s.World.Name) {
+		return
+	}
+	tr := &trigger.Trigger{Name: t.name, Pattern: t.pattern, Display: t.display}
+	if t.fn != nil {
+		tr.Action = func(line wotmud.Line, match []string) {
+			t.fn(s, line, match)
+		}
+	}
+	s.Triggers.Add(tr)
+}
+
+// AddTrigger - Add a trigger called name to every world, including worlds
+// connected later. A trigger with the same name is replaced. The returned
+// func removes the trigger again.
+func (c *Client) AddTrigger(name string, pattern *regexp.Regexp, fn TriggerFunc) func() {
+	return c.addTrigger(&clientTrigger{name: name, pattern: pattern, fn: fn})
+}
+
+// AddDisplay - Like AddTrigger, but change how matching lines are shown
+// instead of running a func. The pattern is matched against the line with its
+// ANSI codes removed. A world, when not empty, limits it to that world.
+func (c *Client) AddDisplay(name, world string, pattern *regexp.Regexp, display trigger.Display) func() {
+	return c.addTrigger(&clientTrigger{name: name, pattern: pattern, display: &display, world: world})
+}
+
+func (c *Client) addTrigger(t *clientTrigger) func() {
+	name := t.name
+	c.triggers.mu.Lock()
+	c.triggers.triggers = append(c.triggers.triggers, t)
+	c.triggers.mu.Unlock()
+
+	for _, s := range c.Sessions() {
+		t.install(s)
+	}
+
+	return func() {
+		c.triggers.mu.Lock()
+		var replacement *clientTrigger
+		for idx, existing := range c.triggers.triggers {
+			if existing == t {
+				c.triggers.triggers = append(c.triggers.triggers[:idx:idx], c.triggers.triggers[idx+1:]...)
+				break
+			}
+		}
+		for _, existing := range c.triggers.triggers {
+			if existing.name == name {
+				replacement = existing
+			}
+		}
+		c.triggers.mu.Unlock()
+
+		for _, s := range c.Sessions() {
+			if replacement != nil {
+				replacement.install(s)
+			} else {
+				s.Triggers.Remove(name)
+			}
+		}
+	}
+}
+
+// installTriggers gives a new session the triggers added with AddTrigger.
+func (c *Client) installTriggers(s *Session) {
+	c.triggers.mu.Lock()
+	defer c.triggers.mu.Unlock()
+
+	for _, t := range c.triggers.triggers {
+		t.install(s)
+	}
+}
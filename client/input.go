@@ -0,0 +1,112 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SeparatorVar is the variable holding the command separator used on the
+// input line. It defaults to DefaultSeparator; setting it to an empty string
+// turns command stacking off.
+const SeparatorVar = "command_separator"
+
+// DefaultSeparator separates commands typed on one line, as in "n;n;e;s".
+const DefaultSeparator = ";"
+
+// MaxRepeat bounds the count of a "#N command" repetition.
+const MaxRepeat = 100
+
+// speedwalkDirs are the directions a speedwalk such as "3n2e" may use.
+const speedwalkDirs = "neswud"
+
+// ExpandInput - Split a line typed by the user into the commands to run, in
+// order. The line is split at separator unless it is a slash command, a part
+// starting with "#N " is repeated N times and a speedwalk such as "3n2e"
+// becomes one command per step.
+func ExpandInput(line, separator string) ([]string, error) {
+	parts := []string{line}
+	if separator != "" && !strings.HasPrefix(strings.TrimSpace(line), "/") {
+		parts = strings.Split(line, separator)
+	}
+
+	var cmds []string
+	for _, part := range parts {
+		count, cmd, err := repeatCount(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		expanded := speedwalk(cmd)
+		if expanded == nil {
+			expanded = []string{cmd}
+		}
+		for ; count > 0; count-- {
+			cmds = append(cmds, expanded...)
+		}
+	}
+	return cmds, nil
+}
+
+// repeatCount splits "#N command" into N and the command. Other commands
+// are run once.
+func repeatCount(part string) (int, string, error) {
+	if !strings.HasPrefix(part, "#") {
+		return 1, part, nil
+	}
+	word, cmd := part[1:], ""
+	if idx := strings.IndexAny(word, " \t"); idx >= 0 {
+		word, cmd = word[:idx], strings.TrimSpace(word[idx+1:])
+	}
+	count, err := strconv.Atoi(word)
+	if err != nil || count < 0 {
+		return 1, part, nil
+	}
+	if count > MaxRepeat {
+		return 0, "", fmt.Errorf("#%d: cannot repeat more than %d times", count, MaxRepeat)
+	}
+	return count, cmd, nil
+}
+
+// speedwalk expands a word of counts and directions holding at least one
+// count, such as "3n2e" or "n2u", into its steps. It returns nil for
+// anything else, which leaves commands such as "use" alone.
+func speedwalk(cmd string) []string {
+	if strings.IndexAny(cmd, "0123456789") < 0 {
+		return nil
+	}
+
+	var steps []string
+	count, counted := 0, false
+	for idx := 0; idx < len(cmd); idx++ {
+		ch := cmd[idx]
+		switch {
+		case ch >= '0' && ch <= '9':
+			count, counted = count*10+int(ch-'0'), true
+			if count > MaxRepeat {
+				return nil
+			}
+		case strings.IndexByte(speedwalkDirs, ch) >= 0:
+			if !counted {
+				count = 1
+			}
+			for ; count > 0; count-- {
+				steps = append(steps, string(ch))
+			}
+			counted = false
+		default:
+			return nil
+		}
+	}
+	if counted {
+		return nil
+	}
+	return steps
+}
+
+// separator finds the command separator configured for the input line.
+func (c *Client) separator() string {
+	if sep, ok := c.Interp.Var(SeparatorVar); ok {
+		return sep
+	}
+	return DefaultSeparator
+}
@@ -0,0 +1,81 @@
+package client_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/huntwj/gofugue/client"
+)
+
+func TestExpandInput(t *testing.T) {
+	tests := []struct {
+		line, separator string
+		expected        []string
+	}{
+		{"n;n;e;s", ";", []string{"n", "n", "e", "s"}},
+		{"#3 kill ancient", ";", []string{"kill ancient", "kill ancient", "kill ancient"}},
+		{"3n2e", ";", []string{"n", "n", "n", "e", "e"}},
+		{"n2u;#2 2w", ";", []string{"n", "u", "u", "w", "w", "w", "w"}},
+		{"use;news;3x", ";", []string{"use", "news", "3x"}},
+		{"say hi; there", "", []string{"say hi; there"}},
+		{"say a|b", "|", []string{"say a", "b"}},
+		{"/def k = kill %1;bs %1", ";", []string{"/def k = kill %1;bs %1"}},
+		{"#nope", ";", []string{"#nope"}},
+		{"#0 look", ";", nil},
+	}
+	for _, test := range tests {
+		cmds, err := client.ExpandInput(test.line, test.separator)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", test.line, err)
+		} else if strings.Join(cmds, "|") != strings.Join(test.expected, "|") || len(cmds) != len(test.expected) {
+			t.Errorf("Expected %q to expand to %q but found %q", test.line, test.expected, cmds)
+		}
+	}
+
+	if _, err := client.ExpandInput("#1000 kill ancient", ";"); err == nil {
+		t.Error("Expected huge repeat counts to be refused")
+	}
+}
+
+func TestInputStacking(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	logDir := t.TempDir()
+	c := client.New()
+	defer c.Quit()
+	c.LogDir = logDir
+	go func() {
+		for range c.Events() {
+		}
+	}()
+
+	c.Input(server.addWorldCommand("Freddie"))
+	if _, err := c.Connect("Freddie"); err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	server.accept(t)
+
+	c.Input("n;#2 kill ancient;2s")
+	for _, expected := range []string{"n", "kill ancient", "kill ancient", "s", "s"} {
+		server.expectReceived(t, expected)
+	}
+	c.Input("/set " + client.SeparatorVar + "=|")
+	c.Input("say a;b|e")
+	server.expectReceived(t, "say a;b")
+	server.expectReceived(t, "e")
+
+	c.Quit()
+	logs, _ := filepath.Glob(filepath.Join(logDir, "*.clog"))
+	if len(logs) != 1 {
+		t.Fatalf("Expected one log but found %v", logs)
+	}
+	data, _ := ioutil.ReadFile(logs[0])
+	if n := strings.Count(string(data), "<Sent: s >"); n != 2 {
+		t.Errorf("Expected each step to be logged on its own but found %d", n)
+	}
+}
@@ -0,0 +1,120 @@
+package keymap
+
+import "unicode/utf8"
+
+// An Action is the result of decoding keys: either the Command of a binding
+// or Text that was typed.
+type Action struct {
+	Command string
+	Text    string
+}
+
+// Decoder - Turns the keys read from a terminal into actions. Keys that may
+// begin a longer binding are held until the rest of the binding arrives or
+// Flush is called, typically after the keymap's Timeout. Escape sequences
+// that are not bound are dropped rather than typed.
+type Decoder struct {
+	Keymap *Keymap
+
+	pending []byte
+}
+
+// Feed - Decode data, returning the actions it completes
+func (d *Decoder) Feed(data []byte) []Action {
+	var actions []Action
+	for _, b := range data {
+		d.pending = append(d.pending, b)
+		actions = d.resolve(actions, false)
+	}
+	return actions
+}
+
+// Flush - Decode the keys held back waiting for a longer binding
+func (d *Decoder) Flush() []Action {
+	return d.resolve(nil, true)
+}
+
+// Pending - Whether keys are held back waiting for a longer binding
+func (d *Decoder) Pending() bool {
+	return len(d.pending) > 0
+}
+
+func (d *Decoder) resolve(actions []Action, final bool) []Action {
+	for len(d.pending) > 0 {
+		keys := string(d.pending)
+		cmd, bound, prefix := d.Keymap.match(keys)
+		switch {
+		case prefix && !final:
+			return actions
+		case bound:
+			actions = append(actions, Action{Command: cmd})
+			d.pending = d.pending[:0]
+			continue
+		}
+
+		if d.pending[0] == 0x1b && len(d.pending) > 1 && (d.pending[1] == '[' || d.pending[1] == 'O' || d.pending[1] == 0x1b) {
+			// An unbound escape sequence.
+			n := escapeLength(d.pending)
+			if n == 0 && !final {
+				return actions
+			}
+			if n == 0 {
+				n = len(d.pending)
+			}
+			d.pending = d.pending[n:]
+			continue
+		}
+
+		// Run the longest binding the keys start with, if any.
+		n := len(d.pending) - 1
+		for ; n > 0; n-- {
+			if cmd, ok := d.Keymap.Lookup(keys[:n]); ok {
+				actions = append(actions, Action{Command: cmd})
+				break
+			}
+		}
+		if n == 0 {
+			if !final && !utf8.FullRune(d.pending) {
+				return actions
+			}
+			_, n = utf8.DecodeRune(d.pending)
+			if last := len(actions) - 1; last >= 0 && actions[last].Command == "" {
+				actions[last].Text += keys[:n]
+			} else {
+				actions = append(actions, Action{Text: keys[:n]})
+			}
+		}
+		d.pending = d.pending[n:]
+	}
+	return actions
+}
+
+// escapeLength finds the length of the escape sequence at the start of seq:
+// a CSI sequence such as ESC [ 1 ; 3 D, an SS3 sequence such as ESC O x, or
+// either of those preceded by another ESC for Alt. It returns 0 while the
+// sequence is incomplete.
+func escapeLength(seq []byte) int {
+	if len(seq) < 2 {
+		return 0
+	}
+	switch seq[1] {
+	case 0x1b:
+		if n := escapeLength(seq[1:]); n > 0 {
+			return n + 1
+		}
+		return 0
+	case 'O':
+		if len(seq) < 3 {
+			return 0
+		}
+		return 3
+	case '[':
+		for idx := 2; idx < len(seq); idx++ {
+			if seq[idx] >= 0x40 && seq[idx] <= 0x7e {
+				return idx + 1
+			}
+		}
+		return 0
+	}
+	return 2
+}
@@ -0,0 +1,242 @@
+// Package keymap binds sequences of keys, as sent by a terminal, to commands
+// in the style of TinyFugue's /bind.
+package keymap
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout is how long a Decoder waits for the rest of a sequence that
+// is the start of a longer binding.
+const DefaultTimeout = 300 * time.Millisecond
+
+// DefaultBindings are the bindings of a new Keymap: Emacs-style line editing,
+// PgUp and PgDn to page through the scrollback, Alt-c to switch to the comm
+// tab and back, Alt-Left and Alt-Right to switch worlds and the numeric
+// keypad, in application mode, for movement.
+var DefaultBindings = map[string]string{
+	"^J":      "/dokey NEWLINE",
+	"^M":      "/dokey NEWLINE",
+	"^H":      "/dokey BSPC",
+	"^?":      "/dokey BSPC",
+	"^A":      "/dokey HOME",
+	"^E":      "/dokey END",
+	"^B":      "/dokey LEFT",
+	"^F":      "/dokey RIGHT",
+	"^D":      "/dokey DCH",
+	"^K":      "/dokey DEOL",
+	"^U":      "/dokey DLINE",
+	"^W":      "/dokey BWORD",
+	"^P":      "/dokey RECALLB",
+	"^N":      "/dokey RECALLF",
+	"^L":      "/dokey REDRAW",
+	"^[b":     "/dokey WLEFT",
+	"^[f":     "/dokey WRIGHT",
+	"^[d":     "/dokey DWORD",
+	"^[[A":    "/dokey RECALLB",
+	"^[[B":    "/dokey RECALLF",
+	"^[[C":    "/dokey RIGHT",
+	"^[[D":    "/dokey LEFT",
+	"^[[H":    "/dokey HOME",
+	"^[[F":    "/dokey END",
+	"^[[3~":   "/dokey DCH",
+	"^[[5~":   "/dokey PGUP",
+	"^[[6~":   "/dokey PGDN",
+	"^[j":     "/dokey FLUSH",
+	"^[c":     "/tab",
+	"^[OA":    "/dokey RECALLB",
+	"^[OB":    "/dokey RECALLF",
+	"^[OC":    "/dokey RIGHT",
+	"^[OD":    "/dokey LEFT",
+	"^[[1;3D": "/fg -<",
+	"^[^[[D":  "/fg -<",
+	"^[[1;3C": "/fg ->",
+	"^[^[[C":  "/fg ->",
+	"^[Ox":    "/send n",
+	"^[Or":    "/send s",
+	"^[Ov":    "/send e",
+	"^[Ot":    "/send w",
+	"^[Oy":    "/send u",
+	"^[Os":    "/send d",
+	"^[Ou":    "/send look",
+	"^[OM":    "/dokey NEWLINE",
+}
+
+// Keymap - A set of key bindings, safe for concurrent use
+type Keymap struct {
+	// Timeout is how long to wait for the next key of a sequence.
+	Timeout time.Duration
+
+	mu       sync.RWMutex
+	bindings map[string]string
+}
+
+// Binding - A key sequence and the command it runs
+type Binding struct {
+	Keys    string
+	Command string
+}
+
+// String - The /bind command that makes the binding
+func (b Binding) String() string {
+	return fmt.Sprintf("/bind %s = %s", KeyString(b.Keys), b.Command)
+}
+
+// New - Create a keymap holding the DefaultBindings
+func New() *Keymap {
+	k := &Keymap{Timeout: DefaultTimeout, bindings: make(map[string]string)}
+	for spec, cmd := range DefaultBindings {
+		keys, err := ParseKeys(spec)
+		if err != nil {
+			panic(err)
+		}
+		k.bindings[keys] = cmd
+	}
+	return k
+}
+
+// Bind - Run cmd when the keys are typed, replacing any existing binding
+func (k *Keymap) Bind(keys, cmd string) error {
+	if keys == "" {
+		return errors.New("no keys to bind")
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.bindings[keys] = cmd
+	return nil
+}
+
+// Unbind - Remove the binding of keys, reporting whether there was one
+func (k *Keymap) Unbind(keys string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	_, ok := k.bindings[keys]
+	delete(k.bindings, keys)
+	return ok
+}
+
+// Lookup - Find the command bound to keys
+func (k *Keymap) Lookup(keys string) (string, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	cmd, ok := k.bindings[keys]
+	return cmd, ok
+}
+
+// Bindings - Every binding, ordered by key sequence
+func (k *Keymap) Bindings() []Binding {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	bindings := make([]Binding, 0, len(k.bindings))
+	for keys, cmd := range k.bindings {
+		bindings = append(bindings, Binding{keys, cmd})
+	}
+	sort.Slice(bindings, func(i, j int) bool {
+		return bindings[i].Keys < bindings[j].Keys
+	})
+	return bindings
+}
+
+// match reports the command bound to keys and whether keys is the start of
+// a longer binding.
+func (k *Keymap) match(keys string) (cmd string, bound, prefix bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	cmd, bound = k.bindings[keys]
+	for other := range k.bindings {
+		if len(other) > len(keys) && strings.HasPrefix(other, keys) {
+			prefix = true
+			break
+		}
+	}
+	return cmd, bound, prefix
+}
+
+// ParseKeys - Convert TinyFugue key notation into the characters a terminal
+// sends: ^X is Control-X, ^[ is Escape and ^? is Delete, and \ooo, \xhh, \e
+// and \\ are escapes.
+func ParseKeys(spec string) (string, error) {
+	var b strings.Builder
+	for idx := 0; idx < len(spec); idx++ {
+		ch := spec[idx]
+		switch {
+		case ch == '^' && idx+1 < len(spec):
+			idx++
+			next := spec[idx]
+			if next == '?' {
+				b.WriteByte(0x7f)
+			} else {
+				b.WriteByte(strings.ToUpper(string(next))[0] ^ 0x40)
+			}
+
+		case ch == '\\' && idx+1 < len(spec):
+			idx++
+			switch next := spec[idx]; {
+			case next == 'e':
+				b.WriteByte(0x1b)
+			case next == 'x':
+				end := idx + 1
+				for end < len(spec) && end < idx+3 && strings.IndexByte("0123456789abcdefABCDEF", spec[end]) >= 0 {
+					end++
+				}
+				n, err := strconv.ParseUint(spec[idx+1:end], 16, 8)
+				if err != nil {
+					return "", fmt.Errorf("%s: bad \\x escape", spec)
+				}
+				b.WriteByte(byte(n))
+				idx = end - 1
+			case next >= '0' && next <= '7':
+				end := idx
+				for end < len(spec) && end < idx+3 && spec[end] >= '0' && spec[end] <= '7' {
+					end++
+				}
+				n, err := strconv.ParseUint(spec[idx:end], 8, 8)
+				if err != nil {
+					return "", fmt.Errorf("%s: bad octal escape", spec)
+				}
+				b.WriteByte(byte(n))
+				idx = end - 1
+			default:
+				b.WriteByte(next)
+			}
+
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String(), nil
+}
+
+// KeyString - Write keys in the notation read by ParseKeys
+func KeyString(keys string) string {
+	var b strings.Builder
+	for idx := 0; idx < len(keys); idx++ {
+		switch ch := keys[idx]; {
+		case ch == 0x7f:
+			b.WriteString("^?")
+		case ch < ' ':
+			b.WriteByte('^')
+			b.WriteByte(ch ^ 0x40)
+		case ch == '^' || ch == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(ch)
+		case ch >= 0x80:
+			fmt.Fprintf(&b, "\\x%02x", ch)
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,71 @@
+package keymap_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/huntwj/gofugue/client/keymap"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		spec, keys string
+	}{
+		{"^[Ow", "\x1bOw"},
+		{"^w", "\x17"},
+		{"^?", "\x7f"},
+		{`\e[A`, "\x1b[A"},
+		{`\033x\x41`, "\x1bxA"},
+		{`a\^b`, "a^b"},
+	}
+	for _, test := range tests {
+		keys, err := keymap.ParseKeys(test.spec)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", test.spec, err)
+		} else if keys != test.keys {
+			t.Errorf("Expected %q to parse as %q but found %q", test.spec, test.keys, keys)
+		}
+	}
+
+	if s := keymap.KeyString("\x1bOw^\x7f"); s != `^[Ow\^^?` {
+		t.Errorf("Unexpected key string %q", s)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	k := keymap.New()
+	k.Bind("\x1b", "/escape")
+	k.Bind("gg", "/twice")
+	d := keymap.Decoder{Keymap: k}
+
+	expect := func(actions []keymap.Action, expected ...keymap.Action) {
+		t.Helper()
+		if len(actions) != len(expected) || len(expected) > 0 && !reflect.DeepEqual(actions, expected) {
+			t.Errorf("Expected %q but found %q", expected, actions)
+		}
+	}
+
+	expect(d.Feed([]byte("héllo\x1bOx\r")), keymap.Action{Text: "héllo"}, keymap.Action{Command: "/send n"}, keymap.Action{Command: "/dokey NEWLINE"})
+
+	// A key starting longer bindings waits for the rest or a flush.
+	expect(d.Feed([]byte("\x1b")))
+	if !d.Pending() {
+		t.Error("Expected escape to be held back")
+	}
+	expect(d.Feed([]byte("[1;3")))
+	expect(d.Feed([]byte("D")), keymap.Action{Command: "/fg -<"})
+	expect(d.Feed([]byte("\x1b")))
+	expect(d.Flush(), keymap.Action{Command: "/escape"})
+	expect(d.Feed([]byte("gx")), keymap.Action{Text: "gx"})
+	expect(d.Feed([]byte("gg")), keymap.Action{Command: "/twice"})
+
+	// Unbound escape sequences are dropped, not typed.
+	expect(d.Feed([]byte("a\x1b[17~b")), keymap.Action{Text: "ab"})
+
+	if !k.Unbind("\x1b") || k.Unbind("\x1b") {
+		t.Error("Expected a binding to be removed once")
+	}
+	if cmd, ok := k.Lookup("\x17"); !ok || cmd != "/dokey BWORD" {
+		t.Errorf("Expected ^W to delete a word but found %q", cmd)
+	}
+}
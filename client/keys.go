@@ -0,0 +1,65 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/huntwj/gofugue/client/keymap"
+)
+
+// splitBinding splits "keys = command" at the first = not escaped with \.
+func splitBinding(args string) (string, string, bool) {
+	for idx := 0; idx < len(args); idx++ {
+		switch args[idx] {
+		case '\\':
+			idx++
+		case '=':
+			return strings.TrimSpace(args[:idx]), strings.TrimSpace(args[idx+1:]), true
+		}
+	}
+	return strings.TrimSpace(args), "", false
+}
+
+// cmdBind implements "/bind keys = command". With only keys it shows their
+// binding, and with no arguments every binding.
+func (c *Client) cmdBind(args string) error {
+	spec, cmd, assign := splitBinding(args)
+	if spec == "" {
+		if assign {
+			return errors.New("usage: /bind keys = command")
+		}
+		for _, b := range c.Keys.Bindings() {
+			c.Echo(nil, b.String())
+		}
+		return nil
+	}
+
+	keys, err := keymap.ParseKeys(spec)
+	if err != nil {
+		return err
+	}
+	if !assign {
+		cmd, ok := c.Keys.Lookup(keys)
+		if !ok {
+			return fmt.Errorf("%s: not bound", spec)
+		}
+		c.Echo(nil, keymap.Binding{Keys: keys, Command: cmd}.String())
+		return nil
+	}
+	return c.Keys.Bind(keys, cmd)
+}
+
+func (c *Client) cmdUnbind(args string) error {
+	if args == "" {
+		return errors.New("usage: /unbind keys")
+	}
+	keys, err := keymap.ParseKeys(args)
+	if err != nil {
+		return err
+	}
+	if !c.Keys.Unbind(keys) {
+		return fmt.Errorf("%s: not bound", args)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package login
+
+import (
+	"regexp"
+
+	"github.com/huntwj/gofugue/wotmud"
+)
+
+var namePromptRegex = regexp.MustCompile(`By what name do you wish to be known\? ?$`)
+var passPromptRegex = regexp.MustCompile(`^Passphrase: ?$`)
+var failuresRegex = regexp.MustCompile(`(\d+) LOGIN FAILURES? SINCE LAST SUCCESSFUL LOGIN`)
+
+// An AutoLogin answers the WoTMUD login prompts for one connection. Each
+// prompt is answered only once, so a rejected passphrase is not retried over
+// and over, locking the character out.
+type AutoLogin struct {
+	Credential Credential
+
+	// Send writes a line to the world. Secret lines must be kept out of logs.
+	Send func(text string, secret bool) error
+	// Warn tells the user about something suspicious during login.
+	Warn func(string)
+
+	sentName bool
+	sentPass bool
+}
+
+// Observe - Check a line of output for a login prompt or the login failure
+// banner. Prompts arrive as partial lines, so both partial and complete lines
+// should be passed in.
+func (a *AutoLogin) Observe(line wotmud.Line) {
+	text := line.Text()
+
+	switch {
+	case namePromptRegex.MatchString(text):
+		if a.sentName {
+			a.warn("The world asked for a name again; not logging in automatically.")
+			return
+		}
+		a.sentName = true
+		a.send(a.Credential.Character, false)
+	case passPromptRegex.MatchString(text):
+		if !a.sentName {
+			return
+		}
+		if a.sentPass {
+			a.warn("The world asked for the passphrase again; not logging in automatically.")
+			return
+		}
+		a.sentPass = true
+		a.send(a.Credential.Passphrase, true)
+	default:
+		if matches := failuresRegex.FindStringSubmatch(text); matches != nil {
+			a.warn("There have been " + matches[1] + " failed logins to this character since it last logged in!")
+		}
+	}
+}
+
+func (a *AutoLogin) send(text string, secret bool) {
+	if a.Send != nil {
+		if err := a.Send(text, secret); err != nil {
+			a.warn("Automatic login failed: " + err.Error())
+		}
+	}
+}
+
+func (a *AutoLogin) warn(text string) {
+	if a.Warn != nil {
+		a.Warn(text)
+	}
+}
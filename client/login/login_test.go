@@ -0,0 +1,173 @@
+package login_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/huntwj/gofugue/client/login"
+	"github.com/huntwj/gofugue/wotmud"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "credentials")
+	store, err := login.Open(path, login.MasterKey("correct horse"))
+	if err != nil {
+		t.Fatalf("Could not open new store: %v", err)
+	}
+	store.Set("Freddie", login.Credential{Character: "freddie", Passphrase: "hunter2"})
+	store.Set("Talia", login.Credential{Character: "talia", Passphrase: "swordfish"})
+	if err := store.Save(); err != nil {
+		t.Fatalf("Could not save store: %v", err)
+	}
+
+	data, _ := ioutil.ReadFile(path)
+	if bytes.Contains(data, []byte("hunter2")) || bytes.Contains(data, []byte("freddie")) {
+		t.Error("Credentials should not be stored in plain text")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected store to be private but found mode %v", info.Mode().Perm())
+	}
+
+	reopened, err := login.Open(path, login.MasterKey("correct horse"))
+	if err != nil {
+		t.Fatalf("Could not reopen store: %v", err)
+	}
+	cred, ok := reopened.Get("freddie")
+	if !ok || cred.Character != "freddie" || cred.Passphrase != "hunter2" {
+		t.Errorf("Unexpected credential %+v (%t)", cred, ok)
+	}
+	if worlds := reopened.Worlds(); len(worlds) != 2 {
+		t.Errorf("Expected 2 worlds but found %v", worlds)
+	}
+}
+
+func TestStoreWrongKey(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "credentials")
+	store, _ := login.Open(path, login.MasterKey("correct horse"))
+	store.Set("Freddie", login.Credential{Character: "freddie", Passphrase: "hunter2"})
+	store.Save()
+
+	if _, err := login.Open(path, login.MasterKey("battery staple")); err != login.ErrBadKey {
+		t.Errorf("Expected ErrBadKey but found %v", err)
+	}
+}
+
+func TestStoreKeyFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "credentials")
+	keyFile := filepath.Join(dir, "keys", "credentials.key")
+
+	store, err := login.Open(path, login.KeyFile(keyFile))
+	if err != nil {
+		t.Fatalf("Could not open new store: %v", err)
+	}
+	store.Set("Freddie", login.Credential{Character: "freddie", Passphrase: "hunter2"})
+	if err := store.Save(); err != nil {
+		t.Fatalf("Could not save store: %v", err)
+	}
+	if info, err := os.Stat(keyFile); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("Expected a private keyfile to be created: %v", err)
+	}
+
+	reopened, err := login.Open(path, login.KeyFile(keyFile))
+	if err != nil {
+		t.Fatalf("Could not reopen store: %v", err)
+	}
+	if !reopened.Delete("Freddie") || reopened.Delete("Freddie") {
+		t.Error("Expected credential to be deleted exactly once")
+	}
+
+	os.Remove(keyFile)
+	if _, err := login.Open(path, login.KeyFile(keyFile)); err != login.ErrBadKey {
+		t.Errorf("Expected ErrBadKey with a new keyfile but found %v", err)
+	}
+}
+
+func TestStoreRejectsOtherFiles(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "init.tf")
+	ioutil.WriteFile(path, []byte("/addworld Freddie game.wotmud.org 2224\n"), 0600)
+
+	if _, err := login.Open(path, login.MasterKey("x")); err == nil || !strings.Contains(err.Error(), "not a credential store") {
+		t.Errorf("Expected error for a non-store file but found %v", err)
+	}
+}
+
+type loginRecorder struct {
+	sent     []string
+	warnings []string
+}
+
+func newAutoLogin(r *loginRecorder) *login.AutoLogin {
+	return &login.AutoLogin{
+		Credential: login.Credential{Character: "freddie", Passphrase: "hunter2"},
+		Send: func(text string, secret bool) error {
+			r.sent = append(r.sent, text)
+			return nil
+		},
+		Warn: func(text string) {
+			r.warnings = append(r.warnings, text)
+		},
+	}
+}
+
+func TestAutoLogin(t *testing.T) {
+	t.Parallel()
+
+	var r loginRecorder
+	a := newAutoLogin(&r)
+
+	a.Observe(wotmud.NewLine("                        Running since Summer 1993"))
+	a.Observe(wotmud.NewLine("By what name do you wish to be known? "))
+	a.Observe(wotmud.NewLine("Passphrase: "))
+	a.Observe(wotmud.NewLine("Welcome to the Wheel of Time!  Type 'help' for information."))
+
+	if len(r.sent) != 2 || r.sent[0] != "freddie" || r.sent[1] != "hunter2" {
+		t.Errorf("Unexpected login sequence %q", r.sent)
+	}
+	if len(r.warnings) != 0 {
+		t.Errorf("Unexpected warnings %q", r.warnings)
+	}
+}
+
+func TestAutoLoginPassphraseOnce(t *testing.T) {
+	t.Parallel()
+
+	var r loginRecorder
+	a := newAutoLogin(&r)
+
+	a.Observe(wotmud.NewLine("By what name do you wish to be known? "))
+	a.Observe(wotmud.NewLine("Passphrase: "))
+	a.Observe(wotmud.NewLine("Passphrase: "))
+
+	if len(r.sent) != 2 {
+		t.Errorf("Expected passphrase to be sent once but found %q", r.sent)
+	}
+	if len(r.warnings) != 1 {
+		t.Errorf("Expected a warning but found %q", r.warnings)
+	}
+}
+
+func TestLoginFailureBanner(t *testing.T) {
+	t.Parallel()
+
+	var r loginRecorder
+	a := newAutoLogin(&r)
+
+	a.Observe(wotmud.NewLine("\x1b[31m3 LOGIN FAILURES SINCE LAST SUCCESSFUL LOGIN.\x1b[0m"))
+
+	if len(r.warnings) != 1 || !strings.Contains(r.warnings[0], "3 failed logins") {
+		t.Errorf("Expected login failure warning but found %q", r.warnings)
+	}
+}
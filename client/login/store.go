@@ -0,0 +1,239 @@
+package login
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrBadKey - Returned when a credential store cannot be decrypted with the
+// key it was opened with
+var ErrBadKey = errors.New("credential store could not be decrypted; wrong master key or keyfile?")
+
+// storeMagic starts every credential store file so that other files are not
+// mistaken for a store with a bad key.
+var storeMagic = []byte("gofugue-credentials-1\n")
+
+const (
+	saltSize         = 16
+	keySize          = 32
+	pbkdf2Iterations = 200000
+)
+
+// A Credential is what is needed to log a character in to a world.
+type Credential struct {
+	Character  string
+	Passphrase string
+}
+
+// A KeySource derives the key a store is encrypted with from the random salt
+// saved alongside it.
+type KeySource func(salt []byte) ([]byte, error)
+
+// MasterKey - Derive the store key from a master passphrase the user types
+func MasterKey(passphrase string) KeySource {
+	return func(salt []byte) ([]byte, error) {
+		return pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iterations, keySize)
+	}
+}
+
+// KeyFile - Derive the store key from a file of random bytes, creating the
+// file if it does not exist yet. Unlike a master passphrase this works
+// without user interaction, so the keyfile must be protected like a
+// private key.
+func KeyFile(path string) KeySource {
+	return func(salt []byte) ([]byte, error) {
+		secret, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			secret = make([]byte, keySize)
+			if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+				return nil, err
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				return nil, err
+			}
+			err = ioutil.WriteFile(path, secret, 0600)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(secret) < keySize {
+			return nil, fmt.Errorf("keyfile %s is too short", path)
+		}
+
+		return hkdf.Key(sha256.New, secret, salt, "gofugue credentials", keySize)
+	}
+}
+
+// A Store keeps login credentials per world in a file encrypted with
+// AES-GCM. Passphrases are only ever held in memory and in the encrypted
+// file, never in scripts.
+type Store struct {
+	path string
+	key  []byte
+	salt []byte
+
+	mu          sync.Mutex
+	credentials map[string]Credential
+}
+
+// Open - Load the credential store at path, or start an empty one if the file
+// does not exist yet
+func Open(path string, source KeySource) (*Store, error) {
+	s := &Store{
+		path:        path,
+		credentials: make(map[string]Credential),
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		s.salt = make([]byte, saltSize)
+		if _, err := io.ReadFull(rand.Reader, s.salt); err != nil {
+			return nil, err
+		}
+		if s.key, err = source(s.salt); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(data, storeMagic) || len(data) < len(storeMagic)+saltSize {
+		return nil, fmt.Errorf("%s is not a credential store", path)
+	}
+	data = data[len(storeMagic):]
+	s.salt, data = data[:saltSize], data[saltSize:]
+	if s.key, err = source(s.salt); err != nil {
+		return nil, err
+	}
+
+	gcm, err := s.cipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, ErrBadKey
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], storeMagic)
+	if err != nil {
+		return nil, ErrBadKey
+	}
+	if err := json.Unmarshal(plain, &s.credentials); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Store) cipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func storeKey(world string) string {
+	return strings.ToLower(world)
+}
+
+// Get - Find the credential for a world
+func (s *Store) Get(world string) (Credential, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cred, ok := s.credentials[storeKey(world)]
+	return cred, ok
+}
+
+// Set - Store the credential for a world. Call Save to write it to disk.
+func (s *Store) Set(world string, cred Credential) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.credentials[storeKey(world)] = cred
+}
+
+// Delete - Forget the credential for a world, reporting whether there was one
+func (s *Store) Delete(world string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.credentials[storeKey(world)]
+	delete(s.credentials, storeKey(world))
+	return ok
+}
+
+// Worlds - The names of all worlds with stored credentials
+func (s *Store) Worlds() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	worlds := make([]string, 0, len(s.credentials))
+	for world := range s.credentials {
+		worlds = append(worlds, world)
+	}
+	sort.Strings(worlds)
+	return worlds
+}
+
+// Save - Encrypt the store and write it to its file. The file is replaced
+// atomically and is only readable by the current user.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	plain, err := json.Marshal(s.credentials)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	gcm, err := s.cipher()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(storeMagic)
+	buf.Write(s.salt)
+	buf.Write(nonce)
+	buf.Write(gcm.Seal(nil, nonce, plain, storeMagic))
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, ".credentials")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"strings"
+
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/mapper"
+	"github.com/huntwj/gofugue/wotmud/mount"
+)
+
+// observeMount feeds a line, and the room it completed if any, into the
+// world's mount tracker. It fires the DISMOUNT hook when the player stops
+// riding and warns when the mount is left behind.
+func (s *Session) observeMount(line wotmud.Line, room *mapper.Room) {
+	if room != nil {
+		s.Mount.Enter(room)
+	}
+	for _, ev := range s.Mount.Observe(line) {
+		switch ev.Kind {
+		case mount.Dismounted:
+			s.client.FireHook(HookDismount, s, ev.Mount)
+		case mount.LeftBehind:
+			name := ev.Mount
+			if name == "" {
+				name = "your mount"
+			}
+			s.client.message(s, "Warning: you moved away without %s.", strings.ToLower(name[:1])+name[1:])
+		}
+	}
+}
+
+// resolveMount supplies mount, the name of the player's mount, mount_led,
+// whether it is being led, and followers, those following the player
+// separated by commas.
+func resolveMount(s *Session, name string) (string, bool) {
+	switch name {
+	case "mount":
+		return s.Mount.Mount(), true
+	case "mount_led":
+		return boolValue(s.Mount.Leading()), true
+	case "followers":
+		return strings.Join(s.Mount.Followers(), ", "), true
+	}
+	return "", false
+}
@@ -0,0 +1,341 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NodeVar is the variable that may name the node binary, e.g. from init.tf.
+const NodeVar = "node_path"
+
+// DefaultPluginRestart - Restart crashed plugins after a second, doubling the
+// wait up to a minute. A plugin that ran for a minute starts over.
+var DefaultPluginRestart = ReconnectPolicy{
+	Initial: time.Second,
+	Max:     time.Minute,
+	Stable:  time.Minute,
+}
+
+// DefaultPluginStopTimeout is how long a plugin gets to exit after its input
+// is closed before it is killed.
+const DefaultPluginStopTimeout = 2 * time.Second
+
+// FindNode - Locate the node binary. configured, when not empty, is used as
+// is; otherwise node is looked up on PATH.
+func FindNode(configured string) (string, error) {
+	if configured != "" {
+		return exec.LookPath(configured)
+	}
+	return exec.LookPath("node")
+}
+
+// NodeScripts - List the node plugins in dir: every .js file and every
+// directory holding a package.json or index.js, in name order.
+func NodeScripts(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var scripts []string
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if !entry.IsDir() {
+			if filepath.Ext(path) == ".js" {
+				scripts = append(scripts, path)
+			}
+			continue
+		}
+		for _, main := range []string{"package.json", "index.js"} {
+			if _, err := os.Stat(filepath.Join(path, main)); err == nil {
+				scripts = append(scripts, path)
+				break
+			}
+		}
+	}
+	sort.Strings(scripts)
+	return scripts, nil
+}
+
+// Executables - List the files in dir that are executable plugins, in name
+// order. Node scripts are not included; they are run with node.
+func Executables(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var executables []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") || !entry.Mode().IsRegular() {
+			continue
+		}
+		if entry.Mode().Perm()&0111 == 0 || filepath.Ext(entry.Name()) == ".js" {
+			continue
+		}
+		executables = append(executables, filepath.Join(dir, entry.Name()))
+	}
+	return executables, nil
+}
+
+// Plugins - Supervisor for the plugin processes of a client. Each plugin is
+// restarted with backoff when it exits on its own, and its stderr is shown
+// as LogEvents.
+type Plugins struct {
+	// Restart controls restarting plugins that exit. Plugins are not
+	// restarted when Initial is zero.
+	Restart ReconnectPolicy
+	// StopTimeout bounds how long Close waits for a plugin to exit.
+	StopTimeout time.Duration
+
+	client *Client
+
+	mu      sync.Mutex
+	plugins map[string]*plugin
+	stop    chan struct{}
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+type plugin struct {
+	name string
+	argv []string
+	proc *Process
+}
+
+func newPlugins(c *Client) *Plugins {
+	return &Plugins{
+		Restart:     DefaultPluginRestart,
+		StopTimeout: DefaultPluginStopTimeout,
+		client:      c,
+		plugins:     make(map[string]*plugin),
+		stop:        make(chan struct{}),
+	}
+}
+
+// Start - Run argv as the plugin name and keep it running until Close
+func (p *Plugins) Start(name string, argv ...string) error {
+	if len(argv) == 0 {
+		return errors.New("no plugin command given")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return errors.New("plugins are shut down")
+	}
+	if _, ok := p.plugins[name]; ok {
+		return fmt.Errorf("%s: plugin already running", name)
+	}
+	pl := &plugin{name: name, argv: argv}
+	p.plugins[name] = pl
+	p.wg.Add(1)
+	go p.supervise(pl)
+	return nil
+}
+
+// LoadDir - Start every plugin found in dir and return how many were
+// started. Executables are run directly and node scripts with the given node
+// binary; node scripts are skipped when node is empty. A plugin is named
+// after its file without the extension.
+func (p *Plugins) LoadDir(node, dir string) (int, error) {
+	executables, err := Executables(dir)
+	if err != nil {
+		return 0, err
+	}
+	var commands [][]string
+	for _, path := range executables {
+		commands = append(commands, []string{path})
+	}
+	if node != "" {
+		scripts, err := NodeScripts(dir)
+		if err != nil {
+			return 0, err
+		}
+		for _, script := range scripts {
+			commands = append(commands, []string{node, script})
+		}
+	}
+
+	started := 0
+	for _, argv := range commands {
+		base := filepath.Base(argv[len(argv)-1])
+		name := strings.TrimSuffix(base, filepath.Ext(base))
+		if err := p.Start(name, argv...); err != nil {
+			return started, err
+		}
+		started++
+	}
+	return started, nil
+}
+
+// Names - The names of the plugins being supervised
+func (p *Plugins) Names() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	names := make([]string, 0, len(p.plugins))
+	for name := range p.plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (p *Plugins) log(name, format string, args ...interface{}) {
+	p.client.emit(Event{
+		Type: LogEvent,
+		Text: fmt.Sprintf("[%s] %s", name, fmt.Sprintf(format, args...)),
+	})
+}
+
+func (p *Plugins) supervise(pl *plugin) {
+	defer p.wg.Done()
+
+	var delay time.Duration
+	for {
+		started := time.Now()
+		err := p.run(pl)
+		if p.stopping() {
+			return
+		}
+		if err == nil {
+			err = errors.New("exited")
+		}
+		if p.Restart.Initial <= 0 {
+			p.log(pl.name, "%v", err)
+			return
+		}
+
+		if delay == 0 || time.Since(started) >= p.Restart.Stable {
+			delay = p.Restart.Initial
+		} else {
+			delay = nextDelay(delay, p.Restart)
+		}
+		p.log(pl.name, "%v; restarting in %v.", err, delay)
+		select {
+		case <-time.After(delay):
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// run starts the plugin process once and waits for it to exit.
+func (p *Plugins) run(pl *plugin) error {
+	cmd := exec.Command(pl.argv[0], pl.argv[1:]...)
+	stderr := &logWriter{log: func(line string) {
+		p.log(pl.name, "%s", line)
+	}}
+	cmd.Stderr = stderr
+	defer stderr.Flush()
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	proc, err := StartProcess(p.client, pl.name, cmd)
+	if err != nil {
+		p.mu.Unlock()
+		return err
+	}
+	pl.proc = proc
+	p.mu.Unlock()
+
+	err = proc.Wait()
+
+	p.mu.Lock()
+	pl.proc = nil
+	p.mu.Unlock()
+	return err
+}
+
+func (p *Plugins) stopping() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.closed
+}
+
+// Close - Stop every plugin. Plugins are asked to exit by closing their input
+// and are killed if they have not exited after StopTimeout.
+func (p *Plugins) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.stop)
+	var running []*Process
+	for _, pl := range p.plugins {
+		if pl.proc != nil {
+			running = append(running, pl.proc)
+		}
+	}
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, proc := range running {
+		wg.Add(1)
+		go func(proc *Process) {
+			defer wg.Done()
+			proc.stdin.Close()
+			select {
+			case <-proc.served:
+			case <-time.After(p.StopTimeout):
+				proc.cmd.Process.Kill()
+			}
+		}(proc)
+	}
+	wg.Wait()
+	p.wg.Wait()
+}
+
+// logWriter splits what is written to it into lines for log.
+type logWriter struct {
+	log  func(line string)
+	mu   sync.Mutex
+	part []byte
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.part = append(w.part, p...)
+	for {
+		idx := bytes.IndexByte(w.part, '\n')
+		if idx < 0 {
+			break
+		}
+		w.log(strings.TrimRight(string(w.part[:idx]), "\r"))
+		w.part = w.part[idx+1:]
+	}
+	return len(p), nil
+}
+
+// Flush - Log any unfinished last line
+func (w *logWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.part) > 0 {
+		w.log(string(w.part))
+		w.part = nil
+	}
+}
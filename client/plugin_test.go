@@ -0,0 +1,110 @@
+package client_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huntwj/gofugue/client"
+)
+
+func TestNodeScripts(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, name := range []string{"timers.js", "notes.txt", ".hidden.js", "logs/2024-01-01_01_Freddie.clog", "mapper/package.json"} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0700)
+		ioutil.WriteFile(path, nil, 0600)
+	}
+
+	scripts, err := client.NodeScripts(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{filepath.Join(dir, "mapper"), filepath.Join(dir, "timers.js")}
+	if strings.Join(scripts, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected scripts %q but found %q", expected, scripts)
+	}
+}
+
+// waitLog reads events until a LogEvent containing text arrives.
+func waitLog(t *testing.T, c *client.Client, text string) {
+	t.Helper()
+
+	for {
+		ev := waitEvent(t, c, client.LogEvent)
+		if strings.Contains(ev.Text, text) {
+			return
+		}
+	}
+}
+
+func TestPluginRestart(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	c := client.New()
+	defer c.Quit()
+	c.Plugins.Restart = client.ReconnectPolicy{
+		Initial: 10 * time.Millisecond,
+		Max:     20 * time.Millisecond,
+		Stable:  time.Minute,
+	}
+
+	if err := c.Plugins.Start("crash", "sh", "-c", "echo oops >&2; exit 3"); err != nil {
+		t.Fatalf("Could not start plugin: %v", err)
+	}
+	waitLog(t, c, "[crash] oops")
+	waitLog(t, c, "[crash] exit status 3; restarting in 10ms.")
+	waitLog(t, c, "[crash] oops")
+	waitLog(t, c, "[crash] exit status 3; restarting in 20ms.")
+	waitLog(t, c, "[crash] oops")
+	waitLog(t, c, "[crash] exit status 3; restarting in 20ms.")
+
+	if err := c.Plugins.Start("crash", "sh"); err == nil {
+		t.Error("Expected an error starting a plugin twice")
+	}
+}
+
+func TestPluginShutdown(t *testing.T) {
+	t.Parallel()
+
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+
+	c := client.New()
+	c.Plugins.StopTimeout = 100 * time.Millisecond
+	if err := c.Plugins.Start("plugin", node, "testdata/plugin.js"); err != nil {
+		t.Fatalf("Could not start plugin: %v", err)
+	}
+	if err := c.Plugins.Start("stubborn", "sh", "-c", "exec sleep 10"); err != nil {
+		t.Fatalf("Could not start plugin: %v", err)
+	}
+	for {
+		if ev := waitEvent(t, c, client.MessageEvent); ev.Text == "plugin ready" {
+			break
+		}
+	}
+	if names := c.Plugins.Names(); len(names) != 2 {
+		t.Errorf("Expected 2 plugins but found %v", names)
+	}
+
+	start := time.Now()
+	c.Quit()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected plugins to stop promptly but took %v", elapsed)
+	}
+	if err := c.Plugins.Start("late", node); err == nil {
+		t.Error("Expected an error starting a plugin after quitting")
+	}
+}
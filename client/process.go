@@ -0,0 +1,504 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/huntwj/gofugue/client/rpc"
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/combat"
+	"github.com/huntwj/gofugue/wotmud/mapper"
+	"github.com/huntwj/gofugue/wotmud/prompt"
+)
+
+// processQueueSize is how many notifications may wait for a slow process
+// before further events are dropped.
+const processQueueSize = 1024
+
+// Event names a process can subscribe to.
+var processEventNames = map[EventType]string{
+	LineEvent:   "line",
+	PromptEvent: "prompt",
+	RoomEvent:   "room",
+	CombatEvent: "combat",
+	CommEvent:   "comm",
+}
+
+// A Process is a child process speaking the gofugue protocol: JSON-RPC 2.0,
+// one message per line, over its stdin and stdout. It can be written in any
+// language. The process subscribes to client events, which it receives as
+// "event" notifications, registers triggers, aliases and hooks, which it
+// hears about through "trigger", "alias" and "hook" notifications, and calls
+// back into the client to send commands, echo text and use variables. Every
+// trigger, alias and hook it registered is removed when it exits.
+type Process struct {
+	// Name identifies the process in messages and in the names of its
+	// triggers.
+	Name string
+
+	client *Client
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	conn   *rpc.Conn
+
+	mu         sync.Mutex
+	subscribed map[string]bool
+	dropped    bool
+	removers   map[string]func()
+
+	queue  chan notification
+	done   chan struct{}
+	served chan struct{}
+	err    error
+}
+
+type notification struct {
+	method string
+	params interface{}
+}
+
+// StartProcess - Start cmd as the process called name and connect it to the
+// client. Its stdin and stdout are used for the protocol; stderr is left for
+// the caller to set up.
+func StartProcess(c *Client, name string, cmd *exec.Cmd) (*Process, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	pr := &Process{
+		Name:       name,
+		client:     c,
+		cmd:        cmd,
+		stdin:      stdin,
+		subscribed: make(map[string]bool),
+		removers:   make(map[string]func()),
+		queue:      make(chan notification, processQueueSize),
+		done:       make(chan struct{}),
+		served:     make(chan struct{}),
+	}
+	pr.conn = rpc.NewConn(stdout, stdin, pr.handle)
+	pr.removers["listener"] = c.AddListener(pr.listen)
+
+	go pr.notify()
+	go func() {
+		pr.conn.Serve()
+		pr.cleanup()
+		close(pr.done)
+		pr.err = cmd.Wait()
+		close(pr.served)
+	}()
+
+	return pr, nil
+}
+
+// Wait - Wait for the process to exit and return its exit status
+func (pr *Process) Wait() error {
+	<-pr.served
+	return pr.err
+}
+
+// Close - Close the process's input, which asks it to exit, and wait for it
+func (pr *Process) Close() error {
+	pr.stdin.Close()
+	return pr.Wait()
+}
+
+// Conn - The protocol connection, for calling methods the process provides
+func (pr *Process) Conn() *rpc.Conn {
+	return pr.conn
+}
+
+// cleanup removes everything the process registered with the client.
+func (pr *Process) cleanup() {
+	pr.mu.Lock()
+	removers := pr.removers
+	pr.removers = nil
+	pr.mu.Unlock()
+
+	for _, remove := range removers {
+		remove()
+	}
+}
+
+// post queues a notification for the process, dropping it if the process is
+// not keeping up.
+func (pr *Process) post(method string, params interface{}) {
+	select {
+	case pr.queue <- notification{method, params}:
+	default:
+		pr.mu.Lock()
+		warn := !pr.dropped
+		pr.dropped = true
+		pr.mu.Unlock()
+		if warn {
+			go pr.client.message(nil, "Process %s is not keeping up; dropping events.", pr.Name)
+		}
+	}
+}
+
+func (pr *Process) listen(ev Event) {
+	name, ok := processEventNames[ev.Type]
+	if !ok {
+		return
+	}
+	pr.mu.Lock()
+	subscribed := pr.subscribed[name]
+	pr.mu.Unlock()
+
+	if subscribed {
+		pr.post("event", newEventParams(ev))
+	}
+}
+
+// notify sends queued notifications until the connection closes.
+func (pr *Process) notify() {
+	for {
+		select {
+		case n := <-pr.queue:
+			pr.conn.Notify(n.method, n.params)
+			pr.mu.Lock()
+			if len(pr.queue) == 0 {
+				pr.dropped = false
+			}
+			pr.mu.Unlock()
+		case <-pr.done:
+			return
+		}
+	}
+}
+
+type eventLine struct {
+	Raw     string `json:"raw"`
+	Text    string `json:"text"`
+	Partial bool   `json:"partial,omitempty"`
+}
+
+type eventCombatant struct {
+	Name   string `json:"name"`
+	Health string `json:"health"`
+}
+
+type eventCombat struct {
+	Target eventCombatant  `json:"target"`
+	Tank   *eventCombatant `json:"tank,omitempty"`
+}
+
+type eventPrompt struct {
+	Lit    bool         `json:"lit"`
+	Riding bool         `json:"riding"`
+	Health string       `json:"health"`
+	Spell  *string      `json:"spell,omitempty"`
+	Moves  string       `json:"moves"`
+	Combat *eventCombat `json:"combat,omitempty"`
+}
+
+type eventRoom struct {
+	Name        string   `json:"name"`
+	Description []string `json:"description"`
+	Exits       []string `json:"exits"`
+}
+
+type eventAttack struct {
+	Attacker string `json:"attacker"`
+	Defender string `json:"defender"`
+	Outcome  string `json:"outcome"`
+	Attack   string `json:"attack,omitempty"`
+	BodyPart string `json:"bodyPart,omitempty"`
+	Severity string `json:"severity,omitempty"`
+}
+
+type eventComm struct {
+	Speaker string `json:"speaker"`
+	Channel string `json:"channel"`
+	To      string `json:"to,omitempty"`
+	Text    string `json:"text"`
+}
+
+type eventParams struct {
+	Type   string       `json:"type"`
+	World  string       `json:"world,omitempty"`
+	Line   *eventLine   `json:"line,omitempty"`
+	Prompt *eventPrompt `json:"prompt,omitempty"`
+	Room   *eventRoom   `json:"room,omitempty"`
+	Attack *eventAttack `json:"attack,omitempty"`
+	Comm   *eventComm   `json:"comm,omitempty"`
+}
+
+func worldName(s *Session) string {
+	if s == nil {
+		return ""
+	}
+	return s.World.Name
+}
+
+func newEventParams(ev Event) eventParams {
+	params := eventParams{
+		Type:  processEventNames[ev.Type],
+		World: worldName(ev.Session),
+	}
+
+	switch ev.Type {
+	case LineEvent, PromptEvent:
+		params.Line = newEventLine(ev.Line)
+		params.Prompt = newEventPrompt(ev.Line.Prompt())
+	case RoomEvent:
+		params.Room = newEventRoom(ev.Room)
+	case CombatEvent:
+		params.Line = newEventLine(ev.Line)
+		params.Attack = newEventAttack(ev.Attack)
+	case CommEvent:
+		params.Line = newEventLine(ev.Line)
+		if ev.Comm != nil {
+			comm := eventComm(*ev.Comm)
+			params.Comm = &comm
+		}
+	}
+	return params
+}
+
+func newEventLine(line wotmud.Line) *eventLine {
+	return &eventLine{
+		Raw:     line.Raw,
+		Text:    line.Text(),
+		Partial: line.Partial,
+	}
+}
+
+func newEventPrompt(info *prompt.Info) *eventPrompt {
+	if info == nil {
+		return nil
+	}
+	p := &eventPrompt{
+		Lit:    info.IsLit,
+		Riding: info.IsRiding,
+		Health: info.Health,
+		Spell:  info.Spell,
+		Moves:  info.Moves,
+	}
+	if info.Combat != nil {
+		p.Combat = &eventCombat{
+			Target: eventCombatant(info.Combat.Target),
+		}
+		if info.Combat.Tank != nil {
+			tank := eventCombatant(*info.Combat.Tank)
+			p.Combat.Tank = &tank
+		}
+	}
+	return p
+}
+
+func newEventRoom(room *mapper.Room) *eventRoom {
+	if room == nil {
+		return nil
+	}
+	return &eventRoom{
+		Name:        room.Name,
+		Description: room.Description,
+		Exits:       room.Exits,
+	}
+}
+
+func newEventAttack(attack *combat.Event) *eventAttack {
+	if attack == nil {
+		return nil
+	}
+	return &eventAttack{
+		Attacker: attack.Attacker,
+		Defender: attack.Defender,
+		Outcome:  attack.Outcome.String(),
+		Attack:   attack.Attack,
+		BodyPart: attack.BodyPart,
+		Severity: attack.Severity,
+	}
+}
+
+func invalidParams(format string, args ...interface{}) error {
+	return &rpc.Error{Code: rpc.CodeInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
+// nameParams are the parameters of the methods that only take a name.
+type nameParams struct {
+	Name string `json:"name"`
+}
+
+func decodeName(params json.RawMessage) (string, error) {
+	var p nameParams
+	if err := json.Unmarshal(params, &p); err != nil || p.Name == "" {
+		return "", invalidParams("a name is required")
+	}
+	return p.Name, nil
+}
+
+// handle answers requests from the process.
+func (pr *Process) handle(method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case "subscribe", "unsubscribe":
+		var p struct {
+			Events []string `json:"events"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, invalidParams("%v", err)
+		}
+		return nil, pr.subscribe(p.Events, method == "subscribe")
+
+	case "send":
+		var p struct {
+			Text  string `json:"text"`
+			World string `json:"world"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, invalidParams("%v", err)
+		}
+		s := pr.client.Foreground()
+		if p.World != "" {
+			s = pr.client.Session(p.World)
+		}
+		if s == nil {
+			return nil, ErrNotConnected
+		}
+		return nil, s.Send(p.Text)
+
+	case "echo":
+		var p struct {
+			Text  string `json:"text"`
+			World string `json:"world"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, invalidParams("%v", err)
+		}
+		var s *Session
+		if p.World != "" {
+			s = pr.client.Session(p.World)
+		}
+		pr.client.Echo(s, p.Text)
+		return nil, nil
+
+	case "setVariable":
+		var p struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil || p.Name == "" {
+			return nil, invalidParams("a name is required")
+		}
+		pr.client.Interp.SetVar(p.Name, p.Value)
+		return nil, nil
+
+	case "getVariable":
+		name, err := decodeName(params)
+		if err != nil {
+			return nil, err
+		}
+		value, ok := pr.client.Interp.Var(name)
+		return map[string]interface{}{"value": value, "set": ok}, nil
+
+	case "addTrigger":
+		var p struct {
+			Name    string `json:"name"`
+			Pattern string `json:"pattern"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil || p.Name == "" {
+			return nil, invalidParams("a name is required")
+		}
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return nil, invalidParams("%v", err)
+		}
+		name := p.Name
+		pr.register("trigger "+name, pr.client.AddTrigger(pr.Name+":"+name, re, func(s *Session, line wotmud.Line, match []string) {
+			pr.post("trigger", map[string]interface{}{
+				"name":  name,
+				"world": s.World.Name,
+				"line":  newEventLine(line),
+				"match": match,
+			})
+		}))
+		return nil, nil
+
+	case "addAlias":
+		name, err := decodeName(params)
+		if err != nil {
+			return nil, err
+		}
+		pr.register("alias "+name, pr.client.AddAlias(name, func(s *Session, args string) error {
+			pr.post("alias", map[string]string{"name": name, "world": worldName(s), "args": args})
+			return nil
+		}))
+		return nil, nil
+
+	case "addHook":
+		name, err := decodeName(params)
+		if err != nil {
+			return nil, err
+		}
+		pr.register("hook "+name, pr.client.AddHook(name, func(s *Session, args string) {
+			pr.post("hook", map[string]string{"name": name, "world": worldName(s), "args": args})
+		}))
+		return nil, nil
+
+	case "removeTrigger", "removeAlias", "removeHook":
+		name, err := decodeName(params)
+		if err != nil {
+			return nil, err
+		}
+		kind := strings.ToLower(strings.TrimPrefix(method, "remove"))
+		pr.unregister(kind + " " + name)
+		return nil, nil
+	}
+
+	return nil, &rpc.Error{Code: rpc.CodeMethodNotFound, Message: method + ": no such method"}
+}
+
+func (pr *Process) subscribe(events []string, on bool) error {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	for _, name := range events {
+		known := false
+		for _, existing := range processEventNames {
+			known = known || existing == name
+		}
+		if !known {
+			return invalidParams("%s: unknown event", name)
+		}
+		pr.subscribed[name] = on
+	}
+	return nil
+}
+
+// register keeps the func removing a trigger, alias or hook, replacing and
+// removing one registered earlier under the same key.
+func (pr *Process) register(key string, remove func()) {
+	pr.mu.Lock()
+	old := pr.removers[key]
+	pr.removers[key] = remove
+	pr.mu.Unlock()
+
+	if old != nil {
+		old()
+	}
+}
+
+func (pr *Process) unregister(key string) {
+	pr.mu.Lock()
+	remove := pr.removers[key]
+	delete(pr.removers, key)
+	pr.mu.Unlock()
+
+	if remove != nil {
+		remove()
+	}
+}
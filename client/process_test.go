@@ -0,0 +1,120 @@
+package client_test
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/huntwj/gofugue/client"
+)
+
+func TestNodePlugin(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("node is not installed")
+	}
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	c := client.New()
+	defer c.Quit()
+	c.Input(server.addWorldCommand("Freddie"))
+	if _, err := c.Connect("Freddie"); err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	conn := server.accept(t)
+
+	node, err := client.StartProcess(c, "plugin", exec.Command("node", "testdata/plugin.js"))
+	if err != nil {
+		t.Fatalf("Could not start node: %v", err)
+	}
+	for {
+		ev := waitEvent(t, c, client.MessageEvent)
+		if ev.Text == "plugin ready" {
+			break
+		}
+	}
+	if value, _ := c.Interp.Var("plugin"); value != "loaded" {
+		t.Errorf("Expected plugin to set a variable but found '%s'", value)
+	}
+
+	conn.Write([]byte("Talia tells you 'ping'\r\n"))
+	server.expectReceived(t, "tell talia pong")
+	conn.Write([]byte("\x1b[36mThe White Crescent\x1b[0m\r\nA quiet room.\r\n[ obvious exits: N S ]\r\n"))
+	server.expectReceived(t, "say entered The White Crescent NS")
+	c.Input("greet Talia")
+	server.expectReceived(t, "say Hello, Talia!")
+
+	if err := node.Close(); err != nil {
+		t.Errorf("Expected node to exit cleanly but found %v", err)
+	}
+}
+
+// waitMessages reads events until a message with each of the given texts has
+// arrived.
+func waitMessages(t *testing.T, c *client.Client, texts ...string) {
+	t.Helper()
+
+	want := make(map[string]bool)
+	for _, text := range texts {
+		want[text] = true
+	}
+	for len(want) > 0 {
+		delete(want, waitEvent(t, c, client.MessageEvent).Text)
+	}
+}
+
+func TestProcessPlugins(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"sh", "python3"} {
+		if _, err := exec.LookPath(name); err != nil {
+			t.Skipf("%s is not installed", name)
+		}
+	}
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	c := client.New()
+	defer c.Quit()
+	c.Input(server.addWorldCommand("Freddie"))
+	c.Input(server.addWorldCommand("Talia"))
+	freddie, err := c.Connect("Freddie")
+	if err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	conn := server.accept(t)
+
+	// Without node only the executables are started.
+	if started, err := c.Plugins.LoadDir("", "testdata"); err != nil || started != 2 {
+		t.Fatalf("Expected 2 plugins to start but found %d: %v", started, err)
+	}
+	waitMessages(t, c, "shell ready", "python ready")
+	if value, _ := c.Interp.Var("shell"); value != "yes" {
+		t.Errorf("Expected shell plugin to set a variable but found '%s'", value)
+	}
+
+	conn.Write([]byte("Talia tells you 'ping'\r\n"))
+	server.expectReceived(t, "tell talia pong ping")
+	c.Input("greet Talia")
+	server.expectReceived(t, "say Hello, Talia!")
+
+	talia, err := c.Connect("Talia")
+	if err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	server.accept(t)
+	waitMessages(t, c, "welcome to Talia")
+	if talia.Triggers.Len() != 1 {
+		t.Errorf("Expected a newly connected world to get the plugin's trigger")
+	}
+
+	c.Plugins.Close()
+	if freddie.Triggers.Len() != 0 || talia.Triggers.Len() != 0 {
+		t.Error("Expected triggers to be removed when the plugin exits")
+	}
+	c.Input("greet Freddie")
+	server.expectReceived(t, "greet Freddie")
+}
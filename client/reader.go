@@ -0,0 +1,85 @@
+package client
+
+import (
+	"time"
+
+	"github.com/huntwj/gofugue/client/telnet"
+	"github.com/huntwj/gofugue/wotmud"
+)
+
+// DefaultPromptTimeout is how long a partial line has to sit without further
+// output before it is treated as a prompt, for servers that do not mark their
+// prompts with GA or EOR.
+const DefaultPromptTimeout = 200 * time.Millisecond
+
+// A LineReader turns the data read from a telnet connection into logical
+// lines. Partial lines are flushed as soon as the server marks the end of a
+// prompt. When the server has not negotiated prompt markers, a partial line is
+// flushed once no more output has arrived for PromptTimeout.
+type LineReader struct {
+	PromptTimeout time.Duration
+
+	conn *telnet.Conn
+	norm wotmud.Normalizer
+}
+
+// NewLineReader creates a LineReader for the given connection.
+func NewLineReader(conn *telnet.Conn) *LineReader {
+	return &LineReader{
+		PromptTimeout: DefaultPromptTimeout,
+		conn:          conn,
+	}
+}
+
+type frameResult struct {
+	frame telnet.Frame
+	err   error
+}
+
+// Run reads from the connection until it fails, sending every line to lines.
+// Any partial line left when the connection fails is flushed before the error
+// is returned.
+func (r *LineReader) Run(lines chan<- wotmud.Line) error {
+	frames := make(chan frameResult)
+	go func() {
+		for {
+			frame, err := r.conn.ReadFrame()
+			frames <- frameResult{frame, err}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	timer := time.NewTimer(r.PromptTimeout)
+	timer.Stop()
+
+	flush := func() {
+		if line, ok := r.norm.Flush(); ok {
+			lines <- line
+		}
+	}
+
+	for {
+		select {
+		case res := <-frames:
+			for _, line := range r.norm.Write(res.frame.Data) {
+				lines <- line
+			}
+			if res.err != nil {
+				timer.Stop()
+				flush()
+				return res.err
+			}
+
+			if res.frame.EndOfPrompt {
+				timer.Stop()
+				flush()
+			} else if _, ok := r.norm.Partial(); ok && !r.conn.MarksPrompts() {
+				timer.Reset(r.PromptTimeout)
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
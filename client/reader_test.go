@@ -0,0 +1,94 @@
+package client_test
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/huntwj/gofugue/client"
+	"github.com/huntwj/gofugue/client/telnet"
+	"github.com/huntwj/gofugue/wotmud"
+)
+
+func startLineReader(t *testing.T, timeout time.Duration) (net.Conn, chan wotmud.Line, chan error) {
+	t.Helper()
+
+	server, local := net.Pipe()
+	reader := client.NewLineReader(telnet.NewConn(local))
+	reader.PromptTimeout = timeout
+
+	lines := make(chan wotmud.Line, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- reader.Run(lines)
+	}()
+
+	return server, lines, done
+}
+
+func expectLine(t *testing.T, lines chan wotmud.Line, within time.Duration, expected string) wotmud.Line {
+	t.Helper()
+
+	select {
+	case line := <-lines:
+		if line.Raw != expected {
+			t.Errorf("Expected line %q but found %q", expected, line.Raw)
+		}
+		return line
+	case <-time.After(within):
+		t.Fatalf("Timed out waiting for line %q", expected)
+	}
+	return wotmud.Line{}
+}
+
+func TestPromptFlushedOnGoAhead(t *testing.T) {
+	t.Parallel()
+
+	server, lines, done := startLineReader(t, time.Hour)
+	defer server.Close()
+
+	go server.Write(append([]byte("You are standing.\r\n* HP:Healthy MV:Full > "), telnet.IAC, telnet.GA))
+
+	expectLine(t, lines, time.Second, "You are standing.")
+	line := expectLine(t, lines, time.Second, "* HP:Healthy MV:Full > ")
+	if line.Prompt() == nil {
+		t.Error("Expected flushed line to carry prompt info")
+	}
+
+	server.Close()
+	if err := <-done; err != io.EOF {
+		t.Errorf("Expected EOF but found %v", err)
+	}
+}
+
+func TestPromptFlushedOnTimeout(t *testing.T) {
+	t.Parallel()
+
+	server, lines, _ := startLineReader(t, 20*time.Millisecond)
+	defer server.Close()
+
+	go server.Write([]byte("* HP:Healthy MV:Fresh > "))
+
+	line := expectLine(t, lines, time.Second, "* HP:Healthy MV:Fresh > ")
+	if line.Prompt() == nil {
+		t.Error("Expected flushed line to carry prompt info")
+	}
+
+	go server.Write([]byte("\r\nThe River Lady has arrived at the docks.\r\n"))
+	expectLine(t, lines, time.Second, "The River Lady has arrived at the docks.")
+}
+
+func TestPartialLineFlushedOnClose(t *testing.T) {
+	t.Parallel()
+
+	server, lines, done := startLineReader(t, time.Hour)
+
+	server.Write([]byte("By what name do you wish to be known? "))
+	server.Close()
+
+	if err := <-done; err != io.EOF {
+		t.Errorf("Expected EOF but found %v", err)
+	}
+	expectLine(t, lines, time.Second, "By what name do you wish to be known? ")
+}
@@ -0,0 +1,241 @@
+package client_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huntwj/gofugue/client"
+)
+
+// loginAndDrop plays the part of a MUD that logs the character in, shows a
+// room and then drops the connection.
+func loginAndDrop(t *testing.T, server *testServer, room string) {
+	t.Helper()
+
+	conn := server.accept(t)
+	conn.Write([]byte("By what name do you wish to be known? "))
+	server.expectReceived(t, "freddie")
+	conn.Write([]byte("Passphrase: "))
+	server.expectReceived(t, "hunter2")
+	conn.Write([]byte("\r\n\x1b[36m" + room + "\x1b[0m\r\nA quiet room.\r\n[ obvious exits: N S ]\r\n"))
+	conn.Write([]byte("* HP:Healthy MV:Full > "))
+	time.Sleep(50 * time.Millisecond)
+	conn.Close()
+}
+
+func expectHook(t *testing.T, hooks chan string, expected string) {
+	t.Helper()
+
+	select {
+	case hook := <-hooks:
+		if hook != expected {
+			t.Errorf("Expected hook %s but found %s", expected, hook)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for hook %s", expected)
+	}
+}
+
+func TestReconnectAfterDrop(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	logDir := t.TempDir()
+	c := client.New()
+	defer c.Quit()
+	c.LogDir = logDir
+	c.Reconnect = client.ReconnectPolicy{
+		Initial: 10 * time.Millisecond,
+		Max:     40 * time.Millisecond,
+		Stable:  time.Minute,
+	}
+	go func() {
+		for range c.Events() {
+		}
+	}()
+
+	hooks := make(chan string, 10)
+	for _, name := range []string{client.HookConnect, client.HookDisconnect} {
+		name := name
+		c.AddHook(name, func(s *client.Session, args string) {
+			hooks <- name + " " + args
+		})
+	}
+
+	c.Credentials = newTestStore(t)
+	c.Input(server.addWorldCommand("Freddie"))
+	c.Input("/addlogin Freddie freddie hunter2")
+	s, err := c.Connect("Freddie")
+	if err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	expectHook(t, hooks, "CONNECT Freddie")
+	loginAndDrop(t, server, "The White Crescent")
+	expectHook(t, hooks, "DISCONNECT Freddie")
+
+	// The client reconnects on its own and logs in again.
+	expectHook(t, hooks, "CONNECT Freddie")
+	if room := s.Mapper.Current(); room == nil || room.Name != "The White Crescent" {
+		t.Errorf("Expected mapper position to survive the reconnect but found %v", room)
+	}
+	loginAndDrop(t, server, "A Wide Paved Street")
+	expectHook(t, hooks, "DISCONNECT Freddie")
+	expectHook(t, hooks, "CONNECT Freddie")
+
+	if again := c.Session("Freddie"); again != s {
+		t.Error("Reconnecting should keep the same session")
+	}
+	if room := s.Mapper.Current(); room == nil || room.Name != "A Wide Paved Street" {
+		t.Errorf("Expected mapper to keep tracking after reconnect but found %v", room)
+	}
+
+	// Disconnecting on purpose does not reconnect.
+	server.accept(t)
+	if err := c.Interp.Eval("/dc Freddie"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expectHook(t, hooks, "DISCONNECT Freddie")
+	select {
+	case hook := <-hooks:
+		t.Errorf("Unexpected hook %s after /dc", hook)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	c.Quit()
+	logs, _ := filepath.Glob(filepath.Join(logDir, "*.clog"))
+	if len(logs) != 1 {
+		t.Fatalf("Expected a single log across reconnects but found %v", logs)
+	}
+	data, _ := ioutil.ReadFile(logs[0])
+	log := string(data)
+	for _, expected := range []string{"The White Crescent", "A Wide Paved Street", "<Sent: freddie >", "<Sent: xxxxxxxx >"} {
+		if !strings.Contains(log, expected) {
+			t.Errorf("Expected log to contain %q", expected)
+		}
+	}
+	if strings.Contains(log, "hunter2") {
+		t.Error("Passphrase should not be logged")
+	}
+}
+
+func TestReconnectBacksOff(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	c := client.New()
+	defer c.Quit()
+	c.Reconnect = client.ReconnectPolicy{
+		Initial: 20 * time.Millisecond,
+		Max:     80 * time.Millisecond,
+		Stable:  time.Minute,
+	}
+
+	c.Input(server.addWorldCommand("Freddie"))
+	if _, err := c.Connect("Freddie"); err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	server.accept(t).Close()
+	server.Close()
+
+	var delays []string
+	timeout := time.After(2 * time.Second)
+	for len(delays) < 4 {
+		select {
+		case ev := <-c.Events():
+			if ev.Type == client.MessageEvent && strings.HasPrefix(ev.Text, "% Reconnecting") {
+				delays = append(delays, strings.TrimSuffix(ev.Text[strings.LastIndex(ev.Text, " ")+1:], "."))
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for reconnect attempts, saw %v", delays)
+		}
+	}
+
+	expected := []string{"20ms", "40ms", "80ms", "80ms"}
+	for idx := range expected {
+		if delays[idx] != expected[idx] {
+			t.Errorf("Expected delays %v but found %v", expected, delays)
+			break
+		}
+	}
+
+	if err := c.Interp.Eval("/dc Freddie"); err != nil {
+		t.Errorf("Expected /dc to cancel reconnecting but found %v", err)
+	}
+}
+
+func TestDcWhileDialing(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	// The world is slow to answer: dialing waits until the test lets it go.
+	dialing := make(chan struct{}, 1)
+	answer := make(chan struct{}, 1)
+	c := client.New()
+	defer c.Quit()
+	c.Dial = func(network, address string) (net.Conn, error) {
+		dialing <- struct{}{}
+		<-answer
+		return net.Dial(network, address)
+	}
+	c.Reconnect = client.ReconnectPolicy{
+		Initial: 10 * time.Millisecond,
+		Max:     10 * time.Millisecond,
+		Stable:  time.Minute,
+	}
+	c.Input(server.addWorldCommand("Freddie"))
+	waitDial := func() {
+		t.Helper()
+		select {
+		case <-dialing:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for a dial")
+		}
+	}
+
+	connected := make(chan error, 1)
+	go func() {
+		_, err := c.Connect("Freddie")
+		connected <- err
+	}()
+	waitDial()
+	if err := c.Interp.Eval("/dc Freddie"); err != nil {
+		t.Errorf("Expected /dc to stop the connection being opened but found %v", err)
+	}
+	answer <- struct{}{}
+	if err := <-connected; !errors.Is(err, client.ErrClosed) {
+		t.Fatalf("Expected ErrClosed but found %v", err)
+	}
+	server.accept(t)
+
+	// The same while reconnecting after the connection dropped.
+	answer <- struct{}{}
+	s, err := c.Connect("Freddie")
+	if err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	waitDial()
+	server.accept(t).Close()
+	waitDial()
+	if err := c.Interp.Eval("/dc Freddie"); err != nil {
+		t.Errorf("Expected /dc to stop reconnecting but found %v", err)
+	}
+	answer <- struct{}{}
+	time.Sleep(50 * time.Millisecond)
+	if s.Connected() {
+		t.Error("Expected the session to stay closed")
+	}
+	select {
+	case <-dialing:
+		t.Error("Expected no further reconnect attempts")
+	default:
+	}
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/huntwj/gofugue/client/roster"
+	"github.com/huntwj/gofugue/tflang/interp"
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/comm"
+)
+
+// FriendAttrVar and EnemyAttrVar hold the display attributes, as for
+// "/hilite -a", of what friends and enemies say on the communication
+// channels. They default to DefaultFriendAttr and DefaultEnemyAttr.
+const (
+	FriendAttrVar = "friend_attr"
+	EnemyAttrVar  = "enemy_attr"
+)
+
+// The default display attributes of what friends and enemies say.
+const (
+	DefaultFriendAttr = "BCgreen"
+	DefaultEnemyAttr  = "BCred"
+)
+
+// observeWho feeds a line into the world's who list parser, recording the
+// players of a complete list in the roster.
+func (s *Session) observeWho(line wotmud.Line) {
+	players, ok := s.who.Observe(line)
+	if !ok {
+		return
+	}
+	c := s.client
+	c.Roster.Seen(players, time.Now())
+	if err := c.Roster.Save(); err != nil {
+		c.message(s, "Could not save the roster: %v", err)
+	}
+}
+
+// colorComm shows a message from a friend or enemy in their colours.
+func (c *Client) colorComm(line wotmud.Line, msg *comm.Message) wotmud.Line {
+	entry, ok := c.Roster.Get(msg.Speaker)
+	if !ok || msg.FromYou() {
+		return line
+	}
+	name, attr := FriendAttrVar, DefaultFriendAttr
+	switch entry.Relation {
+	case roster.Friend:
+	case roster.Enemy:
+		name, attr = EnemyAttrVar, DefaultEnemyAttr
+	default:
+		return line
+	}
+	if value, ok := c.Interp.Var(name); ok {
+		attr = value
+	}
+	if _, code, err := interp.AttrCodes(attr); err == nil && code != "" {
+		line.Raw = line.Raw[:line.PromptEnd] + wrapColor(line.Text(), code)
+	}
+	return line
+}
+
+// wrapColor shows text in the colour code, keeping the colours already in it.
+// The colour is applied again after each reset so that it lasts to the end.
+func wrapColor(text, code string) string {
+	const reset = "\x1b[0m"
+	text = strings.TrimSuffix(strings.Replace(text, reset, reset+code, -1), code)
+	if !strings.HasSuffix(text, reset) {
+		text += reset
+	}
+	return code + text
+}
+
+// cmdRoster implements "/roster [name]", listing the players in the roster,
+// or showing all that is known of one.
+func (c *Client) cmdRoster(args string) error {
+	if args != "" {
+		entry, ok := c.Roster.Get(args)
+		if !ok {
+			return fmt.Errorf("%s is not in the roster", args)
+		}
+		c.message(nil, "%s", describePlayer(entry))
+		if !entry.FirstSeen.IsZero() {
+			c.message(nil, "First seen %s, last seen %s.", entry.FirstSeen.Format(time.RFC1123), entry.LastSeen.Format(time.RFC1123))
+		}
+		if entry.Note != "" {
+			c.message(nil, "Note: %s", entry.Note)
+		}
+		return nil
+	}
+
+	players := c.Roster.Players()
+	if len(players) == 0 {
+		c.message(nil, "No players.")
+	}
+	for _, entry := range players {
+		c.message(nil, "%s", describePlayer(entry))
+	}
+	return nil
+}
+
+// describePlayer sums up a roster entry on one line, such as "Spruce the
+// Hundredman [Child of Light] (friend)".
+func describePlayer(entry roster.Entry) string {
+	text := entry.Title
+	if text == "" {
+		text = entry.Name
+	}
+	for _, clan := range entry.Clans {
+		text += " [" + clan + "]"
+	}
+	if entry.Relation != roster.Neutral {
+		text += " (" + string(entry.Relation) + ")"
+	}
+	return text
+}
+
+// relationCmd implements "/friend name", "/enemy name" and "/neutral name",
+// recording how the user regards a player.
+func (c *Client) relationCmd(cmd string, rel roster.Relation) interp.Command {
+	return func(args string) error {
+		if args == "" || strings.ContainsAny(args, " \t") {
+			return errors.New("usage: /" + cmd + " name")
+		}
+		c.Roster.SetRelation(args, rel)
+		return c.Roster.Save()
+	}
+}
+
+// cmdNote implements "/note name [text]", recording a note on a player, or
+// clearing it when text is left out.
+func (c *Client) cmdNote(args string) error {
+	fields := strings.SplitN(strings.TrimSpace(args), " ", 2)
+	if fields[0] == "" {
+		return errors.New("usage: /note name [text]")
+	}
+	note := ""
+	if len(fields) == 2 {
+		note = strings.TrimSpace(fields[1])
+	}
+	c.Roster.SetNote(fields[0], note)
+	return c.Roster.Save()
+}
@@ -0,0 +1,181 @@
+// Package roster keeps a local record of the players seen in who lists, with
+// when they were first and last seen and what the user notes about them.
+package roster
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/huntwj/gofugue/wotmud/who"
+)
+
+// A Relation is how the user regards a player.
+type Relation string
+
+// Relations.
+const (
+	Neutral Relation = ""
+	Friend  Relation = "friend"
+	Enemy   Relation = "enemy"
+)
+
+// An Entry is what the roster knows of a player. Title and Clans are as the
+// player was last listed.
+type Entry struct {
+	Name      string
+	Title     string   `json:",omitempty"`
+	Clans     []string `json:",omitempty"`
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Relation  Relation `json:",omitempty"`
+	Note      string   `json:",omitempty"`
+}
+
+// A Roster is the record of players, kept in a JSON file. It is safe for
+// concurrent use.
+type Roster struct {
+	path string
+
+	mu      sync.Mutex
+	players map[string]*Entry
+}
+
+// New - Create an empty roster that is not kept in a file
+func New() *Roster {
+	return &Roster{players: make(map[string]*Entry)}
+}
+
+// Open - Load the roster kept at path, or start an empty one if the file
+// does not exist yet
+func Open(path string) (*Roster, error) {
+	r := New()
+	r.path = path
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []*Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		r.players[key(e.Name)] = e
+	}
+	return r, nil
+}
+
+func key(name string) string {
+	return strings.ToLower(name)
+}
+
+// entry finds the player called name, adding them when not known yet.
+func (r *Roster) entry(name string) *Entry {
+	e, ok := r.players[key(name)]
+	if !ok {
+		e = &Entry{Name: name}
+		r.players[key(name)] = e
+	}
+	return e
+}
+
+// Seen - Note the players of a who list shown at now
+func (r *Roster) Seen(players []who.Player, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, p := range players {
+		if p.Name == "" {
+			continue
+		}
+		e := r.entry(p.Name)
+		e.Title = p.Title
+		e.Clans = append([]string(nil), p.Clans...)
+		if e.FirstSeen.IsZero() {
+			e.FirstSeen = now
+		}
+		e.LastSeen = now
+	}
+}
+
+// Get - Find the player called name
+func (r *Roster) Get(name string) (Entry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.players[key(name)]
+	if !ok {
+		return Entry{}, false
+	}
+	return *e, true
+}
+
+// SetRelation - Record how the user regards the player called name, who need
+// not have been seen yet
+func (r *Roster) SetRelation(name string, rel Relation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entry(name).Relation = rel
+}
+
+// SetNote - Record a note on the player called name, or clear it when note
+// is empty
+func (r *Roster) SetNote(name, note string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entry(name).Note = note
+}
+
+// Players - Every player in the roster, by name
+func (r *Roster) Players() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := make([]Entry, 0, len(r.players))
+	for _, e := range r.players {
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return key(entries[i].Name) < key(entries[j].Name) })
+	return entries
+}
+
+// Save - Write the roster to its file, replacing it atomically. A roster
+// made with New is not saved.
+func (r *Roster) Save() error {
+	if r.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(r.Players(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(r.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, ".roster")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), r.path)
+}
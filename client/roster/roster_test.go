@@ -0,0 +1,60 @@
+package roster_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/huntwj/gofugue/client/roster"
+	"github.com/huntwj/gofugue/wotmud/who"
+)
+
+func TestSeen(t *testing.T) {
+	t.Parallel()
+
+	r := roster.New()
+	first := time.Date(2017, 10, 22, 20, 0, 0, 0, time.UTC)
+	r.Seen([]who.Player{{Name: "Spruce", Title: "Spruce the Hundredman", Clans: []string{"Child of Light"}}}, first)
+	r.Seen([]who.Player{{Name: "Spruce", Title: "Spruce the Lieutenant", Flags: []string{"Idle"}}}, first.Add(time.Hour))
+
+	e, ok := r.Get("spruce")
+	if !ok {
+		t.Fatal("Expected Spruce in the roster")
+	}
+	if e.Title != "Spruce the Lieutenant" || e.Clans != nil {
+		t.Errorf("Expected the last listing to be kept but found %+v", e)
+	}
+	if !e.FirstSeen.Equal(first) || !e.LastSeen.Equal(first.Add(time.Hour)) {
+		t.Errorf("Unexpected times %v and %v", e.FirstSeen, e.LastSeen)
+	}
+}
+
+func TestSaveAndOpen(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "roster.json")
+	r, err := roster.Open(path)
+	if err != nil {
+		t.Fatalf("Could not open a new roster: %v", err)
+	}
+	seen := time.Date(2017, 10, 23, 12, 0, 0, 0, time.UTC)
+	r.Seen([]who.Player{{Name: "Etain", Title: "Etain a'Conn"}}, seen)
+	r.SetRelation("Etain", roster.Friend)
+	r.SetRelation("Dal", roster.Enemy)
+	r.SetNote("Dal", "ganked me at the docks")
+	if err := r.Save(); err != nil {
+		t.Fatalf("Could not save: %v", err)
+	}
+
+	reopened, err := roster.Open(path)
+	if err != nil {
+		t.Fatalf("Could not reopen: %v", err)
+	}
+	if !reflect.DeepEqual(reopened.Players(), r.Players()) {
+		t.Errorf("Expected %+v but found %+v", r.Players(), reopened.Players())
+	}
+	if e, _ := reopened.Get("dal"); e.Relation != roster.Enemy || e.Note != "ganked me at the docks" || !e.FirstSeen.IsZero() {
+		t.Errorf("Unexpected entry %+v", e)
+	}
+}
@@ -0,0 +1,234 @@
+package rpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Version is the JSON-RPC version spoken over the connection.
+const Version = "2.0"
+
+// maxMessageSize bounds a single message, which is one line of JSON.
+const maxMessageSize = 1 << 20
+
+// Error codes from the JSON-RPC 2.0 specification.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// ErrClosed - Returned by calls that were waiting when the connection closed
+var ErrClosed = errors.New("rpc connection closed")
+
+// A Message is one JSON-RPC 2.0 request, notification or response. Requests
+// carry an ID that the response repeats, notifications have no ID.
+type Message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// An Error is the error member of a failed response.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+// A Handler answers requests and notifications from the other side. For a
+// notification the result is discarded. Returning an *Error controls the
+// error code sent back; any other error is reported as an internal error.
+type Handler func(method string, params json.RawMessage) (interface{}, error)
+
+// Conn - One end of a connection carrying newline delimited JSON-RPC
+// messages. Both ends may send requests; responses are matched to their
+// requests by ID.
+type Conn struct {
+	r       io.Reader
+	handler Handler
+
+	wmu sync.Mutex
+	w   io.Writer
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan *Message
+	closed  bool
+}
+
+// NewConn - Create a connection reading messages from r and writing to w.
+// Incoming requests are passed to handler.
+func NewConn(r io.Reader, w io.Writer, handler Handler) *Conn {
+	return &Conn{
+		r:       r,
+		w:       w,
+		handler: handler,
+		pending: make(map[int64]chan *Message),
+	}
+}
+
+// Serve - Read and dispatch messages until the reader fails. Requests are
+// handled one at a time, in order, so a handler must not Call the other side
+// itself. Calls still waiting for a response when Serve returns fail with
+// ErrClosed.
+func (c *Conn) Serve() error {
+	defer c.close()
+
+	scanner := bufio.NewScanner(c.r)
+	scanner.Buffer(make([]byte, 4096), maxMessageSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg Message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			c.write(&Message{Error: &Error{CodeParseError, err.Error()}})
+			continue
+		}
+		c.dispatch(&msg)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+func (c *Conn) dispatch(msg *Message) {
+	if msg.Method == "" {
+		if msg.ID == nil {
+			return
+		}
+		c.mu.Lock()
+		ch, ok := c.pending[*msg.ID]
+		delete(c.pending, *msg.ID)
+		c.mu.Unlock()
+		if ok {
+			ch <- msg
+		}
+		return
+	}
+
+	var result interface{}
+	var err error
+	if c.handler == nil {
+		err = &Error{CodeMethodNotFound, "no methods available"}
+	} else {
+		result, err = c.handler(msg.Method, msg.Params)
+	}
+	if msg.ID == nil {
+		return
+	}
+
+	resp := &Message{ID: msg.ID}
+	if err != nil {
+		rpcErr, ok := err.(*Error)
+		if !ok {
+			rpcErr = &Error{CodeInternalError, err.Error()}
+		}
+		resp.Error = rpcErr
+	} else {
+		data, err := json.Marshal(result)
+		if err != nil {
+			resp.Error = &Error{CodeInternalError, err.Error()}
+		} else {
+			resp.Result = data
+		}
+	}
+	c.write(resp)
+}
+
+func (c *Conn) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+}
+
+func (c *Conn) write(msg *Message) error {
+	msg.JSONRPC = Version
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	_, err = c.w.Write(append(data, '\n'))
+	return err
+}
+
+func encodeParams(params interface{}) (json.RawMessage, error) {
+	if params == nil {
+		return nil, nil
+	}
+	return json.Marshal(params)
+}
+
+// Call - Send a request and wait for its response. The result is decoded into
+// result unless it is nil.
+func (c *Conn) Call(method string, params, result interface{}) error {
+	data, err := encodeParams(params)
+	if err != nil {
+		return err
+	}
+
+	ch := make(chan *Message, 1)
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	if err := c.write(&Message{ID: &id, Method: method, Params: data}); err != nil {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return err
+	}
+
+	resp, ok := <-ch
+	if !ok {
+		return ErrClosed
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result != nil && resp.Result != nil {
+		return json.Unmarshal(resp.Result, result)
+	}
+	return nil
+}
+
+// Notify - Send a notification, which gets no response
+func (c *Conn) Notify(method string, params interface{}) error {
+	data, err := encodeParams(params)
+	if err != nil {
+		return err
+	}
+	return c.write(&Message{Method: method, Params: data})
+}
@@ -0,0 +1,140 @@
+package rpc_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/huntwj/gofugue/client/rpc"
+)
+
+// pipePair connects two Conns to each other.
+func pipePair(a, b rpc.Handler) (*rpc.Conn, *rpc.Conn) {
+	ar, bw := io.Pipe()
+	br, aw := io.Pipe()
+	connA := rpc.NewConn(ar, aw, a)
+	connB := rpc.NewConn(br, bw, b)
+	go func() {
+		connA.Serve()
+		aw.Close()
+	}()
+	go func() {
+		connB.Serve()
+		bw.Close()
+	}()
+	return connA, connB
+}
+
+func TestCallAndResult(t *testing.T) {
+	t.Parallel()
+
+	_, client := pipePair(func(method string, params json.RawMessage) (interface{}, error) {
+		if method != "getVariable" {
+			return nil, &rpc.Error{Code: rpc.CodeMethodNotFound, Message: method}
+		}
+		var p struct{ Name string }
+		json.Unmarshal(params, &p)
+		return map[string]string{"value": "value of " + p.Name}, nil
+	}, nil)
+
+	var result struct{ Value string }
+	if err := client.Call("getVariable", map[string]string{"name": "target"}, &result); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Value != "value of target" {
+		t.Errorf("Unexpected result %q", result.Value)
+	}
+
+	err := client.Call("nosuch", nil, nil)
+	var rpcErr *rpc.Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != rpc.CodeMethodNotFound {
+		t.Errorf("Expected method not found but found %v", err)
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	t.Parallel()
+
+	_, client := pipePair(func(method string, params json.RawMessage) (interface{}, error) {
+		return nil, errors.New("not connected")
+	}, nil)
+
+	err := client.Call("send", nil, nil)
+	var rpcErr *rpc.Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != rpc.CodeInternalError || rpcErr.Message != "not connected" {
+		t.Errorf("Expected internal error but found %v", err)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan string, 1)
+	server, _ := pipePair(nil, func(method string, params json.RawMessage) (interface{}, error) {
+		received <- method + " " + string(params)
+		return nil, nil
+	})
+
+	if err := server.Notify("event", map[string]string{"type": "line"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if observed := <-received; observed != `event {"type":"line"}` {
+		t.Errorf("Unexpected notification %q", observed)
+	}
+}
+
+func TestWireFormat(t *testing.T) {
+	t.Parallel()
+
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":7,"method":"echo","params":{"text":"hi"}}` + "\n" +
+		`not json` + "\n" +
+		`{"jsonrpc":"2.0","method":"echo","params":{"text":"quiet"}}` + "\n")
+	r, w := io.Pipe()
+	conn := rpc.NewConn(in, w, func(method string, params json.RawMessage) (interface{}, error) {
+		return "ok", nil
+	})
+	go func() {
+		conn.Serve()
+		w.Close()
+	}()
+
+	scanner := bufio.NewScanner(r)
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	expected := []string{
+		`{"jsonrpc":"2.0","id":7,"result":"ok"}`,
+		`{"jsonrpc":"2.0","error":{"code":-32700,"message":"invalid character 'o' in literal null (expecting 'u')"}}`,
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d responses but found %q", len(expected), lines)
+	}
+	for idx := range expected {
+		if lines[idx] != expected[idx] {
+			t.Errorf("Expected %s but found %s", expected[idx], lines[idx])
+		}
+	}
+}
+
+func TestCallFailsWhenClosed(t *testing.T) {
+	t.Parallel()
+
+	r, w := io.Pipe()
+	conn := rpc.NewConn(strings.NewReader(""), w, nil)
+	go io.Copy(io.Discard, r)
+
+	done := make(chan error)
+	go func() {
+		done <- conn.Call("send", nil, nil)
+	}()
+	conn.Serve()
+
+	if err := <-done; err != rpc.ErrClosed {
+		t.Errorf("Expected ErrClosed but found %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/safety"
+)
+
+// The variables configuring the escape plan. EscapeHealthVar is the health
+// level, as the prompt shows it, at which to flee a fight; the plan is off
+// while it is unset. EscapeFleeVar is the command to flee with and
+// EscapeTriesVar how many times to try. SafeRoomsVar names the rooms,
+// separated by "|", to walk to once away, whether the plan or the game's
+// wimpy fled.
+const (
+	EscapeHealthVar = "escape_health"
+	EscapeFleeVar   = "escape_flee"
+	EscapeTriesVar  = "escape_tries"
+	SafeRoomsVar    = "safe_rooms"
+)
+
+// observeSafety feeds a line into the world's safety monitor and runs the
+// escape plan for the events it caused, firing DANGER when the player's
+// health falls to EscapeHealthVar in a fight and FLEE once the player fled.
+func (s *Session) observeSafety(line wotmud.Line) {
+	health, _ := s.client.Interp.Var(EscapeHealthVar)
+	s.Safety.SetThreshold(health)
+
+	for _, ev := range s.Safety.Observe(line) {
+		switch ev.Kind {
+		case safety.Danger:
+			s.client.message(s, "Warning: %s in a fight, fleeing.", strings.ToLower(ev.Health))
+			s.client.FireHook(HookDanger, s, ev.Health)
+		case safety.Fled:
+			if room := s.Mapper.Current(); room != nil {
+				s.client.FireHook(HookFlee, s, room.Name)
+			} else {
+				s.client.FireHook(HookFlee, s, "")
+			}
+		}
+
+		cmds, routed := s.escapeStep(ev)
+		if ev.Kind == safety.Fled && routed {
+			switch {
+			case cmds == nil:
+				s.client.message(s, "Warning: no known way to a safe room.")
+			case len(cmds) > 0:
+				s.client.message(s, "Escaping: %s", strings.Join(cmds, " "))
+			}
+		}
+		for _, cmd := range cmds {
+			s.Send(cmd)
+		}
+	}
+}
+
+// escapeStep configures the escape plan from its variables and takes the
+// step for an event, reporting whether safe rooms are set.
+func (s *Session) escapeStep(ev safety.Event) ([]string, bool) {
+	c := s.client
+	flee, _ := c.Interp.Var(EscapeFleeVar)
+	tries, _ := c.Interp.Var(EscapeTriesVar)
+	rooms, _ := c.Interp.Var(SafeRoomsVar)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.escape.Flee = flee
+	s.escape.Attempts, _ = strconv.Atoi(tries)
+	s.escape.Safe = nil
+	for _, name := range strings.Split(rooms, "|") {
+		if name = strings.TrimSpace(name); name != "" {
+			s.escape.Safe = append(s.escape.Safe, name)
+		}
+	}
+	return s.escape.Step(ev, s.Mapper), len(s.escape.Safe) > 0
+}
+
+// resolveSafety supplies mood and wimpy, the player's settings as the game
+// last showed them, and in_danger, whether the player's health is at
+// EscapeHealthVar in a fight.
+func resolveSafety(s *Session, name string) (string, bool) {
+	switch name {
+	case "mood":
+		return s.Safety.Mood(), true
+	case "wimpy":
+		return strconv.Itoa(s.Safety.Wimpy()), true
+	case "in_danger":
+		return boolValue(s.Safety.InDanger()), true
+	}
+	return "", false
+}
@@ -0,0 +1,292 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/huntwj/gofugue/client/trigger"
+	"github.com/huntwj/gofugue/tflang/interp"
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/inventory"
+	"github.com/huntwj/gofugue/wotmud/prompt"
+)
+
+// macroSet keeps the funcs removing the triggers and hooks of macros.
+type macroSet struct {
+	mu       sync.Mutex
+	removers map[*interp.Macro][]func()
+}
+
+// setupScripting connects the interpreter's macros to the client: their
+// triggers, hooks and the text they send.
+func (c *Client) setupScripting() {
+	c.Interp.Resolve = c.resolve
+	c.Interp.Send = c.sendFrame
+	c.Interp.Output = func(text string) {
+		c.Echo(nil, text)
+	}
+	c.Interp.OnDefine = c.defineMacro
+	c.Interp.OnUndefine = c.undefineMacro
+	c.Interp.RegisterFrame("send", c.cmdSend)
+}
+
+// frameSession finds the world a frame belongs to.
+func (c *Client) frameSession(f *interp.Frame) *Session {
+	if f.World != "" {
+		return c.Session(f.World)
+	}
+	return c.Foreground()
+}
+
+// sendFrame sends text to the world of a frame, unless a plugin has an alias
+// for it.
+func (c *Client) sendFrame(f *interp.Frame, text string) error {
+	s := c.frameSession(f)
+	if fn, args, ok := c.alias(text); ok {
+		return fn(s, args)
+	}
+	if s == nil {
+		return ErrNotConnected
+	}
+	return s.Send(text)
+}
+
+// cmdSend implements "/send [-w<world>] text".
+func (c *Client) cmdSend(f *interp.Frame, args string) error {
+	if strings.HasPrefix(args, "-w") {
+		world := args[2:]
+		args = ""
+		if idx := strings.IndexAny(world, " \t"); idx >= 0 {
+			world, args = world[:idx], strings.TrimLeft(world[idx+1:], " \t")
+		}
+		if world != "" {
+			if c.Session(world) == nil {
+				return errors.New(world + ": no such world")
+			}
+			f = &interp.Frame{World: world}
+		}
+	}
+	return c.sendFrame(f, args)
+}
+
+func boolValue(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// resolve supplies the variables describing the world of a frame, such as
+// world_name and the prompt_ and room_ variables. The resolveX helpers supply
+// those of the other trackers.
+func (c *Client) resolve(f *interp.Frame, name string) (string, bool) {
+	s := c.frameSession(f)
+	if s == nil {
+		return "", false
+	}
+
+	if name == "world_name" {
+		return s.World.Name, true
+	}
+	if value, ok := resolveClock(s, name); ok {
+		return value, true
+	}
+	if strings.HasPrefix(name, "room_") {
+		room := s.Mapper.Current()
+		if room == nil {
+			return "", false
+		}
+		switch name {
+		case "room_name":
+			return room.Name, true
+		case "room_exits":
+			return strings.Join(room.Exits, " "), true
+		}
+		return "", false
+	}
+
+	if strings.HasPrefix(name, "group_") {
+		return resolveGroup(s, name)
+	}
+	if name == "effects" {
+		return effectNames(s), true
+	}
+	if value, ok := resolveItems(s, name); ok {
+		return value, true
+	}
+	if value, ok := resolveMount(s, name); ok {
+		return value, true
+	}
+	if value, ok := resolveSurvival(s, name); ok {
+		return value, true
+	}
+	if value, ok := resolveSafety(s, name); ok {
+		return value, true
+	}
+
+	info := s.PromptInfo()
+	if !strings.HasPrefix(name, "prompt_") || info == nil {
+		return "", false
+	}
+	switch name {
+	case "prompt_health":
+		return info.Health, true
+	case "prompt_moves":
+		return info.Moves, true
+	case "prompt_spell":
+		if info.Spell == nil {
+			return "", true
+		}
+		return *info.Spell, true
+	case "prompt_lit":
+		return boolValue(info.IsLit), true
+	case "prompt_riding":
+		return boolValue(info.IsRiding), true
+	case "prompt_target", "prompt_target_health", "prompt_tank", "prompt_tank_health":
+		if info.Combat == nil {
+			return "", true
+		}
+		switch {
+		case name == "prompt_target":
+			return info.Combat.Target.Name, true
+		case name == "prompt_target_health":
+			return info.Combat.Target.Health, true
+		case info.Combat.Tank == nil:
+			return "", true
+		case name == "prompt_tank":
+			return info.Combat.Tank.Name, true
+		}
+		return info.Combat.Tank.Health, true
+	}
+	return "", false
+}
+
+// resolveGroup supplies group_members, the names of the members of the
+// player's group separated by commas, group_size, group_leader and
+// group_tank, the member tanking in the current fight.
+func resolveGroup(s *Session, name string) (string, bool) {
+	switch name {
+	case "group_members":
+		var names []string
+		for _, m := range s.Group.Members() {
+			names = append(names, m.Name)
+		}
+		return strings.Join(names, ", "), true
+	case "group_size":
+		return strconv.Itoa(len(s.Group.Members())), true
+	case "group_leader":
+		return s.Group.Leader(), true
+	case "group_tank":
+		var combat *prompt.Combat
+		if info := s.PromptInfo(); info != nil {
+			combat = info.Combat
+		}
+		tank, _ := s.Group.Tank(combat)
+		return tank.Name, true
+	}
+	return "", false
+}
+
+// resolveItems supplies inventory, the items carried separated by commas,
+// equipment, the items used, wielded, held and light, the items in those
+// slots, and liquid_KEYWORD, how full the item with that keyword was when it
+// was last looked in.
+func resolveItems(s *Session, name string) (string, bool) {
+	switch name {
+	case "inventory":
+		var items []string
+		for _, item := range s.Items.Carried() {
+			if item.Count > 1 {
+				items = append(items, fmt.Sprintf("[%d] %s", item.Count, item.Name))
+			} else {
+				items = append(items, item.Name)
+			}
+		}
+		return strings.Join(items, ", "), true
+	case "equipment":
+		var items []string
+		for _, worn := range s.Items.Used() {
+			items = append(items, worn.Item)
+		}
+		return strings.Join(items, ", "), true
+	case "wielded":
+		return strings.Join(append(s.Items.InSlot(inventory.Wielded), s.Items.InSlot(inventory.TwoHanded)...), ", "), true
+	case "held":
+		return strings.Join(s.Items.InSlot(inventory.Held), ", "), true
+	case "light":
+		return strings.Join(s.Items.InSlot(inventory.Light), ", "), true
+	}
+	if strings.HasPrefix(name, "liquid_") {
+		return s.Items.Liquid(strings.TrimPrefix(name, "liquid_")), true
+	}
+	return "", false
+}
+
+// runMacro runs a macro for a trigger or hook of the world s, reporting
+// errors to the user.
+func (c *Client) runMacro(m *interp.Macro, f *interp.Frame) {
+	if err := c.Interp.Call(m, f); err != nil {
+		c.message(c.Session(f.World), "/%s: %v", m.Name, err)
+	}
+}
+
+func (c *Client) defineMacro(m *interp.Macro) error {
+	inWorld := func(s *Session) bool {
+		return m.World == "" || strings.EqualFold(m.World, s.World.Name)
+	}
+
+	var removers []func()
+	gag, hilite, err := interp.AttrCodes(m.Attr)
+	if err != nil {
+		return err
+	}
+	if m.Trigger != nil && (gag || hilite != "" || m.Subst != "") {
+		display := trigger.Display{Gag: gag, Hilite: hilite, Subst: m.Subst}
+		removers = append(removers, c.AddDisplay("display:"+m.Name, m.World, m.Trigger, display))
+	}
+	if m.Trigger != nil && (m.Body != "" || m.Attr == "" && m.Subst == "") {
+		removers = append(removers, c.AddTrigger("macro:"+m.Name, m.Trigger, func(s *Session, line wotmud.Line, match []string) {
+			if !inWorld(s) {
+				return
+			}
+			f := &interp.Frame{World: s.World.Name}
+			interp.SetMatch(f, match)
+			c.runMacro(m, f)
+		}))
+	}
+	if m.Hook != "" {
+		removers = append(removers, c.AddHook(m.Hook, func(s *Session, args string) {
+			f := &interp.Frame{Args: args}
+			if s != nil {
+				if !inWorld(s) {
+					return
+				}
+				f.World = s.World.Name
+			}
+			c.runMacro(m, f)
+		}))
+	}
+
+	c.macros.mu.Lock()
+	defer c.macros.mu.Unlock()
+
+	if c.macros.removers == nil {
+		c.macros.removers = make(map[*interp.Macro][]func())
+	}
+	c.macros.removers[m] = removers
+	return nil
+}
+
+func (c *Client) undefineMacro(m *interp.Macro) {
+	c.macros.mu.Lock()
+	removers := c.macros.removers[m]
+	delete(c.macros.removers, m)
+	c.macros.mu.Unlock()
+
+	for _, remove := range removers {
+		remove()
+	}
+}
@@ -0,0 +1,501 @@
+package client_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/huntwj/gofugue/client"
+)
+
+func TestScriptedTriggersAndHooks(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	c := client.New()
+	defer c.Quit()
+	go func() {
+		for range c.Events() {
+		}
+	}()
+
+	script := []string{
+		server.addWorldCommand("Freddie"),
+		`/def -t"^(\w+) tells you '(.*)'$" reply = tell %P1 %{prompt_health} at %{room_name}`,
+		`/def -hROOM -wFreddie explore = /if (room_exits =/ "*E*") /send east %; /else /send -wFreddie look %; /endif`,
+		`/def -wTalia -t"." nope = /send never`,
+	}
+	for _, cmd := range script {
+		if err := c.Interp.Eval(cmd); err != nil {
+			t.Fatalf("Unexpected error for %q: %v", cmd, err)
+		}
+	}
+	s, err := c.Connect("Freddie")
+	if err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	conn := server.accept(t)
+	if s.Triggers.Len() != 2 {
+		t.Errorf("Expected macro triggers on a new world but found %d", s.Triggers.Len())
+	}
+
+	conn.Write([]byte("\x1b[36mThe White Crescent\x1b[0m\r\nA quiet room.\r\n[ obvious exits: N S ]\r\n"))
+	server.expectReceived(t, "look")
+	conn.Write([]byte("* HP:Wounded MV:Full > "))
+	conn.Write([]byte("\r\nTalia tells you 'where are you?'\r\n"))
+	server.expectReceived(t, "tell Talia Wounded at The White Crescent")
+	conn.Write([]byte("\x1b[36mA Wide Paved Street\x1b[0m\r\n[ obvious exits: E W ]\r\n"))
+	server.expectReceived(t, "east")
+
+	if err := c.Interp.Eval("/undef reply"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if s.Triggers.Len() != 1 {
+		t.Errorf("Expected the trigger to be removed with its macro but found %d", s.Triggers.Len())
+	}
+}
+
+func TestInputAliases(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	c := client.New()
+	defer c.Quit()
+	go func() {
+		for range c.Events() {
+		}
+	}()
+
+	for _, cmd := range []string{server.addWorldCommand("Freddie"), "/alias k kill %1%; bs %1", "/def bs = backstab %{1-someone}"} {
+		if err := c.Interp.Eval(cmd); err != nil {
+			t.Fatalf("Unexpected error for %q: %v", cmd, err)
+		}
+	}
+	if _, err := c.Connect("Freddie"); err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	server.accept(t)
+
+	c.Input("k trolloc")
+	server.expectReceived(t, "kill trolloc")
+	server.expectReceived(t, "backstab trolloc")
+	c.Input("bs")
+	server.expectReceived(t, "backstab someone")
+	c.Input("kiss talia")
+	server.expectReceived(t, "kiss talia")
+}
+
+func TestGagHiliteSubstitute(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	c := client.New()
+	defer c.Quit()
+
+	script := []string{
+		server.addWorldCommand("Freddie"),
+		`/gag ^The ancient tree tries to hit you, but you parry successfully\.$`,
+		"/hilite -aBCred hits your body hard",
+		"/substitute -msimple You receive your share of experience... = [exp]",
+		"/gag -wTalia .",
+	}
+	for _, cmd := range script {
+		if err := c.Interp.Eval(cmd); err != nil {
+			t.Fatalf("Unexpected error for %q: %v", cmd, err)
+		}
+	}
+	s, err := c.Connect("Freddie")
+	if err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	conn := server.accept(t)
+
+	conn.Write([]byte("The \x1b[33mancient tree\x1b[0m tries to hit you, but you parry successfully.\r\n" +
+		"The ancient tree hits your body hard.\r\n" +
+		"You receive your share of experience...\r\n"))
+
+	ev := waitEvent(t, c, client.LineEvent)
+	if !ev.Gagged || ev.Line.Raw != "The \x1b[33mancient tree\x1b[0m tries to hit you, but you parry successfully." {
+		t.Errorf("Expected the parry to be gagged but found %+v", ev)
+	}
+	ev = waitEvent(t, c, client.LineEvent)
+	if ev.Gagged || ev.Text != "The ancient tree \x1b[1;31mhits your body hard\x1b[0m." {
+		t.Errorf("Expected a highlight but found %q", ev.Text)
+	}
+	ev = waitEvent(t, c, client.LineEvent)
+	if ev.Text != "[exp]" || ev.Line.Raw != "You receive your share of experience..." {
+		t.Errorf("Expected a substitution but found %q", ev.Text)
+	}
+
+	if history := s.History(10); len(history) != 2 || history[1].Raw != "[exp]" {
+		t.Errorf("Expected history to hold the lines as shown but found %v", history)
+	}
+}
+
+func TestCombatMessages(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	c := client.New()
+	defer c.Quit()
+	c.Input(server.addWorldCommand("Freddie"))
+	if err := c.Interp.Eval(`/def -hCOMBAT fight = /send say %*`); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	s, err := c.Connect("Freddie")
+	if err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	conn := server.accept(t)
+
+	conn.Write([]byte("* R HP:Healthy MV:Fresh - the ancient tree: Hurt > \x1b[32mYou slash the ancient tree's trunk hard.\x1b[0m\r\n"))
+	ev := waitEvent(t, c, client.CombatEvent)
+	if ev.Attack == nil || ev.Attack.Defender != "the ancient tree" || ev.Attack.BodyPart != "trunk" {
+		t.Errorf("Expected a hit on the ancient tree but found %+v", ev.Attack)
+	}
+	server.expectReceived(t, "say You slash the ancient tree's trunk hard.")
+
+	state := s.Combat.State()
+	if state.Combat == nil || state.Combat.Target.Name != "the ancient tree" {
+		t.Errorf("Expected the fight to be tracked but found %+v", state)
+	}
+}
+
+func TestCommAndReply(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	c := client.New()
+	defer c.Quit()
+	c.Input(server.addWorldCommand("Freddie"))
+	if _, err := c.Connect("Freddie"); err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	conn := server.accept(t)
+
+	if err := c.Interp.Eval("/reply hi"); err == nil {
+		t.Error("Expected an error replying before any tell")
+	}
+
+	conn.Write([]byte("\x1b[33mErulisse narrates 'seanchan altara'\x1b[0m\r\nDal tells you 'where are you?'\r\nGudha tells you 'I haven't a use for those.'\r\n"))
+	ev := waitEvent(t, c, client.CommEvent)
+	if ev.Comm == nil || ev.Comm.Speaker != "Erulisse" || ev.Text != "\x1b[33mErulisse narrates 'seanchan altara'\x1b[0m" {
+		t.Errorf("Unexpected narrate %+v %q", ev.Comm, ev.Text)
+	}
+	for _, speaker := range []string{"Dal", "Gudha"} {
+		if ev = waitEvent(t, c, client.CommEvent); ev.Comm == nil || ev.Comm.Speaker != speaker || !ev.Comm.ToYou() {
+			t.Errorf("Unexpected tell %+v", ev.Comm)
+		}
+	}
+
+	// Dal has not been seen on a who list, and Gudha is a shopkeeper.
+	c.Input("/reply by the ford")
+	server.expectReceived(t, "tell Dal by the ford")
+	if c.Comm.Len() != 3 {
+		t.Errorf("Expected three messages in the history but found %d", c.Comm.Len())
+	}
+
+	if err := c.Interp.Eval("/comm narrates"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ev := waitEvent(t, c, client.MessageEvent); ev.Text != "[Freddie] Erulisse narrates 'seanchan altara'" {
+		t.Errorf("Unexpected /comm output %q", ev.Text)
+	}
+}
+
+func TestGroupVariables(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	c := client.New()
+	defer c.Quit()
+	go func() {
+		for range c.Events() {
+		}
+	}()
+	c.Input(server.addWorldCommand("Freddie"))
+	if err := c.Interp.Eval(`/def -hGROUP regroup = /send gt %{group_size} with %{group_leader}: %*`); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	s, err := c.Connect("Freddie")
+	if err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	conn := server.accept(t)
+
+	conn.Write([]byte("Your group consists of:\r\nFreddie (Head of group)\r\nDal\r\n\r\n"))
+	server.expectReceived(t, "gt 2 with Freddie: Freddie, Dal")
+	conn.Write([]byte("A warhorse is now a member of your group.\r\n"))
+	server.expectReceived(t, "gt 3 with Freddie: Freddie, Dal, A warhorse")
+
+	conn.Write([]byte("* HP:Healthy MV:Fresh - Dal: Hurt - the ancient tree: Wounded > "))
+	for s.PromptInfo() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Input("/def tank = /send tank %{group_tank}")
+	c.Input("/tank")
+	server.expectReceived(t, "tank Dal")
+}
+
+func TestEffectsExpire(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	c := client.New()
+	defer c.Quit()
+	go func() {
+		for range c.Events() {
+		}
+	}()
+	c.Input(server.addWorldCommand("Freddie"))
+	if err := c.Interp.Eval(`/def -hEXPIRE expired = /send say %* is gone, %{effects-nothing} left`); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := c.Connect("Freddie"); err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	conn := server.accept(t)
+
+	conn.Write([]byte("You are subjected to the following effects:\r\n- NO QUIT \r\n- NOTICE \r\n\r\n"))
+	conn.Write([]byte("You are subjected to the following effects:\r\n- NOTICE \r\n\r\n"))
+	server.expectReceived(t, "say NO QUIT is gone, NOTICE left")
+}
+
+func TestEffectMessages(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	c := client.New()
+	defer c.Quit()
+	go func() {
+		for range c.Events() {
+		}
+	}()
+	c.Input(server.addWorldCommand("Freddie"))
+	for _, cmd := range []string{
+		`/effect -s'^You feel more alert\.$' -e'^You feel less alert\.$' NOTICE`,
+		`/def -hEXPIRE expired = /send say %* is gone`,
+	} {
+		if err := c.Interp.Eval(cmd); err != nil {
+			t.Fatalf("Unexpected error for %q: %v", cmd, err)
+		}
+	}
+	if err := c.Interp.Eval("/effect NOTICE"); err == nil {
+		t.Error("Expected an error for an effect without messages")
+	}
+	s, err := c.Connect("Freddie")
+	if err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	conn := server.accept(t)
+
+	conn.Write([]byte("You feel more alert.\r\n* HP:Healthy MV:Fresh > "))
+	time.Sleep(50 * time.Millisecond)
+	if active := s.Effects.Active(); len(active) != 1 || active[0].Name != "NOTICE" {
+		t.Errorf("Expected NOTICE to be active but found %v", active)
+	}
+	conn.Write([]byte("You feel less alert.\r\n* HP:Healthy MV:Fresh > "))
+	server.expectReceived(t, "say NOTICE is gone")
+}
+
+func TestItemVariables(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	c := client.New()
+	defer c.Quit()
+	go func() {
+		for range c.Events() {
+		}
+	}()
+	c.Input(server.addWorldCommand("Freddie"))
+	if err := c.Interp.Eval(`/def -t"^It's empty\.$" refill = /if (liquid_flask =~ "empty") fill flask %; /endif`); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := c.Connect("Freddie"); err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	conn := server.accept(t)
+
+	c.Input("look in flask")
+	server.expectReceived(t, "look in flask")
+	conn.Write([]byte("It's empty.\r\n"))
+	server.expectReceived(t, "fill flask")
+
+	c.Input(`/def -t"^You wield" items = /send %{wielded} and %{inventory}`)
+	conn.Write([]byte("You are carrying:\r\n[2] a lantern\r\na rapier\r\n\r\nYou wield a rapier.\r\n"))
+	server.expectReceived(t, "a rapier and [2] a lantern")
+}
+
+func TestMountWarnings(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	c := client.New()
+	defer c.Quit()
+	c.Input(server.addWorldCommand("Freddie"))
+	if err := c.Interp.Eval(`/def -hDISMOUNT dismounted = /send say off %{mount}, led %{mount_led}`); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := c.Connect("Freddie"); err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	conn := server.accept(t)
+
+	conn.Write([]byte("\x1b[36mThe Road\x1b[0m\r\nA road.\r\n[ obvious exits: E W ]\r\n"))
+	conn.Write([]byte("A warhorse stops following you.\r\nYou start riding him.\r\n* R HP:Healthy MV:Fresh > "))
+	conn.Write([]byte("You stop riding him.\r\n* HP:Healthy MV:Fresh > "))
+	server.expectReceived(t, "say off A warhorse, led 0")
+
+	conn.Write([]byte("\x1b[36mThe Inn\x1b[0m\r\nAn inn.\r\n[ obvious exits: W ]\r\n\r\n* HP:Healthy MV:Fresh > "))
+	waitMessages(t, c, "% Warning: you moved away without a warhorse.")
+}
+
+func TestLightHooks(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	c := client.New()
+	defer c.Quit()
+	go func() {
+		for range c.Events() {
+		}
+	}()
+	c.Input(server.addWorldCommand("Freddie"))
+	for _, cmd := range []string{
+		`/def -hDARK torch = hold torch`,
+		`/def -hSUN sun = /send say %* at %{game_hour}, dark %{room_dark}`,
+	} {
+		if err := c.Interp.Eval(cmd); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	s, err := c.Connect("Freddie")
+	if err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	conn := server.accept(t)
+
+	conn.Write([]byte("* HP:Healthy MV:Fresh > The night has begun.\r\nIt is pitch black...\r\n\r\no HP:Healthy MV:Fresh > "))
+	server.expectReceived(t, "say night at 22, dark 0")
+	server.expectReceived(t, "hold torch")
+	if status := s.Status(); len(status) != 2 || status[0] != "22:00 night" || status[1] != "dark" {
+		t.Errorf("Unexpected status %v", status)
+	}
+}
+
+func TestAutoEat(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	c := client.New()
+	defer c.Quit()
+	go func() {
+		for range c.Events() {
+		}
+	}()
+	c.Input(server.addWorldCommand("Freddie"))
+	c.Input("/set auto_eat=1")
+	if _, err := c.Connect("Freddie"); err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	conn := server.accept(t)
+
+	conn.Write([]byte("You are carrying:\r\na backpack\r\n\r\n* HP:Healthy MV:Fresh > "))
+	conn.Write([]byte("a backpack (carried) :\r\na large slab of meat\r\n\r\n* HP:Healthy MV:Fresh > "))
+	conn.Write([]byte("You are hungry.\r\n* HP:Healthy MV:Fresh - Freddie: Wounded - a rat: Hurt > "))
+	conn.Write([]byte("You are hungry.\r\n* HP:Healthy MV:Fresh > "))
+	server.expectReceived(t, "get meat backpack")
+	server.expectReceived(t, "eat meat")
+}
+
+func TestEscapePlan(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	c := client.New()
+	defer c.Quit()
+	c.Input(server.addWorldCommand("Freddie"))
+	c.Input("/set escape_health=Wounded")
+	c.Input("/set safe_rooms=The Inn|The Temple")
+	if _, err := c.Connect("Freddie"); err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	conn := server.accept(t)
+
+	room := func(name, exits string) string {
+		return "\x1b[36m" + name + "\x1b[0m\r\nA room called " + name + ".\r\n[ obvious exits: " + exits + " ]\r\n\r\n* HP:Healthy MV:Fresh > "
+	}
+	conn.Write([]byte(room("The Inn", "E")))
+	for _, name := range []string{"The Square", "The Road"} {
+		c.Input("e")
+		server.expectReceived(t, "e")
+		conn.Write([]byte(room(name, "E W")))
+	}
+
+	conn.Write([]byte("A rat bites you very hard.\r\n* HP:Wounded MV:Fresh - a rat: Healthy > "))
+	server.expectReceived(t, "flee")
+	conn.Write([]byte("You flee head over heels.\r\n" + room("The Square", "E W")))
+	server.expectReceived(t, "w")
+	waitMessages(t, c, "% Warning: wounded in a fight, fleeing.", "% Escaping: w")
+}
+
+func TestRosterColors(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	c := client.New()
+	defer c.Quit()
+	c.Input(server.addWorldCommand("Freddie"))
+	c.Input("/friend Erulisse")
+	c.Input("/enemy Dal")
+	c.Input("/set enemy_attr=Cyellow")
+	c.Input("/hilite -aCred seanchan")
+	if _, err := c.Connect("Freddie"); err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	conn := server.accept(t)
+
+	conn.Write([]byte("* HP:Healthy MV:Full >   Players\r\n  -------\r\n   Erulisse the Tower Accepted  [White Tower]\r\n   Dal the Sun Captain  [Rising Sun]\r\n\r\n  2 players displayed.\r\n"))
+	conn.Write([]byte("\x1b[33mErulisse narrates 'seanchan altara'\x1b[0m\r\nDal chats 'where are you?'\r\nSpruce chats 'hi'\r\n"))
+	for _, expected := range []string{
+		"\x1b[1;32m\x1b[33mErulisse narrates '\x1b[31mseanchan\x1b[0m\x1b[1;32m altara'\x1b[0m",
+		"\x1b[33mDal chats 'where are you?'\x1b[0m",
+		"Spruce chats 'hi'",
+	} {
+		if ev := waitEvent(t, c, client.CommEvent); ev.Text != expected {
+			t.Errorf("Expected %q but found %q", expected, ev.Text)
+		}
+	}
+
+	if e, ok := c.Roster.Get("Dal"); !ok || e.Title != "Dal the Sun Captain" || e.LastSeen.IsZero() {
+		t.Errorf("Unexpected roster entry %+v", e)
+	}
+}
@@ -0,0 +1,269 @@
+// Package scrollback keeps the lines shown by the user interface so they can
+// be paged through and searched, with a bounded amount of memory.
+package scrollback
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/huntwj/gofugue/wotmud"
+)
+
+// DefaultCapacity is how many lines a Buffer keeps by default.
+const DefaultCapacity = 10000
+
+// DefaultHeight is the page size used when the screen size is not known.
+const DefaultHeight = 23
+
+// Highlighting of search matches, in reverse video.
+const (
+	highlightOn  = "\x1b[7m"
+	highlightOff = "\x1b[27m"
+)
+
+// Buffer - A bounded store of styled lines with a view onto them. The view
+// follows new lines until it is scrolled back, after which it stays frozen
+// while lines keep arriving. With More set, the view also pauses once a page
+// of lines has arrived since the user last acknowledged the output, like the
+// --More-- prompt of TinyFugue. The oldest lines are dropped once the buffer
+// is full. A Buffer is safe for concurrent use.
+type Buffer struct {
+	mu       sync.Mutex
+	lines    []string // ring of stored lines
+	first    int      // index in lines of the oldest line
+	count    int      // number of lines stored
+	total    int      // number of lines ever added
+	height   int
+	more     bool
+	bottom   int // absolute index just past the last line in the view
+	mark     int // absolute index of the first line not acknowledged
+	search   int // absolute index searches continue above
+	pattern  *regexp.Regexp
+	capacity int
+}
+
+// New - Create a buffer keeping capacity lines with pages of height lines
+func New(capacity, height int) *Buffer {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	if height <= 0 {
+		height = DefaultHeight
+	}
+	return &Buffer{
+		lines:    make([]string, capacity),
+		height:   height,
+		capacity: capacity,
+	}
+}
+
+// SetHeight - Change the page size, e.g. when the screen is resized
+func (b *Buffer) SetHeight(height int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if height > 0 {
+		b.height = height
+	}
+}
+
+// SetMore - Turn pausing after each page of output on or off. Turning it off
+// resumes a paused view.
+func (b *Buffer) SetMore(more bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !more && b.paused() {
+		b.bottom = b.total
+	}
+	b.more = more
+	b.mark = b.bottom
+}
+
+// More - Whether output pauses after each page
+func (b *Buffer) More() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.more
+}
+
+// Add - Store a line, reporting whether it is visible at once because the
+// view is following new lines
+func (b *Buffer) Add(line string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	live := b.bottom == b.total
+	if b.count < b.capacity {
+		b.lines[(b.first+b.count)%b.capacity] = line
+		b.count++
+	} else {
+		b.lines[b.first] = line
+		b.first = (b.first + 1) % b.capacity
+	}
+	b.total++
+
+	if live && b.more && b.total-b.mark > b.height {
+		live = false
+	}
+	if live {
+		b.bottom = b.total
+	}
+	if oldest := b.oldest(); b.bottom < oldest+1 {
+		// The view has scrolled off the top of the buffer.
+		b.bottom = min(oldest+b.height, b.total)
+	}
+	return live
+}
+
+func (b *Buffer) oldest() int {
+	return b.total - b.count
+}
+
+func (b *Buffer) line(idx int) string {
+	return b.lines[(b.first+idx-b.oldest())%b.capacity]
+}
+
+// Len - The number of lines stored
+func (b *Buffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.count
+}
+
+// Live - Whether the view is following new lines
+func (b *Buffer) Live() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.bottom == b.total
+}
+
+func (b *Buffer) paused() bool {
+	return b.more && b.bottom < b.total && b.bottom >= b.mark+b.height
+}
+
+// Ack - Note that the user has seen the output so far, so that pausing
+// counts a page from here
+func (b *Buffer) Ack() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.bottom == b.total {
+		b.mark = b.total
+	}
+}
+
+// Status - A marker describing a view that is not following new lines:
+// --More-- while paused and the number of lines below a frozen view.
+func (b *Buffer) Status() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.bottom == b.total:
+		return ""
+	case b.paused():
+		return "--More--"
+	}
+	return fmt.Sprintf("--Scrolled: %d more--", b.total-b.bottom)
+}
+
+// PageUp - Scroll the view back a page, freezing it
+func (b *Buffer) PageUp() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bottom = max(b.bottom-b.height, min(b.oldest()+b.height, b.total))
+	b.search = b.bottom
+}
+
+// PageDown - Scroll the view forward a page. Reaching the newest line makes
+// the view follow new lines again.
+func (b *Buffer) PageDown() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bottom = min(b.bottom+b.height, b.total)
+	b.mark = b.bottom - b.height
+	b.search = b.bottom
+}
+
+// Flush - Jump to the newest lines and follow them again
+func (b *Buffer) Flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bottom, b.mark, b.search = b.total, b.total, b.total
+}
+
+// View - The lines in the view, oldest first, with matches of the last
+// search highlighted
+func (b *Buffer) View() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var view []string
+	for idx := max(b.bottom-b.height, b.oldest()); idx < b.bottom; idx++ {
+		line := b.line(idx)
+		if b.pattern != nil {
+			line = highlight(line, b.pattern)
+		}
+		view = append(view, line)
+	}
+	return view
+}
+
+// Search - Scroll back to the closest line above the last match, or above the
+// view, whose text without ANSI codes matches re, putting it at the bottom of
+// the view. Matches stay highlighted until the next search; a nil re clears
+// them. It reports whether a line was found.
+func (b *Buffer) Search(re *regexp.Regexp) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.pattern == nil || re == nil || b.pattern.String() != re.String() {
+		b.search = b.bottom
+	}
+	b.pattern = re
+	if re == nil {
+		return false
+	}
+
+	for idx := min(b.search, b.total) - 1; idx >= b.oldest(); idx-- {
+		if re.MatchString(wotmud.StripANSI(b.line(idx))) {
+			b.bottom, b.search = idx+1, idx
+			return true
+		}
+	}
+	return false
+}
+
+// highlight shows the matches of re in the text of line in reverse video.
+func highlight(line string, re *regexp.Regexp) string {
+	plain, offsets := wotmud.StripANSIOffsets(line)
+	matches := re.FindAllStringIndex(plain, -1)
+	if len(matches) == 0 {
+		return line
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		if m[0] == m[1] {
+			continue
+		}
+		start, end := offsets[m[0]], offsets[m[1]-1]+1
+		b.WriteString(line[last:start])
+		b.WriteString(highlightOn)
+		b.WriteString(line[start:end])
+		b.WriteString(highlightOff)
+		last = end
+	}
+	b.WriteString(line[last:])
+	return b.String()
+}
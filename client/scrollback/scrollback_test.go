@@ -0,0 +1,124 @@
+package scrollback_test
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/huntwj/gofugue/client/scrollback"
+)
+
+func add(b *scrollback.Buffer, from, to int) {
+	for n := from; n <= to; n++ {
+		b.Add(fmt.Sprintf("line %d", n))
+	}
+}
+
+func expectView(t *testing.T, b *scrollback.Buffer, expected ...string) {
+	t.Helper()
+
+	if view := b.View(); strings.Join(view, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected view %q but found %q", expected, view)
+	}
+}
+
+func TestBounded(t *testing.T) {
+	b := scrollback.New(5, 3)
+	for n := 1; n <= 10000; n++ {
+		if !b.Add(fmt.Sprintf("The ancient tree tries to hit you, but you parry successfully. %d", n)) {
+			t.Fatal("Expected a live view to show every line")
+		}
+	}
+	if b.Len() != 5 {
+		t.Errorf("Expected 5 lines kept but found %d", b.Len())
+	}
+
+	b.PageUp()
+	b.PageUp()
+	b.PageUp()
+	if view := b.View(); len(view) != 3 || !strings.HasSuffix(view[0], " 9996") {
+		t.Errorf("Expected paging to stop at the oldest line but found %q", view)
+	}
+}
+
+func TestFreezeOnScroll(t *testing.T) {
+	b := scrollback.New(100, 3)
+	add(b, 1, 10)
+	b.PageUp()
+	expectView(t, b, "line 5", "line 6", "line 7")
+
+	if b.Add("line 11") {
+		t.Error("Expected new lines to wait while scrolled back")
+	}
+	expectView(t, b, "line 5", "line 6", "line 7")
+	if status := b.Status(); status != "--Scrolled: 4 more--" {
+		t.Errorf("Unexpected status %q", status)
+	}
+
+	b.PageDown()
+	expectView(t, b, "line 8", "line 9", "line 10")
+	b.PageDown()
+	if !b.Live() || b.Status() != "" {
+		t.Error("Expected the view to follow output again at the bottom")
+	}
+	if !b.Add("line 12") {
+		t.Error("Expected a live view to show new lines")
+	}
+	expectView(t, b, "line 10", "line 11", "line 12")
+}
+
+func TestMore(t *testing.T) {
+	b := scrollback.New(100, 3)
+	b.SetMore(true)
+	add(b, 1, 3)
+	if b.Add("line 4") {
+		t.Error("Expected output to pause after a page")
+	}
+	add(b, 5, 6)
+	if status := b.Status(); status != "--More--" {
+		t.Errorf("Expected --More-- but found %q", status)
+	}
+	expectView(t, b, "line 1", "line 2", "line 3")
+
+	b.PageDown()
+	expectView(t, b, "line 4", "line 5", "line 6")
+	if !b.Live() {
+		t.Error("Expected all output to be shown")
+	}
+	b.Ack()
+	add(b, 7, 9)
+	if b.Add("line 10") || b.Status() != "--More--" {
+		t.Error("Expected to pause again a page after the acknowledgment")
+	}
+
+	b.SetMore(false)
+	if !b.Live() {
+		t.Error("Expected turning more off to resume output")
+	}
+}
+
+func TestSearch(t *testing.T) {
+	b := scrollback.New(100, 2)
+	b.Add("The \x1b[31mancient tree\x1b[0m hits your body hard.")
+	b.Add("You parry.")
+	b.Add("An ancient tree hits your body hard.")
+	add(b, 1, 5)
+
+	re := regexp.MustCompile("ancient tree hits")
+	if !b.Search(re) {
+		t.Fatal("Expected to find a match")
+	}
+	expectView(t, b, "You parry.", "An \x1b[7mancient tree hits\x1b[27m your body hard.")
+	if !b.Search(re) {
+		t.Fatal("Expected to find a match through ANSI codes")
+	}
+	expectView(t, b, "The \x1b[31m\x1b[7mancient tree\x1b[0m hits\x1b[27m your body hard.")
+	if b.Search(re) {
+		t.Error("Expected no more matches")
+	}
+
+	b.Search(nil)
+	b.Flush()
+	expectView(t, b, "line 4", "line 5")
+}
@@ -0,0 +1,489 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/huntwj/gofugue/client/clog"
+	"github.com/huntwj/gofugue/client/login"
+	"github.com/huntwj/gofugue/client/telnet"
+	"github.com/huntwj/gofugue/client/trigger"
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/clock"
+	"github.com/huntwj/gofugue/wotmud/combat"
+	"github.com/huntwj/gofugue/wotmud/comm"
+	"github.com/huntwj/gofugue/wotmud/effects"
+	"github.com/huntwj/gofugue/wotmud/group"
+	"github.com/huntwj/gofugue/wotmud/inventory"
+	"github.com/huntwj/gofugue/wotmud/mapper"
+	"github.com/huntwj/gofugue/wotmud/mount"
+	"github.com/huntwj/gofugue/wotmud/prompt"
+	"github.com/huntwj/gofugue/wotmud/safety"
+	"github.com/huntwj/gofugue/wotmud/survival"
+	"github.com/huntwj/gofugue/wotmud/who"
+)
+
+// ErrNotConnected - Returned when sending to a world that has no connection
+var ErrNotConnected = errors.New("not connected")
+
+// ErrClosed - Returned when connecting to a world that was closed while the
+// connection was being opened
+var ErrClosed = errors.New("closed while connecting")
+
+// DialTimeout is how long to wait for a world to accept a connection.
+const DialTimeout = 15 * time.Second
+
+// historySize is the number of lines kept for each world.
+const historySize = 1000
+
+// A ReconnectPolicy controls how the client reconnects to a world after the
+// connection is lost. The delay before each attempt doubles from Initial up
+// to Max. It only starts over from Initial once a connection has lasted for
+// Stable, so a server that keeps dropping the connection is not hammered.
+// A zero Initial delay disables reconnecting.
+type ReconnectPolicy struct {
+	Initial time.Duration
+	Max     time.Duration
+	Stable  time.Duration
+}
+
+// DefaultReconnect is the ReconnectPolicy of a new Client.
+var DefaultReconnect = ReconnectPolicy{
+	Initial: time.Second,
+	Max:     2 * time.Minute,
+	Stable:  time.Minute,
+}
+
+// A Session holds everything the client knows about one world: its
+// connection, recent output, the last prompt and the trackers fed from its
+// output. Sessions outlive their connections, so reconnecting to a world
+// picks up where it left off.
+type Session struct {
+	World    *World
+	Mapper   *mapper.Mapper
+	Combat   *combat.Tracker
+	Group    *group.Group
+	Effects  *effects.Tracker
+	Items    *inventory.Inventory
+	Mount    *mount.Tracker
+	Clock    *clock.Clock
+	Survival *survival.Tracker
+	Safety   *safety.Monitor
+	Triggers *trigger.Set
+
+	client    *Client
+	autoLogin *login.AutoLogin
+
+	mu      sync.Mutex
+	conn    net.Conn
+	telnet  *telnet.Conn
+	log     *clog.Writer
+	prompt  wotmud.Line
+	info    *prompt.Info
+	history []wotmud.Line
+	replyTo string
+	expiry  *time.Timer // runs when the next effect is estimated to end
+	sentAt  time.Time   // when a command was last sent
+	escape  safety.Escape
+	who     *who.Parser
+
+	closing     bool
+	dialing     bool
+	connectedAt time.Time
+	delay       time.Duration
+	cancel      chan struct{}
+}
+
+func newSession(c *Client, w *World) *Session {
+	s := &Session{
+		World:    w,
+		Mapper:   mapper.New(),
+		Combat:   combat.New(),
+		Group:    group.New(),
+		Effects:  effects.New(),
+		Items:    inventory.New(),
+		Mount:    mount.New(),
+		Clock:    clock.New(),
+		Survival: survival.New(),
+		Safety:   safety.New(),
+		Triggers: trigger.NewSet(),
+		client:   c,
+		who:      who.New(),
+	}
+	c.installTriggers(s)
+	c.installEffects(s)
+	return s
+}
+
+// Connected reports whether the session has an open connection.
+func (s *Session) Connected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.conn != nil
+}
+
+// connect opens the connection to the world and starts reading from it.
+func (s *Session) connect() error {
+	addr := net.JoinHostPort(s.World.Host, strconv.Itoa(s.World.Port))
+	s.mu.Lock()
+	s.closing = false
+	s.dialing = true
+	s.mu.Unlock()
+
+	conn, err := s.client.dial("tcp", addr)
+	s.mu.Lock()
+	s.dialing = false
+	if err == nil && s.closing {
+		// Closed while dialing.
+		conn.Close()
+		err = ErrClosed
+	}
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+
+	s.conn = conn
+	s.telnet = telnet.NewConn(conn)
+	s.connectedAt = time.Now()
+	tc := s.telnet
+	openLog := s.log == nil && s.client.LogDir != ""
+	s.mu.Unlock()
+
+	if openLog {
+		log, err := clog.Create(s.client.LogDir, s.World.Name, time.Now())
+		if err != nil {
+			s.client.message(s, "Could not start log for %s: %v", s.World.Name, err)
+		}
+		s.mu.Lock()
+		s.log = log
+		s.mu.Unlock()
+	}
+
+	s.autoLogin = nil
+	if cred, ok := s.client.credential(s.World); ok {
+		s.autoLogin = &login.AutoLogin{
+			Credential: cred,
+			Send:       s.send,
+			Warn: func(text string) {
+				s.client.message(s, "Warning: %s", text)
+			},
+		}
+	}
+
+	s.client.FireHook(HookConnect, s, s.World.Name)
+	go s.read(tc)
+	return nil
+}
+
+func (s *Session) read(tc *telnet.Conn) {
+	lines := make(chan wotmud.Line)
+	done := make(chan error, 1)
+	go func() {
+		done <- NewLineReader(tc).Run(lines)
+		close(lines)
+	}()
+
+	for line := range lines {
+		s.handle(line)
+	}
+
+	err := <-done
+	s.mu.Lock()
+	s.conn = nil
+	s.telnet = nil
+	closing := s.closing
+	lasted := time.Since(s.connectedAt)
+	s.mu.Unlock()
+
+	if err == io.EOF || errors.Is(err, net.ErrClosed) {
+		s.client.message(s, "Connection to %s closed.", s.World.Name)
+	} else {
+		s.client.message(s, "Connection to %s lost: %v", s.World.Name, err)
+	}
+	s.client.FireHook(HookDisconnect, s, s.World.Name)
+
+	if !closing && s.client.Reconnect.Initial > 0 {
+		go s.reconnect(lasted)
+	}
+}
+
+// reconnect keeps trying to connect to the world again until it succeeds or
+// is cancelled by the user.
+func (s *Session) reconnect(lasted time.Duration) {
+	policy := s.client.Reconnect
+	cancel := make(chan struct{})
+
+	s.mu.Lock()
+	if s.delay == 0 || lasted >= policy.Stable {
+		s.delay = policy.Initial
+	} else {
+		s.delay = nextDelay(s.delay, policy)
+	}
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	for {
+		s.mu.Lock()
+		delay := s.delay
+		s.mu.Unlock()
+
+		s.client.message(s, "Reconnecting to %s in %v.", s.World.Name, delay)
+		select {
+		case <-time.After(delay):
+		case <-cancel:
+			return
+		case <-s.client.done:
+			return
+		}
+
+		err := s.connect()
+		if err == ErrClosed {
+			return
+		}
+		if err == nil {
+			s.mu.Lock()
+			if s.cancel == cancel {
+				s.cancel = nil
+			}
+			s.mu.Unlock()
+			s.client.message(s, "Reconnected to %s.", s.World.Name)
+			return
+		}
+
+		s.client.message(s, "Could not reconnect to %s: %v", s.World.Name, err)
+		s.mu.Lock()
+		s.delay = nextDelay(s.delay, policy)
+		s.mu.Unlock()
+	}
+}
+
+func nextDelay(delay time.Duration, policy ReconnectPolicy) time.Duration {
+	delay *= 2
+	if delay > policy.Max {
+		delay = policy.Max
+	}
+	return delay
+}
+
+// cancelReconnect stops a pending reconnect, reporting whether there was one.
+func (s *Session) cancelReconnect() bool {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.cancel = nil
+	s.mu.Unlock()
+
+	if cancel != nil {
+		close(cancel)
+	}
+	return cancel != nil
+}
+
+// handle runs a line of output through the world's state before passing it
+// on to the user interface.
+func (s *Session) handle(line wotmud.Line) {
+	s.mu.Lock()
+	log := s.log
+	s.mu.Unlock()
+	if log != nil {
+		log.Line(line)
+	}
+
+	if s.autoLogin != nil {
+		s.autoLogin.Observe(line)
+	}
+
+	if line.Partial {
+		s.Mapper.Observe(line)
+		s.Combat.Observe(line)
+		if s.Group.Observe(line) {
+			s.fireGroup()
+		}
+		s.observeEffects(line)
+		s.Items.Observe(line)
+		s.observeMount(line, nil)
+		s.observeClock(line)
+		s.observeSurvival(line)
+		s.observeSafety(line)
+
+		s.mu.Lock()
+		s.prompt = line
+		if line.PromptInfo != nil {
+			s.info = line.PromptInfo
+		}
+		s.mu.Unlock()
+
+		s.client.emit(Event{Type: PromptEvent, Session: s, Line: line})
+		s.client.FireHook(HookPrompt, s, line.Raw)
+		return
+	}
+
+	room := s.Mapper.Observe(line)
+	attack := s.Combat.Observe(line)
+	grouped := s.Group.Observe(line)
+	s.observeEffects(line)
+	s.Items.Observe(line)
+	s.observeMount(line, room)
+	s.observeClock(line)
+	s.observeSurvival(line)
+	s.observeSafety(line)
+	s.observeWho(line)
+	shown, gagged := s.Triggers.Process(line)
+	var msg *comm.Message
+	if !gagged {
+		msg = s.observeComm(line)
+	}
+	if msg != nil {
+		shown = s.client.colorComm(shown, msg)
+	}
+
+	s.mu.Lock()
+	if line.PromptInfo != nil {
+		s.prompt = wotmud.NewLine(line.Raw[:line.PromptEnd])
+		s.info = line.PromptInfo
+	}
+	if !gagged {
+		s.history = append(s.history, shown)
+		if len(s.history) > historySize {
+			s.history = s.history[len(s.history)-historySize:]
+		}
+	}
+	s.mu.Unlock()
+
+	s.client.emit(Event{Type: LineEvent, Session: s, Line: line, Text: shown.Raw, Gagged: gagged})
+	if attack != nil {
+		s.client.emit(Event{Type: CombatEvent, Session: s, Line: line, Attack: attack})
+		s.client.FireHook(HookCombat, s, wotmud.StripANSI(line.Text()))
+	}
+	if grouped {
+		s.fireGroup()
+	}
+	if msg != nil {
+		s.client.emit(Event{Type: CommEvent, Session: s, Line: line, Text: shown.Text(), Comm: msg})
+	}
+	if room != nil {
+		s.client.emit(Event{Type: RoomEvent, Session: s, Room: room})
+		s.client.FireHook(HookRoom, s, room.Name)
+	}
+}
+
+// fireGroup runs the GROUP hook with the names of the group's members.
+func (s *Session) fireGroup() {
+	var names []string
+	for _, m := range s.Group.Members() {
+		names = append(names, m.Name)
+	}
+	s.client.FireHook(HookGroup, s, strings.Join(names, ", "))
+}
+
+// Send writes a command to the world. While the world is echoing input
+// itself the command is treated as secret and masked in the log.
+func (s *Session) Send(cmd string) error {
+	return s.send(cmd, s.ServerEcho())
+}
+
+func (s *Session) send(cmd string, secret bool) error {
+	s.mu.Lock()
+	tc := s.telnet
+	log := s.log
+	if tc != nil {
+		s.sentAt = time.Now()
+	}
+	s.mu.Unlock()
+
+	if tc == nil {
+		return ErrNotConnected
+	}
+	if log != nil {
+		log.Sent(cmd, secret)
+	}
+	if !secret {
+		s.Items.Sent(cmd)
+		s.Mapper.Sent(cmd)
+	}
+	_, err := tc.Write([]byte(cmd + "\r\n"))
+	return err
+}
+
+// ServerEcho reports whether the world is currently echoing input itself,
+// which means the user is typing something secret.
+func (s *Session) ServerEcho() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.telnet != nil && s.telnet.ServerEcho()
+}
+
+// Prompt returns the last prompt line received from the world.
+func (s *Session) Prompt() wotmud.Line {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.prompt
+}
+
+// PromptInfo returns the last parsed prompt, or nil if the world has not
+// sent one.
+func (s *Session) PromptInfo() *prompt.Info {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.info
+}
+
+// History returns up to n of the most recent lines received from the world, as
+// they were shown: substituted and highlighted, with gagged lines left out.
+func (s *Session) History(n int) []wotmud.Line {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n > len(s.history) {
+		n = len(s.history)
+	}
+	lines := make([]wotmud.Line, n)
+	copy(lines, s.history[len(s.history)-n:])
+	return lines
+}
+
+// Close disconnects from the world without reconnecting, or cancels a
+// pending reconnect or a connection being opened. The session keeps its
+// state.
+func (s *Session) Close() error {
+	cancelled := s.cancelReconnect()
+
+	s.mu.Lock()
+	conn := s.conn
+	dialing := s.dialing
+	s.closing = true
+	if s.expiry != nil {
+		s.expiry.Stop()
+		s.expiry = nil
+	}
+	s.mu.Unlock()
+
+	if conn == nil {
+		if cancelled || dialing {
+			return nil
+		}
+		return ErrNotConnected
+	}
+	return conn.Close()
+}
+
+// closeLog finishes the session's log.
+func (s *Session) closeLog() {
+	s.mu.Lock()
+	log := s.log
+	s.log = nil
+	s.mu.Unlock()
+
+	if log != nil {
+		log.Close()
+	}
+}
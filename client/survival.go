@@ -0,0 +1,108 @@
+package client
+
+import (
+	"strings"
+	"time"
+
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/survival"
+)
+
+// AutoEatVar and AutoDrinkVar are the variables that, when true, have the
+// client eat and drink for the player when hungry or thirsty.
+const (
+	AutoEatVar   = "auto_eat"
+	AutoDrinkVar = "auto_drink"
+)
+
+// AutoIdle is how long nothing must have been sent to a world before the
+// client eats or drinks for the player.
+const AutoIdle = 10 * time.Second
+
+// observeSurvival feeds a line into the world's hunger and thirst tracker.
+// At a prompt showing the player out of combat, after AutoIdle without
+// input, it eats and drinks as AutoEatVar and AutoDrinkVar allow.
+func (s *Session) observeSurvival(line wotmud.Line) {
+	s.Survival.Observe(line)
+	info := line.Prompt()
+	if info == nil {
+		return
+	}
+
+	autoEat, autoDrink := s.client.setting(AutoEatVar), s.client.setting(AutoDrinkVar)
+	if !autoEat && !autoDrink {
+		return
+	}
+	s.mu.Lock()
+	idle := time.Since(s.sentAt)
+	s.mu.Unlock()
+	if idle < AutoIdle {
+		return
+	}
+
+	eat, drink := s.Survival.Due(info)
+	if eat && autoEat {
+		s.eat()
+	}
+	if drink && autoDrink {
+		s.drink()
+	}
+}
+
+// eat has the player eat the first food at hand, getting it out of its
+// container first.
+func (s *Session) eat() {
+	food := survival.Food(s.Items)
+	if len(food) == 0 {
+		s.client.message(s, "Warning: hungry with no food at hand.")
+		return
+	}
+	s.client.message(s, "Eating %s.", food[0].Name)
+	keyword := survival.Keyword(food[0].Name)
+	if food[0].In != "" {
+		s.Send("get " + keyword + " " + survival.Keyword(food[0].In))
+	}
+	s.Send("eat " + keyword)
+}
+
+// drink has the player drink from the first drink container at hand.
+func (s *Session) drink() {
+	water := survival.Water(s.Items)
+	if len(water) == 0 {
+		s.client.message(s, "Warning: thirsty with no water at hand.")
+		return
+	}
+	s.client.message(s, "Drinking from %s.", water[0].Name)
+	s.Send("drink " + survival.Keyword(water[0].Name))
+}
+
+// setting reports whether the global variable name is set to a true value.
+func (c *Client) setting(name string) bool {
+	value, ok := c.Interp.Var(name)
+	return ok && value != "" && value != "0"
+}
+
+// resolveSurvival supplies hungry and thirsty, and food and water, the food
+// and drink containers at hand separated by commas.
+func resolveSurvival(s *Session, name string) (string, bool) {
+	switch name {
+	case "hungry":
+		return boolValue(s.Survival.Hungry()), true
+	case "thirsty":
+		return boolValue(s.Survival.Thirsty()), true
+	case "food":
+		return supplyNames(survival.Food(s.Items)), true
+	case "water":
+		return supplyNames(survival.Water(s.Items)), true
+	}
+	return "", false
+}
+
+// supplyNames lists the names of supplies, separated by commas.
+func supplyNames(supplies []survival.Supply) string {
+	var names []string
+	for _, supply := range supplies {
+		names = append(names, supply.Name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,234 @@
+package telnet
+
+import (
+	"io"
+	"sync"
+)
+
+// Telnet command bytes from RFC 854 and RFC 885.
+const (
+	EOR  = 239
+	SE   = 240
+	NOP  = 241
+	GA   = 249
+	SB   = 250
+	WILL = 251
+	WONT = 252
+	DO   = 253
+	DONT = 254
+	IAC  = 255
+)
+
+// Telnet options the client knows how to negotiate.
+const (
+	OptEcho = 1
+	OptSGA  = 3
+	OptEOR  = 25
+)
+
+const (
+	stateData = iota
+	stateIAC
+	stateOption
+	stateSub
+	stateSubIAC
+)
+
+// A Frame is a chunk of data received from the server. EndOfPrompt is set
+// when the server marked the end of the data with GA or EOR, meaning any
+// text following the last newline is a complete prompt.
+type Frame struct {
+	Data        []byte
+	EndOfPrompt bool
+}
+
+// Conn wraps a connection to a telnet server. It strips telnet commands out
+// of the data stream, answers option negotiation, and reports the GA and EOR
+// markers some servers send after a prompt.
+type Conn struct {
+	rw io.ReadWriter
+
+	raw   []byte
+	state int
+	verb  byte
+
+	mu         sync.Mutex
+	eor        bool
+	sga        bool
+	gaSeen     bool
+	serverEcho bool
+}
+
+// NewConn creates a telnet connection on top of rw, usually a net.Conn.
+func NewConn(rw io.ReadWriter) *Conn {
+	return &Conn{rw: rw}
+}
+
+// ReadFrame blocks until data or an end of prompt marker is received.
+func (c *Conn) ReadFrame() (Frame, error) {
+	var frame Frame
+	for {
+		if len(c.raw) == 0 {
+			buf := make([]byte, 4096)
+			n, err := c.rw.Read(buf)
+			if n == 0 && err != nil {
+				return frame, err
+			}
+			c.raw = buf[:n]
+		}
+
+		for len(c.raw) > 0 {
+			b := c.raw[0]
+			c.raw = c.raw[1:]
+
+			if c.parse(b, &frame) {
+				return frame, nil
+			}
+		}
+
+		if len(frame.Data) > 0 {
+			return frame, nil
+		}
+	}
+}
+
+// parse feeds one byte into the protocol state machine. It returns true when
+// the byte completed an end of prompt marker.
+func (c *Conn) parse(b byte, frame *Frame) bool {
+	switch c.state {
+	case stateData:
+		if b == IAC {
+			c.state = stateIAC
+		} else {
+			frame.Data = append(frame.Data, b)
+		}
+	case stateIAC:
+		c.state = stateData
+		switch b {
+		case IAC:
+			frame.Data = append(frame.Data, IAC)
+		case GA, EOR:
+			c.mu.Lock()
+			if b == GA {
+				c.gaSeen = true
+			}
+			c.mu.Unlock()
+			frame.EndOfPrompt = true
+			return true
+		case WILL, WONT, DO, DONT:
+			c.verb = b
+			c.state = stateOption
+		case SB:
+			c.state = stateSub
+		}
+	case stateOption:
+		c.state = stateData
+		c.negotiate(c.verb, b)
+	case stateSub:
+		// Subnegotiation is never requested by the client, so its contents
+		// are skipped.
+		if b == IAC {
+			c.state = stateSubIAC
+		}
+	case stateSubIAC:
+		if b == SE {
+			c.state = stateData
+		} else {
+			c.state = stateSub
+		}
+	}
+
+	return false
+}
+
+// negotiate answers a WILL, WONT, DO or DONT from the server. Replies are only
+// sent when the option state actually changes, to avoid negotiation loops.
+func (c *Conn) negotiate(verb, opt byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch verb {
+	case WILL:
+		switch opt {
+		case OptEOR:
+			if !c.eor {
+				c.eor = true
+				c.send(DO, opt)
+			}
+		case OptSGA:
+			if !c.sga {
+				c.sga = true
+				c.send(DO, opt)
+			}
+		case OptEcho:
+			if !c.serverEcho {
+				c.serverEcho = true
+				c.send(DO, opt)
+			}
+		default:
+			c.send(DONT, opt)
+		}
+	case WONT:
+		switch opt {
+		case OptEOR:
+			if c.eor {
+				c.eor = false
+				c.send(DONT, opt)
+			}
+		case OptSGA:
+			if c.sga {
+				c.sga = false
+				c.send(DONT, opt)
+			}
+		case OptEcho:
+			if c.serverEcho {
+				c.serverEcho = false
+				c.send(DONT, opt)
+			}
+		}
+	case DO:
+		c.send(WONT, opt)
+	}
+}
+
+func (c *Conn) send(verb, opt byte) {
+	c.rw.Write([]byte{IAC, verb, opt})
+}
+
+// MarksPrompts reports whether the server can be relied on to mark the end
+// of its prompts, either because it agreed to send EOR or because it has
+// sent a GA without suppressing them.
+func (c *Conn) MarksPrompts() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.eor || (c.gaSeen && !c.sga)
+}
+
+// ServerEcho reports whether the server has taken over echoing, which it
+// does while the user types a passphrase.
+func (c *Conn) ServerEcho() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.serverEcho
+}
+
+// Write sends data to the server, escaping any IAC bytes.
+func (c *Conn) Write(p []byte) (int, error) {
+	escaped := make([]byte, 0, len(p))
+	for _, b := range p {
+		if b == IAC {
+			escaped = append(escaped, IAC)
+		}
+		escaped = append(escaped, b)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := c.rw.Write(escaped); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
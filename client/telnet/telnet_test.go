@@ -0,0 +1,155 @@
+package telnet_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/huntwj/gofugue/client/telnet"
+)
+
+type fakeServer struct {
+	in  *bytes.Buffer
+	out bytes.Buffer
+}
+
+func (s *fakeServer) Read(p []byte) (int, error)  { return s.in.Read(p) }
+func (s *fakeServer) Write(p []byte) (int, error) { return s.out.Write(p) }
+
+func newFakeServer(data ...byte) *fakeServer {
+	return &fakeServer{in: bytes.NewBuffer(data)}
+}
+
+func TestPlainData(t *testing.T) {
+	t.Parallel()
+
+	server := newFakeServer([]byte("Welcome to the Wheel of Time!\r\n")...)
+	conn := telnet.NewConn(server)
+
+	frame, err := conn.ReadFrame()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(frame.Data) != "Welcome to the Wheel of Time!\r\n" {
+		t.Errorf("Unexpected data %q", frame.Data)
+	}
+	if frame.EndOfPrompt {
+		t.Error("Plain data should not end a prompt")
+	}
+
+	if _, err := conn.ReadFrame(); err != io.EOF {
+		t.Errorf("Expected EOF but found %v", err)
+	}
+}
+
+func TestGoAheadEndsPrompt(t *testing.T) {
+	t.Parallel()
+
+	data := append([]byte("* HP:Healthy MV:Full > "), telnet.IAC, telnet.GA)
+	data = append(data, []byte("\r\nA mirrored lantern has gone out!\r\n")...)
+	conn := telnet.NewConn(newFakeServer(data...))
+
+	if conn.MarksPrompts() {
+		t.Error("Prompts should not be marked before a GA is seen")
+	}
+
+	frame, _ := conn.ReadFrame()
+	if string(frame.Data) != "* HP:Healthy MV:Full > " || !frame.EndOfPrompt {
+		t.Errorf("Expected prompt frame but found %q (%t)", frame.Data, frame.EndOfPrompt)
+	}
+	if !conn.MarksPrompts() {
+		t.Error("Prompts should be marked after a GA is seen")
+	}
+
+	frame, _ = conn.ReadFrame()
+	if string(frame.Data) != "\r\nA mirrored lantern has gone out!\r\n" || frame.EndOfPrompt {
+		t.Errorf("Expected text frame but found %q (%t)", frame.Data, frame.EndOfPrompt)
+	}
+}
+
+func TestEORNegotiation(t *testing.T) {
+	t.Parallel()
+
+	data := []byte{telnet.IAC, telnet.WILL, telnet.OptEOR, telnet.IAC, telnet.WILL, telnet.OptSGA}
+	data = append(data, []byte("Passphrase: ")...)
+	data = append(data, telnet.IAC, telnet.EOR)
+	server := newFakeServer(data...)
+	conn := telnet.NewConn(server)
+
+	frame, _ := conn.ReadFrame()
+	if string(frame.Data) != "Passphrase: " || !frame.EndOfPrompt {
+		t.Errorf("Expected EOR terminated frame but found %q (%t)", frame.Data, frame.EndOfPrompt)
+	}
+	if !conn.MarksPrompts() {
+		t.Error("Prompts should be marked once EOR is agreed")
+	}
+
+	expected := []byte{telnet.IAC, telnet.DO, telnet.OptEOR, telnet.IAC, telnet.DO, telnet.OptSGA}
+	if !bytes.Equal(server.out.Bytes(), expected) {
+		t.Errorf("Expected replies %v but found %v", expected, server.out.Bytes())
+	}
+}
+
+func TestEchoNegotiation(t *testing.T) {
+	t.Parallel()
+
+	server := newFakeServer(telnet.IAC, telnet.WILL, telnet.OptEcho, telnet.IAC, telnet.WILL, telnet.OptEcho, 'x')
+	conn := telnet.NewConn(server)
+	conn.ReadFrame()
+
+	if !conn.ServerEcho() {
+		t.Error("Expected server to be echoing")
+	}
+	expected := []byte{telnet.IAC, telnet.DO, telnet.OptEcho}
+	if !bytes.Equal(server.out.Bytes(), expected) {
+		t.Errorf("Expected a single reply %v but found %v", expected, server.out.Bytes())
+	}
+
+	server.in.Write([]byte{telnet.IAC, telnet.WONT, telnet.OptEcho, 'y'})
+	conn.ReadFrame()
+	if conn.ServerEcho() {
+		t.Error("Expected server to stop echoing")
+	}
+}
+
+func TestRefusesUnknownOptions(t *testing.T) {
+	t.Parallel()
+
+	const optNAWS = 31
+	server := newFakeServer(telnet.IAC, telnet.DO, optNAWS, telnet.IAC, telnet.WILL, 200, 'x')
+	conn := telnet.NewConn(server)
+	conn.ReadFrame()
+
+	expected := []byte{telnet.IAC, telnet.WONT, optNAWS, telnet.IAC, telnet.DONT, 200}
+	if !bytes.Equal(server.out.Bytes(), expected) {
+		t.Errorf("Expected refusals %v but found %v", expected, server.out.Bytes())
+	}
+}
+
+func TestSubnegotiationSkipped(t *testing.T) {
+	t.Parallel()
+
+	server := newFakeServer(telnet.IAC, telnet.SB, 24, 1, telnet.IAC, telnet.SE, 'o', 'k')
+	conn := telnet.NewConn(server)
+
+	frame, _ := conn.ReadFrame()
+	if string(frame.Data) != "ok" {
+		t.Errorf("Expected subnegotiation to be skipped but found %q", frame.Data)
+	}
+}
+
+func TestWriteEscapesIAC(t *testing.T) {
+	t.Parallel()
+
+	server := newFakeServer()
+	conn := telnet.NewConn(server)
+
+	n, err := conn.Write([]byte{'a', telnet.IAC, 'b'})
+	if err != nil || n != 3 {
+		t.Errorf("Unexpected write result %d, %v", n, err)
+	}
+	expected := []byte{'a', telnet.IAC, telnet.IAC, 'b'}
+	if !bytes.Equal(server.out.Bytes(), expected) {
+		t.Errorf("Expected %v but found %v", expected, server.out.Bytes())
+	}
+}
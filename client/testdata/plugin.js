@@ -0,0 +1,19 @@
+'use strict';
+
+// Plugin used by TestNodePlugin: answers a tell and announces rooms.
+const gofugue = require('../_node-gofugue').connect();
+
+gofugue.on('line', (ev) => {
+  if (ev.line.text === 'Talia tells you \'ping\'') {
+    gofugue.send('tell talia pong', ev.world);
+  }
+});
+
+gofugue.on('room', (ev) => {
+  gofugue.send('say entered ' + ev.room.name + ' ' + ev.room.exits.join(''), ev.world);
+});
+
+gofugue.setVariable('plugin', 'loaded')
+  .then(() => gofugue.subscribe('line', 'room'))
+  .then(() => gofugue.addAlias('greet', (ev) => gofugue.send('say Hello, ' + ev.args + '!', ev.world)))
+  .then(() => gofugue.echo('plugin ready'));
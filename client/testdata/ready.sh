@@ -0,0 +1,5 @@
+#!/bin/sh
+# Plugin used by TestProcessPlugins: any executable can speak the protocol.
+printf '%s\n' '{"jsonrpc":"2.0","method":"setVariable","params":{"name":"shell","value":"yes"}}'
+printf '%s\n' '{"jsonrpc":"2.0","method":"echo","params":{"text":"shell ready"}}'
+while read -r line; do :; done
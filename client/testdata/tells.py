@@ -0,0 +1,29 @@
+#!/usr/bin/env python3
+"""Plugin used by TestProcessPlugins: answers tells, greets and welcomes."""
+
+import os
+import sys
+
+sys.path.insert(0, os.path.join(os.path.dirname(os.path.abspath(__file__)), '..', '_python-gofugue'))
+import gofugue
+
+g = gofugue.connect()
+
+
+@g.trigger('tell', r"^(\w+) tells you '(.*)'$")
+def tell(ev):
+    g.send('tell %s pong %s' % (ev['match'][1].lower(), ev['match'][2]), ev['world'])
+
+
+@g.alias('greet')
+def greet(ev):
+    g.send('say Hello, %s!' % ev['args'], ev['world'])
+
+
+@g.hook('CONNECT')
+def connected(ev):
+    g.echo('welcome to ' + ev['args'])
+
+
+g.echo('python ready')
+g.run()
@@ -0,0 +1,172 @@
+package trigger
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/huntwj/gofugue/wotmud"
+)
+
+// An Action is run when a trigger matches a line. Match holds the full match
+// followed by any submatches.
+type Action func(line wotmud.Line, match []string)
+
+// A Trigger runs its Action for every line of output matching Pattern. The
+// pattern is matched against the text following any prompt on the line. A
+// trigger with a Display also changes how matching lines are shown, and is
+// matched against the text with its ANSI codes removed.
+type Trigger struct {
+	Name    string
+	Pattern *regexp.Regexp
+	Action  Action
+	Display *Display
+}
+
+// Display - How a trigger changes the way the lines it matches are shown
+type Display struct {
+	// Gag hides the line.
+	Gag bool
+	// Hilite is the ANSI attribute, such as "\x1b[1;31m", for the matched
+	// text.
+	Hilite string
+	// Subst, when not empty, replaces the matched text. It may refer to
+	// submatches as $1 and so on.
+	Subst string
+}
+
+// apply changes text, the part of a line following its prompt, as the display
+// says for the matches of re.
+func (d *Display) apply(re *regexp.Regexp, text string) string {
+	plain, offsets := wotmud.StripANSIOffsets(text)
+	if d.Subst != "" {
+		text = re.ReplaceAllString(plain, d.Subst)
+		plain, offsets = wotmud.StripANSIOffsets(text)
+	}
+	if d.Hilite == "" {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringIndex(plain, -1) {
+		if m[0] == m[1] {
+			continue
+		}
+		start, end := offsets[m[0]], offsets[m[1]-1]+1
+		b.WriteString(text[last:start])
+		b.WriteString(d.Hilite)
+		// Codes inside the match would override the highlight, so it is
+		// repeated after them.
+		for idx, pos := m[0], start; idx < m[1]; idx++ {
+			if offsets[idx] > pos {
+				b.WriteString(text[pos:offsets[idx]])
+				b.WriteString(d.Hilite)
+			}
+			b.WriteByte(text[offsets[idx]])
+			pos = offsets[idx] + 1
+		}
+		b.WriteString("\x1b[0m")
+		last = end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// A Set is an ordered collection of triggers belonging to one world.
+type Set struct {
+	mu       sync.RWMutex
+	triggers []*Trigger
+}
+
+// NewSet creates an empty trigger set.
+func NewSet() *Set {
+	return &Set{}
+}
+
+// Add appends a trigger to the set. A trigger with the same non-empty name is
+// replaced in place.
+func (s *Set) Add(t *Trigger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t.Name != "" {
+		for idx, existing := range s.triggers {
+			if existing.Name == t.Name {
+				s.triggers[idx] = t
+				return
+			}
+		}
+	}
+	s.triggers = append(s.triggers, t)
+}
+
+// Remove deletes the named trigger, reporting whether it existed.
+func (s *Set) Remove(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for idx, existing := range s.triggers {
+		if existing.Name == name {
+			s.triggers = append(s.triggers[:idx], s.triggers[idx+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of triggers in the set.
+func (s *Set) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.triggers)
+}
+
+// Run matches a line against every trigger in order and runs the actions of
+// those that match. It returns the number of triggers that fired.
+func (s *Set) Run(line wotmud.Line) int {
+	fired, _, _ := s.run(line)
+	return fired
+}
+
+// Process runs the triggers like Run and returns the line as it should be
+// shown after the displays of the triggers that fired, and whether it is
+// gagged. Each display sees the text as changed by those before it.
+func (s *Set) Process(line wotmud.Line) (wotmud.Line, bool) {
+	_, shown, gag := s.run(line)
+	return shown, gag
+}
+
+func (s *Set) run(line wotmud.Line) (int, wotmud.Line, bool) {
+	s.mu.RLock()
+	triggers := make([]*Trigger, len(s.triggers))
+	copy(triggers, s.triggers)
+	s.mu.RUnlock()
+
+	text := line.Text()
+	plain := wotmud.StripANSI(text)
+	shown, gag := text, false
+	fired := 0
+	for _, t := range triggers {
+		subject := text
+		if t.Display != nil {
+			subject = plain
+		}
+		match := t.Pattern.FindStringSubmatch(subject)
+		if match == nil {
+			continue
+		}
+		fired++
+		if t.Action != nil {
+			t.Action(line, match)
+		}
+		if t.Display != nil {
+			gag = gag || t.Display.Gag
+			shown = t.Display.apply(t.Pattern, shown)
+		}
+	}
+
+	line.Raw = line.Raw[:line.PromptEnd] + shown
+	return fired, line, gag
+}
@@ -0,0 +1,92 @@
+package trigger_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/huntwj/gofugue/client/trigger"
+	"github.com/huntwj/gofugue/wotmud"
+)
+
+func TestTriggerFires(t *testing.T) {
+	t.Parallel()
+
+	s := trigger.NewSet()
+	var observed []string
+	s.Add(&trigger.Trigger{
+		Name:    "lantern",
+		Pattern: regexp.MustCompile(`^A (.+) has gone out!$`),
+		Action: func(line wotmud.Line, match []string) {
+			observed = match
+		},
+	})
+
+	if fired := s.Run(wotmud.NewLine("* HP:Healthy MV:Full > A mirrored lantern has gone out!")); fired != 1 {
+		t.Fatalf("Expected 1 trigger to fire but %d did", fired)
+	}
+	if len(observed) != 2 || observed[1] != "mirrored lantern" {
+		t.Errorf("Unexpected match %q", observed)
+	}
+
+	if fired := s.Run(wotmud.NewLine("The River Lady has arrived at the docks.")); fired != 0 {
+		t.Errorf("Expected no triggers to fire but %d did", fired)
+	}
+}
+
+func TestTriggerReplaceAndRemove(t *testing.T) {
+	t.Parallel()
+
+	s := trigger.NewSet()
+	s.Add(&trigger.Trigger{Name: "a", Pattern: regexp.MustCompile("x")})
+	s.Add(&trigger.Trigger{Name: "a", Pattern: regexp.MustCompile("y")})
+	s.Add(&trigger.Trigger{Pattern: regexp.MustCompile("z")})
+
+	if s.Len() != 2 {
+		t.Errorf("Expected 2 triggers but found %d", s.Len())
+	}
+	if fired := s.Run(wotmud.NewLine("x")); fired != 0 {
+		t.Error("Replaced trigger should not fire")
+	}
+	if !s.Remove("a") || s.Remove("a") {
+		t.Error("Expected trigger to be removed exactly once")
+	}
+	if s.Len() != 1 {
+		t.Errorf("Expected 1 trigger but found %d", s.Len())
+	}
+}
+
+func TestTriggerDisplay(t *testing.T) {
+	t.Parallel()
+
+	s := trigger.NewSet()
+	s.Add(&trigger.Trigger{
+		Pattern: regexp.MustCompile(`^The ancient tree tries to hit you, but you parry successfully\.$`),
+		Display: &trigger.Display{Gag: true},
+	})
+	s.Add(&trigger.Trigger{
+		Pattern: regexp.MustCompile(`hits your body hard`),
+		Display: &trigger.Display{Hilite: "\x1b[1;31m"},
+	})
+	s.Add(&trigger.Trigger{
+		Pattern: regexp.MustCompile(`^You receive your share of experience\.+$`),
+		Display: &trigger.Display{Subst: "[exp]"},
+	})
+
+	tests := []struct {
+		raw, shown string
+		gag        bool
+	}{
+		{"* HP:Healthy MV:Full > The \x1b[33mancient tree\x1b[0m tries to hit you, but you parry successfully.",
+			"* HP:Healthy MV:Full > The \x1b[33mancient tree\x1b[0m tries to hit you, but you parry successfully.", true},
+		{"The ancient tree \x1b[31mhits your\x1b[0m body hard.",
+			"The ancient tree \x1b[31m\x1b[1;31mhits your\x1b[0m\x1b[1;31m body hard\x1b[0m.", false},
+		{"* HP:Hurt MV:Full > You receive your share of experience...", "* HP:Hurt MV:Full > [exp]", false},
+		{"You parry.", "You parry.", false},
+	}
+	for _, test := range tests {
+		shown, gag := s.Process(wotmud.NewLine(test.raw))
+		if shown.Raw != test.shown || gag != test.gag {
+			t.Errorf("Expected %q to be shown as %q, gagged %v, but found %q, %v", test.raw, test.shown, test.gag, shown.Raw, gag)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// maxHistory is how many input lines are kept for recall.
+const maxHistory = 500
+
+// editor - The line being typed, with its cursor and the history of lines
+// entered before it
+type editor struct {
+	mu      sync.Mutex
+	line    []rune
+	pos     int
+	history []string
+	recall  int    // index into history while recalling, len(history) otherwise
+	draft   string // the line being typed when recall started
+}
+
+// editFuncs are the editing functions available to /dokey, named as in
+// TinyFugue. NEWLINE is handled by the UI.
+var editFuncs = map[string]func(e *editor){
+	"BSPC": func(e *editor) {
+		if e.pos > 0 {
+			e.delete(e.pos-1, e.pos)
+		}
+	},
+	"DCH": func(e *editor) {
+		if e.pos < len(e.line) {
+			e.delete(e.pos, e.pos+1)
+		}
+	},
+	"BWORD":   func(e *editor) { e.delete(e.wordLeft(), e.pos) },
+	"DWORD":   func(e *editor) { e.delete(e.pos, e.wordRight()) },
+	"DLINE":   func(e *editor) { e.delete(0, len(e.line)) },
+	"DEOL":    func(e *editor) { e.delete(e.pos, len(e.line)) },
+	"HOME":    func(e *editor) { e.pos = 0 },
+	"END":     func(e *editor) { e.pos = len(e.line) },
+	"LEFT":    func(e *editor) { e.pos = max(e.pos-1, 0) },
+	"RIGHT":   func(e *editor) { e.pos = min(e.pos+1, len(e.line)) },
+	"WLEFT":   func(e *editor) { e.pos = e.wordLeft() },
+	"WRIGHT":  func(e *editor) { e.pos = e.wordRight() },
+	"RECALLB": func(e *editor) { e.recallTo(e.recall - 1) },
+	"RECALLF": func(e *editor) { e.recallTo(e.recall + 1) },
+	"REDRAW":  func(e *editor) {},
+}
+
+// do runs the editing function name.
+func (e *editor) do(name string) error {
+	fn, ok := editFuncs[strings.ToUpper(name)]
+	if !ok {
+		return fmt.Errorf("%s: no such editing function", name)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	fn(e)
+	return nil
+}
+
+// insert types text at the cursor, ignoring control characters.
+func (e *editor) insert(text string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, r := range text {
+		if unicode.IsControl(r) {
+			continue
+		}
+		e.line = append(e.line, 0)
+		copy(e.line[e.pos+1:], e.line[e.pos:])
+		e.line[e.pos] = r
+		e.pos++
+	}
+}
+
+// enter clears the line and returns it, adding it to the history unless
+// secret is set.
+func (e *editor) enter(secret bool) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	line := string(e.line)
+	e.line, e.pos = e.line[:0], 0
+	if !secret && line != "" && (len(e.history) == 0 || e.history[len(e.history)-1] != line) {
+		e.history = append(e.history, line)
+		if len(e.history) > maxHistory {
+			e.history = e.history[len(e.history)-maxHistory:]
+		}
+	}
+	e.recall, e.draft = len(e.history), ""
+	return line
+}
+
+// state returns the line and the cursor position in it.
+func (e *editor) state() ([]rune, int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]rune(nil), e.line...), e.pos
+}
+
+func (e *editor) delete(from, to int) {
+	e.line = append(e.line[:from], e.line[to:]...)
+	e.pos = from
+}
+
+func (e *editor) wordLeft() int {
+	pos := e.pos
+	for pos > 0 && unicode.IsSpace(e.line[pos-1]) {
+		pos--
+	}
+	for pos > 0 && !unicode.IsSpace(e.line[pos-1]) {
+		pos--
+	}
+	return pos
+}
+
+func (e *editor) wordRight() int {
+	pos := e.pos
+	for pos < len(e.line) && unicode.IsSpace(e.line[pos]) {
+		pos++
+	}
+	for pos < len(e.line) && !unicode.IsSpace(e.line[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// recallTo replaces the line with history entry idx, or with the line that
+// was being typed when idx is past the end of the history.
+func (e *editor) recallTo(idx int) {
+	if idx < 0 || idx > len(e.history) || idx == e.recall {
+		return
+	}
+	if e.recall == len(e.history) {
+		e.draft = string(e.line)
+	}
+	e.recall = idx
+	if idx == len(e.history) {
+		e.line = []rune(e.draft)
+	} else {
+		e.line = []rune(e.history[idx])
+	}
+	e.pos = len(e.line)
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package ui
+
+import "errors"
+
+func cbreak(fd uintptr) (func(), error) {
+	return nil, errors.New("terminal modes are not supported on this platform")
+}
+
+func termHeight(fd uintptr) (int, error) {
+	return 0, errors.New("terminal size is not supported on this platform")
+}
@@ -0,0 +1,53 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package ui
+
+import (
+	"errors"
+	"syscall"
+	"unsafe"
+)
+
+// cbreak switches the terminal to reading a key at a time without echo. Signal
+// keys such as ^C keep working. The returned func restores the old mode.
+func cbreak(fd uintptr) (func(), error) {
+	var old syscall.Termios
+	if err := ioctl(fd, ioctlGetTermios, unsafe.Pointer(&old)); err != nil {
+		return nil, err
+	}
+
+	mode := old
+	mode.Lflag &^= syscall.ICANON | syscall.ECHO | syscall.IEXTEN
+	mode.Cc[syscall.VMIN] = 1
+	mode.Cc[syscall.VTIME] = 0
+	if err := ioctl(fd, ioctlSetTermios, unsafe.Pointer(&mode)); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		ioctl(fd, ioctlSetTermios, unsafe.Pointer(&old))
+	}, nil
+}
+
+// termHeight finds the number of rows of the terminal.
+func termHeight(fd uintptr) (int, error) {
+	var size struct {
+		rows, cols, xpixel, ypixel uint16
+	}
+	if err := ioctl(fd, syscall.TIOCGWINSZ, unsafe.Pointer(&size)); err != nil {
+		return 0, err
+	}
+	if size.rows == 0 {
+		return 0, errors.New("terminal size unknown")
+	}
+	return int(size.rows), nil
+}
+
+func ioctl(fd, req uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,424 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/huntwj/gofugue/client"
+	"github.com/huntwj/gofugue/client/keymap"
+	"github.com/huntwj/gofugue/client/scrollback"
+	"github.com/huntwj/gofugue/tflang/interp"
+)
+
+// UI - A line oriented terminal interface. Output from the foreground world is
+// printed as it arrives, with its current prompt and the line being typed kept
+// on the last line of the screen. Every world has a scrollback of its own, so
+// background worlds only announce activity until they are brought to the
+// foreground. Messages on communication
+// channels are also kept in a comm tab, which /tab switches to and back, and
+// diagnostics such as the stderr of plugins go to a log tab of their own. The
+// input line starts with the foreground world's status, such as the game hour
+// and whether the room is dark. Keys are looked up in the client's keymap,
+// and the editing functions of /dokey work on the input line.
+type UI struct {
+	client *client.Client
+	in     io.Reader
+	out    io.Writer
+
+	keys   keymap.Decoder
+	input  editor
+	scroll *scrollback.Buffer // the foreground world's
+	worlds map[*client.Session]*scrollback.Buffer
+	comm   *scrollback.Buffer
+	log    *scrollback.Buffer
+	shown  *scrollback.Buffer // scroll, or the comm or log tab when shown
+	hidden int                // lines printed while another tab was shown
+	height int                // page size of the scrollbacks
+	term   interface{ Fd() uintptr }
+	unseen map[*client.Session]int
+}
+
+// New - Create a UI for a client reading keys from in and drawing to out
+func New(c *client.Client, in io.Reader, out io.Writer) *UI {
+	u := &UI{
+		client: c,
+		in:     in,
+		out:    out,
+		keys:   keymap.Decoder{Keymap: c.Keys},
+		scroll: scrollback.New(scrollback.DefaultCapacity, scrollback.DefaultHeight),
+		comm:   scrollback.New(scrollback.DefaultCapacity, scrollback.DefaultHeight),
+		log:    scrollback.New(scrollback.DefaultCapacity, scrollback.DefaultHeight),
+		unseen: make(map[*client.Session]int),
+	}
+	u.shown = u.scroll
+	// Messages shown while no world is connected have a scrollback too.
+	u.worlds = map[*client.Session]*scrollback.Buffer{nil: u.scroll}
+	c.Interp.Register("dokey", u.cmdDokey)
+	c.Interp.Register("more", u.cmdMore)
+	c.Interp.Register("search", u.cmdSearch)
+	c.Interp.Register("tab", u.cmdTab)
+	return u
+}
+
+// Run - Process events and keys until the client quits or input ends
+func (u *UI) Run() error {
+	if f, ok := u.in.(interface{ Fd() uintptr }); ok {
+		if restore, err := cbreak(f.Fd()); err == nil {
+			defer restore()
+			u.term = f
+			if height, err := termHeight(f.Fd()); err == nil {
+				u.setHeight(height - 1)
+			}
+			// Have the numeric keypad send its own sequences.
+			fmt.Fprint(u.out, "\x1b=")
+			defer fmt.Fprint(u.out, "\x1b>")
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	keys := make(chan []byte)
+	go u.readKeys(keys)
+
+	timeout := time.NewTimer(time.Hour)
+	timeout.Stop()
+
+	u.redraw()
+	for {
+		select {
+		case ev := <-u.client.Events():
+			u.handleEvent(ev)
+		case key, ok := <-keys:
+			if !ok {
+				keys = nil
+				u.handleActions(u.keys.Flush())
+				u.client.Quit()
+				continue
+			}
+			timeout.Stop()
+			u.handleActions(u.keys.Feed(key))
+			if u.keys.Pending() {
+				timeout.Reset(u.client.Keys.Timeout)
+			}
+		case <-timeout.C:
+			u.handleActions(u.keys.Flush())
+		case <-signals:
+			u.client.Quit()
+		case <-u.client.Done():
+			u.drain()
+			fmt.Fprint(u.out, "\r\x1b[K")
+			return nil
+		}
+	}
+}
+
+func (u *UI) readKeys(keys chan<- []byte) {
+	defer close(keys)
+
+	buf := make([]byte, 256)
+	for {
+		n, err := u.in.Read(buf)
+		if n > 0 {
+			key := make([]byte, n)
+			copy(key, buf[:n])
+			keys <- key
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// drain shows any events still queued when the client quits.
+func (u *UI) drain() {
+	for {
+		select {
+		case ev := <-u.client.Events():
+			u.handleEvent(ev)
+		default:
+			return
+		}
+	}
+}
+
+func (u *UI) handleEvent(ev client.Event) {
+	fg := u.client.Foreground()
+
+	switch ev.Type {
+	case client.LineEvent:
+		if ev.Gagged {
+			return
+		}
+		if ev.Session == fg {
+			u.print(ev.Text)
+		} else {
+			u.world(ev.Session).Add(ev.Text)
+			if u.unseen[ev.Session] == 0 {
+				u.print(fmt.Sprintf("%% Activity in world %s", ev.Session.World.Name))
+			}
+			u.unseen[ev.Session]++
+		}
+	case client.PromptEvent:
+		if ev.Session == fg {
+			u.redraw()
+		}
+	case client.CommEvent:
+		if ev.Session != nil {
+			u.printTab(u.comm, fmt.Sprintf("[%s] %s", ev.Session.World.Name, ev.Text))
+		}
+	case client.LogEvent:
+		u.printTab(u.log, ev.Text)
+	case client.MessageEvent:
+		u.print(ev.Text)
+	case client.ForegroundEvent:
+		main := u.shown == u.scroll
+		u.scroll = u.world(ev.Session)
+		if main {
+			u.shown = u.scroll
+		}
+		delete(u.unseen, ev.Session)
+		if ev.Session == nil {
+			u.scroll.Add("---- No world ----")
+		} else {
+			u.scroll.Add(fmt.Sprintf("---- World %s ----", ev.Session.World.Name))
+		}
+		if main {
+			u.repaint()
+		}
+	}
+}
+
+// world finds the scrollback of the world s, creating it on its first line.
+func (u *UI) world(s *client.Session) *scrollback.Buffer {
+	b, ok := u.worlds[s]
+	if !ok {
+		b = scrollback.New(scrollback.DefaultCapacity, u.height)
+		b.SetMore(u.scroll.More())
+		u.worlds[s] = b
+	}
+	return b
+}
+
+// handleActions types the text and runs the commands of decoded keys.
+func (u *UI) handleActions(actions []keymap.Action) {
+	u.shown.Ack()
+	for _, action := range actions {
+		if action.Command == "" {
+			u.input.insert(action.Text)
+			continue
+		}
+		// Collect keeps reading events while the command runs, so a long
+		// listing cannot fill the channel this goroutine empties.
+		var err error
+		events := u.client.Collect(func() {
+			err = u.client.Interp.Exec(&interp.Frame{}, action.Command)
+		})
+		for _, ev := range events {
+			u.handleEvent(ev)
+		}
+		if err != nil {
+			u.print("% " + err.Error())
+		}
+	}
+	u.redraw()
+}
+
+// cmdDokey implements "/dokey name", running an editing function such as
+// BWORD or RECALLB on the input line. NEWLINE enters the line.
+func (u *UI) cmdDokey(args string) error {
+	switch strings.ToUpper(args) {
+	case "NEWLINE":
+	case "PGUP":
+		u.shown.PageUp()
+		u.repaint()
+		return nil
+	case "PGDN":
+		u.shown.PageDown()
+		u.repaint()
+		return nil
+	case "FLUSH":
+		u.shown.Flush()
+		u.repaint()
+		return nil
+	default:
+		return u.input.do(args)
+	}
+
+	secret := false
+	if fg := u.client.Foreground(); fg != nil {
+		secret = fg.ServerEcho()
+	}
+	line := u.input.enter(secret)
+	u.redraw()
+	u.client.Input(line)
+	return nil
+}
+
+// cmdMore implements "/more [on|off]", pausing output after every page.
+func (u *UI) cmdMore(args string) error {
+	switch strings.ToLower(args) {
+	case "on":
+		for _, b := range u.worlds {
+			b.SetMore(true)
+		}
+	case "off":
+		for _, b := range u.worlds {
+			b.SetMore(false)
+		}
+		u.repaint()
+	case "":
+		state := "off"
+		if u.scroll.More() {
+			state = "on"
+		}
+		u.print("% more is " + state)
+	default:
+		return errors.New("usage: /more [on|off]")
+	}
+	return nil
+}
+
+// cmdSearch implements "/search regexp", scrolling back to the previous line
+// matching regexp and highlighting the matches. Without arguments it clears
+// the highlighting.
+func (u *UI) cmdSearch(args string) error {
+	if args == "" {
+		u.shown.Search(nil)
+		u.repaint()
+		return nil
+	}
+	re, err := regexp.Compile(args)
+	if err != nil {
+		return err
+	}
+	found := u.shown.Search(re)
+	u.repaint()
+	if !found {
+		return fmt.Errorf("%s: not found", args)
+	}
+	return nil
+}
+
+// cmdTab implements "/tab [main|comm|log]", showing the output of the
+// worlds, the comm tab, which holds their communication channels, or the log
+// tab. Without arguments it switches between the main and comm tabs.
+func (u *UI) cmdTab(args string) error {
+	switch strings.ToLower(args) {
+	case "main":
+		u.shown = u.scroll
+	case "comm":
+		u.shown = u.comm
+	case "log":
+		u.shown = u.log
+	case "":
+		if u.shown == u.scroll {
+			u.shown = u.comm
+		} else {
+			u.shown = u.scroll
+		}
+	default:
+		return errors.New("usage: /tab [main|comm|log]")
+	}
+	if u.shown == u.scroll {
+		u.hidden = 0
+	}
+	u.repaint()
+	return nil
+}
+
+// print writes a line of output above the input line, unless the view is
+// scrolled back or paused or another tab is shown, in which case the line
+// waits in the scrollback.
+func (u *UI) print(text string) {
+	if u.scroll.Add(text) && u.shown == u.scroll {
+		fmt.Fprintf(u.out, "\r\x1b[K%s\n", text)
+	} else if u.shown != u.scroll {
+		u.hidden++
+	}
+	u.redraw()
+}
+
+// printTab keeps a line in the comm or log tab, writing it above the input
+// line if the tab is shown.
+func (u *UI) printTab(tab *scrollback.Buffer, text string) {
+	if tab.Add(text) && u.shown == tab {
+		fmt.Fprintf(u.out, "\r\x1b[K%s\n", text)
+	}
+	u.redraw()
+}
+
+// repaint draws the whole screen: the lines in the scrollback view followed
+// by the input line.
+func (u *UI) repaint() {
+	if u.term != nil {
+		if height, err := termHeight(u.term.Fd()); err == nil {
+			u.setHeight(height - 1)
+		}
+	}
+
+	fmt.Fprint(u.out, "\x1b[H\x1b[2J")
+	for _, line := range u.shown.View() {
+		fmt.Fprintf(u.out, "%s\x1b[0m\r\n", line)
+	}
+	u.redraw()
+}
+
+// setHeight changes the page size of every scrollback.
+func (u *UI) setHeight(height int) {
+	u.height = height
+	for _, b := range u.worlds {
+		b.SetHeight(height)
+	}
+	u.comm.SetHeight(height)
+	u.log.SetHeight(height)
+}
+
+// redraw repaints the input line: the foreground world's prompt followed by
+// whatever the user has typed so far.
+func (u *UI) redraw() {
+	prompt := ""
+	secret := false
+	var fields []string
+	if fg := u.client.Foreground(); fg != nil {
+		prompt = fg.Prompt().Raw
+		secret = fg.ServerEcho()
+		fields = fg.Status()
+	}
+
+	line, pos := u.input.state()
+	input := string(line)
+	if secret {
+		input = strings.Repeat("*", len(line))
+	}
+	status := u.shown.Status()
+	if u.shown != u.scroll {
+		name := "Comm"
+		if u.shown == u.log {
+			name = "Log"
+		}
+		tab := "--" + name + "--"
+		if u.hidden > 0 {
+			tab = fmt.Sprintf("--%s: %d new lines--", name, u.hidden)
+		}
+		status = strings.TrimSpace(tab + " " + status)
+	}
+	if len(fields) > 0 {
+		status = strings.TrimSpace("[" + strings.Join(fields, ", ") + "] " + status)
+	}
+	if status != "" {
+		status = "\x1b[7m" + status + "\x1b[0m "
+	}
+	fmt.Fprintf(u.out, "\r\x1b[K\x1b[0m%s%s%s", status, prompt, input)
+	if back := len(line) - pos; back > 0 {
+		fmt.Fprintf(u.out, "\x1b[%dD", back)
+	}
+}
@@ -0,0 +1,177 @@
+package ui_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/huntwj/gofugue/client"
+	"github.com/huntwj/gofugue/client/ui"
+	"github.com/huntwj/gofugue/wotmud/comm"
+)
+
+func TestInputReachesClient(t *testing.T) {
+	c := client.New()
+	var out bytes.Buffer
+	u := ui.New(c, strings.NewReader("kill ancient\n/addworld Freddie\n/quit\n"), &out)
+
+	if err := u.Run(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, expected := range []string{"% You are not connected to a world.", "% usage: /addworld"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected output to contain %q but found %q", expected, out.String())
+		}
+	}
+}
+
+func TestEndOfInputQuits(t *testing.T) {
+	c := client.New()
+	var out bytes.Buffer
+	u := ui.New(c, strings.NewReader(""), &out)
+
+	if err := u.Run(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	select {
+	case <-c.Done():
+	default:
+		t.Error("Expected client to quit when input ends")
+	}
+}
+
+func TestKeyBindings(t *testing.T) {
+	c := client.New()
+	if err := c.Interp.Eval("/bind ^[Ow = /echo west"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var out bytes.Buffer
+	keys := "/echo hello wrld\x17world\n" + // BWORD
+		"\x1b[A\x02\x02\x02\x02\x08X\n" + // RECALLB, LEFT, BSPC
+		"\x1bOw" +
+		"junk\x15/echo done\n/quit\n" // DLINE
+	u := ui.New(c, strings.NewReader(keys), &out)
+
+	if err := u.Run(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if idx := strings.LastIndex(line, "\x1b[K"); idx >= 0 {
+			line = line[idx+3:]
+		}
+		lines = append(lines, line)
+	}
+	output := strings.Join(lines, "|")
+	if !strings.Contains(output, "hello world|hello Xorld|west|done") {
+		t.Errorf("Unexpected output %q", output)
+	}
+}
+
+func TestLongListing(t *testing.T) {
+	c := client.New()
+	for i := 0; i < 300; i++ {
+		c.Comm.Add(comm.Entry{World: "Freddie", Line: fmt.Sprintf("Spruce chats 'number %d'", i)})
+	}
+	var out bytes.Buffer
+	u := ui.New(c, strings.NewReader("/comm\n/quit\n"), &out)
+
+	done := make(chan error, 1)
+	go func() { done <- u.Run() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out listing 300 messages")
+	}
+	if !strings.Contains(out.String(), "[Freddie] Spruce chats 'number 299'") {
+		t.Errorf("Expected the whole listing but found %q", out.String())
+	}
+}
+
+// screen is an output the test can read while the UI writes to it.
+type screen struct {
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	read int // how much of buf was waited for
+}
+
+func (s *screen) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.buf.Write(p)
+}
+
+// wait waits for text to be written after what was waited for before,
+// returning what was drawn since the screen was last cleared.
+func (s *screen) wait(t *testing.T, text string) string {
+	t.Helper()
+
+	for timeout := time.Now().Add(2 * time.Second); time.Now().Before(timeout); time.Sleep(10 * time.Millisecond) {
+		s.mu.Lock()
+		out := s.buf.String()
+		s.mu.Unlock()
+		if idx := strings.Index(out[s.read:], text); idx >= 0 {
+			s.read += idx + len(text)
+			return out[strings.LastIndex(out, "\x1b[2J")+1:]
+		}
+	}
+	t.Fatalf("Timed out waiting for %q", text)
+	return ""
+}
+
+func TestScrollbackPerWorld(t *testing.T) {
+	c := client.New()
+	conns := make(map[string]net.Conn)
+	for _, name := range []string{"Freddie", "Talia"} {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Could not listen: %v", err)
+		}
+		defer listener.Close()
+		host, port, _ := net.SplitHostPort(listener.Addr().String())
+		c.Input("/addworld " + name + " " + host + " " + port)
+		if _, err := c.Connect(name); err != nil {
+			t.Fatalf("Could not connect: %v", err)
+		}
+		if conns[name], err = listener.Accept(); err != nil {
+			t.Fatalf("Could not accept: %v", err)
+		}
+		defer conns[name].Close()
+	}
+	c.Input("/fg Freddie")
+
+	in, keys := io.Pipe()
+	var out screen
+	u := ui.New(c, in, &out)
+	done := make(chan error, 1)
+	go func() { done <- u.Run() }()
+
+	conns["Freddie"].Write([]byte("Freddie stands here.\r\n"))
+	conns["Talia"].Write([]byte("Talia stands here.\r\n"))
+	out.wait(t, "% Activity in world Talia")
+
+	keys.Write([]byte("/fg Talia\n"))
+	if view := out.wait(t, "---- World Talia ----"); !strings.Contains(view, "Talia stands here.") || strings.Contains(view, "Freddie stands here.") {
+		t.Errorf("Expected only Talia's output but found %q", view)
+	}
+	keys.Write([]byte("/fg Freddie\n"))
+	if view := out.wait(t, "---- World Freddie ----"); !strings.Contains(view, "Freddie stands here.") || strings.Contains(view, "Talia stands here.") {
+		t.Errorf("Expected only Freddie's output but found %q", view)
+	}
+
+	keys.Write([]byte("/quit\n"))
+	if err := <-done; err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
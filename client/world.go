@@ -0,0 +1,519 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/huntwj/gofugue/client/keymap"
+	"github.com/huntwj/gofugue/client/login"
+	"github.com/huntwj/gofugue/client/roster"
+	"github.com/huntwj/gofugue/tflang/interp"
+	"github.com/huntwj/gofugue/wotmud/comm"
+)
+
+// A World is a named server definition, as created by /addworld. Logins for
+// it are kept in the credential store.
+type World struct {
+	Name string
+	Host string
+	Port int
+}
+
+// Client - Data structure tying together the defined worlds, the sessions
+// connected to them and the command interpreter. Output from every world is
+// delivered to the user interface through Events.
+type Client struct {
+	Interp *interp.Interp
+
+	// Credentials holds the encrypted logins used to answer the login
+	// prompts of a world. It is nil when no store could be opened.
+	Credentials *login.Store
+	// Reconnect controls reconnecting to worlds whose connection was lost.
+	Reconnect ReconnectPolicy
+	// Dial opens the connection to a world. It defaults to net.DialTimeout
+	// with DialTimeout.
+	Dial func(network, address string) (net.Conn, error)
+	// LogDir is where a .clog file is kept for every world. Logging is off
+	// when it is empty.
+	LogDir string
+	// Plugins supervises the plugin processes run for the client.
+	Plugins *Plugins
+	// Keys holds the key bindings made with /bind for the user interface.
+	Keys *keymap.Keymap
+	// Comm keeps what was said on the communication channels of every world.
+	Comm *comm.History
+	// Roster keeps the players seen in who lists and how the user regards
+	// them. It starts out empty and is not saved unless replaced with one
+	// opened from a file.
+	Roster *roster.Roster
+
+	hooks     hookSet
+	aliases   aliasSet
+	triggers  triggerList
+	effects   effectList
+	macros    macroSet
+	listeners []*Listener
+
+	events   chan Event
+	done     chan struct{}
+	quitOnce sync.Once
+
+	mu       sync.Mutex
+	worlds   []*World
+	sessions []*Session
+	fg       *Session
+}
+
+// New - Create a client with the standard commands registered
+func New() *Client {
+	c := &Client{
+		Interp:    interp.New(),
+		Reconnect: DefaultReconnect,
+		Keys:      keymap.New(),
+		Comm:      comm.NewHistory(comm.DefaultCapacity),
+		Roster:    roster.New(),
+		events:    make(chan Event, 256),
+		done:      make(chan struct{}),
+	}
+	c.Plugins = newPlugins(c)
+	c.setupScripting()
+
+	c.Interp.Register("addworld", c.cmdAddWorld)
+	c.Interp.Register("world", c.cmdWorld)
+	c.Interp.Register("fg", c.cmdFg)
+	c.Interp.Register("dc", c.cmdDc)
+	c.Interp.Register("quit", c.cmdQuit)
+	c.Interp.Register("echo", c.cmdEcho)
+	c.Interp.Register("addlogin", c.cmdAddLogin)
+	c.Interp.Register("dellogin", c.cmdDelLogin)
+	c.Interp.Register("plugins", c.cmdPlugins)
+	c.Interp.Register("bind", c.cmdBind)
+	c.Interp.Register("unbind", c.cmdUnbind)
+	c.Interp.Register("reply", c.cmdReply)
+	c.Interp.Register("comm", c.cmdComm)
+	c.Interp.Register("effects", c.cmdEffects)
+	c.Interp.Register("effect", c.cmdEffect)
+	c.Interp.Register("roster", c.cmdRoster)
+	c.Interp.Register("friend", c.relationCmd("friend", roster.Friend))
+	c.Interp.Register("enemy", c.relationCmd("enemy", roster.Enemy))
+	c.Interp.Register("neutral", c.relationCmd("neutral", roster.Neutral))
+	c.Interp.Register("note", c.cmdNote)
+
+	return c
+}
+
+// Events - The channel the user interface reads output and messages from
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+// Done - Closed once the user has asked to quit
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// AddListener - Call fn with every event. The returned func removes it.
+func (c *Client) AddListener(fn Listener) func() {
+	l := &fn
+	c.mu.Lock()
+	c.listeners = append(c.listeners, l)
+	c.mu.Unlock()
+
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		for idx, existing := range c.listeners {
+			if existing == l {
+				c.listeners = append(c.listeners[:idx], c.listeners[idx+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) emit(ev Event) {
+	c.mu.Lock()
+	listeners := make([]*Listener, len(c.listeners))
+	copy(listeners, c.listeners)
+	c.mu.Unlock()
+	for _, l := range listeners {
+		(*l)(ev)
+	}
+
+	select {
+	case c.events <- ev:
+	case <-c.done:
+	}
+}
+
+// Collect - Run fn on a goroutine of its own, returning the events read from
+// Events while it ran, in order. The user interface runs commands this way,
+// as it is the one reading Events and a long listing such as /comm could
+// otherwise fill the channel with nobody left to empty it.
+func (c *Client) Collect(fn func()) []Event {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+
+	var events []Event
+	for {
+		select {
+		case ev := <-c.events:
+			events = append(events, ev)
+		case <-done:
+			// Events sent just before fn returned may still be queued.
+			for {
+				select {
+				case ev := <-c.events:
+					events = append(events, ev)
+				default:
+					return events
+				}
+			}
+		}
+	}
+}
+
+func (c *Client) message(s *Session, format string, args ...interface{}) {
+	c.emit(Event{
+		Type:    MessageEvent,
+		Session: s,
+		Text:    "% " + fmt.Sprintf(format, args...),
+	})
+}
+
+// Echo - Show text to the user as if it were output from the world s, which
+// may be nil for the foreground world
+func (c *Client) Echo(s *Session, text string) {
+	c.emit(Event{
+		Type:    MessageEvent,
+		Session: s,
+		Text:    text,
+	})
+}
+
+// Input - Handle a line typed by the user. The line is first split into
+// commands by ExpandInput, except while the world is hiding input such as a
+// password. Slash commands and aliases are run by the interpreter, plugin
+// aliases by their function and anything else is sent to the foreground
+// world, each command on its own.
+func (c *Client) Input(line string) {
+	cmds := []string{line}
+	if fg := c.Foreground(); fg == nil || !fg.ServerEcho() {
+		var err error
+		if cmds, err = ExpandInput(line, c.separator()); err != nil {
+			c.message(nil, "%v", err)
+			return
+		}
+	}
+
+	var err error
+	for _, cmd := range cmds {
+		if err = c.Interp.Input(&interp.Frame{}, cmd); err != nil {
+			break
+		}
+	}
+	if err == ErrNotConnected {
+		c.message(nil, "You are not connected to a world.")
+	} else if err != nil {
+		c.message(nil, "%v", err)
+	}
+}
+
+// AddWorld - Define a world, replacing any existing world with the same name
+func (c *Client) AddWorld(w *World) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for idx, existing := range c.worlds {
+		if strings.EqualFold(existing.Name, w.Name) {
+			c.worlds[idx] = w
+			return
+		}
+	}
+	c.worlds = append(c.worlds, w)
+}
+
+// World - Find a world definition by name
+func (c *Client) World(name string) *World {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.world(name)
+}
+
+func (c *Client) world(name string) *World {
+	for _, w := range c.worlds {
+		if strings.EqualFold(w.Name, name) {
+			return w
+		}
+	}
+	return nil
+}
+
+// Session - Find the session for a world by name
+func (c *Client) Session(name string) *Session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.session(name)
+}
+
+func (c *Client) session(name string) *Session {
+	for _, s := range c.sessions {
+		if strings.EqualFold(s.World.Name, name) {
+			return s
+		}
+	}
+	return nil
+}
+
+// Sessions - All sessions in the order they were first connected
+func (c *Client) Sessions() []*Session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sessions := make([]*Session, len(c.sessions))
+	copy(sessions, c.sessions)
+	return sessions
+}
+
+// Connect - Connect to the named world and bring it to the foreground. An
+// existing session for the world is reused so its state carries over.
+func (c *Client) Connect(name string) (*Session, error) {
+	c.mu.Lock()
+	w := c.world(name)
+	if w == nil {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("no world named '%s'", name)
+	}
+	s := c.session(w.Name)
+	if s == nil {
+		s = newSession(c, w)
+		c.sessions = append(c.sessions, s)
+	}
+	c.mu.Unlock()
+
+	c.SetForeground(s)
+	if s.Connected() {
+		return s, nil
+	}
+	s.cancelReconnect()
+
+	c.message(s, "Connecting to %s (%s %d)...", w.Name, w.Host, w.Port)
+	if err := s.connect(); err != nil {
+		return s, fmt.Errorf("could not connect to %s: %w", w.Name, err)
+	}
+	c.message(s, "Connected to %s.", w.Name)
+	return s, nil
+}
+
+// dial opens a connection with Dial, or net.DialTimeout if it is not set.
+func (c *Client) dial(network, address string) (net.Conn, error) {
+	if c.Dial != nil {
+		return c.Dial(network, address)
+	}
+	return net.DialTimeout(network, address, DialTimeout)
+}
+
+// credential finds the login for a world in the credential store.
+func (c *Client) credential(w *World) (login.Credential, bool) {
+	if c.Credentials == nil {
+		return login.Credential{}, false
+	}
+	return c.Credentials.Get(w.Name)
+}
+
+// Foreground - The session user input is sent to, or nil
+func (c *Client) Foreground() *Session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.fg
+}
+
+// SetForeground - Make s the session user input is sent to
+func (c *Client) SetForeground(s *Session) {
+	c.mu.Lock()
+	changed := c.fg != s
+	c.fg = s
+	c.mu.Unlock()
+
+	if changed {
+		c.emit(Event{Type: ForegroundEvent, Session: s})
+	}
+}
+
+// Cycle - Bring the next (delta > 0) or previous (delta < 0) session to the
+// foreground, wrapping around at either end.
+func (c *Client) Cycle(delta int) {
+	c.mu.Lock()
+	count := len(c.sessions)
+	if count == 0 {
+		c.mu.Unlock()
+		return
+	}
+	idx := 0
+	for i, s := range c.sessions {
+		if s == c.fg {
+			idx = i
+		}
+	}
+	next := c.sessions[((idx+delta)%count+count)%count]
+	c.mu.Unlock()
+
+	c.SetForeground(next)
+}
+
+// Quit - Disconnect from every world and tell the user interface to exit
+func (c *Client) Quit() {
+	for _, s := range c.Sessions() {
+		s.Close()
+		s.closeLog()
+	}
+	c.quitOnce.Do(func() {
+		close(c.done)
+	})
+	c.Plugins.Close()
+}
+
+func (c *Client) cmdAddWorld(args string) error {
+	// The password is the rest of the line, spaces and all.
+	fields := strings.SplitN(args, " ", 5)
+	if len(fields) != 3 && len(fields) != 5 || hasEmpty(fields) {
+		return errors.New("usage: /addworld name host port [char pass]")
+	}
+
+	port, err := strconv.Atoi(fields[2])
+	if err != nil || port <= 0 || port > 65535 {
+		return fmt.Errorf("invalid port '%s'", fields[2])
+	}
+
+	c.AddWorld(&World{
+		Name: fields[0],
+		Host: fields[1],
+		Port: port,
+	})
+	if len(fields) == 5 {
+		// Scripts are plain text, so the login goes into the store.
+		c.message(nil, "Warning: /addworld with a password leaves it readable; use /addlogin instead.")
+		return c.cmdAddLogin(fields[0] + " " + fields[3] + " " + fields[4])
+	}
+	return nil
+}
+
+func (c *Client) cmdWorld(args string) error {
+	name := args
+	if name == "" {
+		c.mu.Lock()
+		if len(c.worlds) > 0 {
+			name = c.worlds[0].Name
+		}
+		c.mu.Unlock()
+		if name == "" {
+			return errors.New("no worlds defined")
+		}
+	} else if c.World(name) == nil {
+		return fmt.Errorf("no world named '%s'", name)
+	}
+
+	go func() {
+		if _, err := c.Connect(name); err != nil {
+			c.message(nil, "%v", err)
+		}
+	}()
+	return nil
+}
+
+func (c *Client) cmdFg(args string) error {
+	switch args {
+	case "-<":
+		c.Cycle(-1)
+	case "->", "":
+		c.Cycle(1)
+	default:
+		s := c.Session(args)
+		if s == nil {
+			return fmt.Errorf("no session for '%s'", args)
+		}
+		c.SetForeground(s)
+	}
+	return nil
+}
+
+func (c *Client) cmdDc(args string) error {
+	s := c.Foreground()
+	if args != "" {
+		s = c.Session(args)
+	}
+	if s == nil {
+		return errors.New("no such session")
+	}
+	return s.Close()
+}
+
+func (c *Client) cmdQuit(args string) error {
+	c.Quit()
+	return nil
+}
+
+func (c *Client) cmdEcho(args string) error {
+	c.Echo(nil, args)
+	return nil
+}
+
+func (c *Client) cmdPlugins(args string) error {
+	names := c.Plugins.Names()
+	if len(names) == 0 {
+		c.message(nil, "No plugins are running.")
+		return nil
+	}
+	c.message(nil, "Plugins: %s", strings.Join(names, ", "))
+	return nil
+}
+
+func (c *Client) cmdAddLogin(args string) error {
+	// The passphrase is the rest of the line, spaces and all.
+	fields := strings.SplitN(args, " ", 3)
+	if len(fields) != 3 || hasEmpty(fields) {
+		return errors.New("usage: /addlogin world char pass")
+	}
+	if c.Credentials == nil {
+		return errors.New("no credential store is available")
+	}
+
+	c.Credentials.Set(fields[0], login.Credential{Character: fields[1], Passphrase: fields[2]})
+	if err := c.Credentials.Save(); err != nil {
+		return err
+	}
+	c.message(nil, "Login for %s saved.", fields[0])
+	return nil
+}
+
+// hasEmpty reports whether any of fields is empty, as when the words of a
+// command's arguments are separated by more than one space.
+func hasEmpty(fields []string) bool {
+	for _, field := range fields {
+		if field == "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Client) cmdDelLogin(args string) error {
+	if args == "" {
+		return errors.New("usage: /dellogin world")
+	}
+	if c.Credentials == nil || !c.Credentials.Delete(args) {
+		return fmt.Errorf("no login saved for %s", args)
+	}
+	return c.Credentials.Save()
+}
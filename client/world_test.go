@@ -0,0 +1,411 @@
+package client_test
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huntwj/gofugue/client"
+	"github.com/huntwj/gofugue/client/login"
+	"github.com/huntwj/gofugue/client/telnet"
+)
+
+// testServer is a stand-in for a MUD. It accepts connections one at a time
+// and records every line the client sends.
+type testServer struct {
+	listener net.Listener
+	conns    chan net.Conn
+	received chan string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %v", err)
+	}
+	s := &testServer{
+		listener: listener,
+		conns:    make(chan net.Conn, 10),
+		received: make(chan string, 100),
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.conns <- conn
+			go func() {
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					s.received <- strings.TrimRight(scanner.Text(), "\r")
+				}
+			}()
+		}
+	}()
+	return s
+}
+
+func (s *testServer) addWorldCommand(name string) string {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return "/addworld " + name + " " + host + " " + port
+}
+
+// newTestStore creates an empty credential store for a test.
+func newTestStore(t *testing.T) *login.Store {
+	t.Helper()
+
+	store, err := login.Open(filepath.Join(t.TempDir(), "credentials"), login.MasterKey("correct horse"))
+	if err != nil {
+		t.Fatalf("Could not open store: %v", err)
+	}
+	return store
+}
+
+func (s *testServer) accept(t *testing.T) net.Conn {
+	t.Helper()
+
+	select {
+	case conn := <-s.conns:
+		return conn
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the client to connect")
+	}
+	return nil
+}
+
+func (s *testServer) expectReceived(t *testing.T, expected string) {
+	t.Helper()
+
+	select {
+	case line := <-s.received:
+		if line != expected {
+			t.Errorf("Expected server to receive %q but found %q", expected, line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Timed out waiting for server to receive %q", expected)
+	}
+}
+
+func (s *testServer) Close() {
+	s.listener.Close()
+}
+
+// waitEvent reads events until one of the given type arrives.
+func waitEvent(t *testing.T, c *client.Client, eventType client.EventType) client.Event {
+	t.Helper()
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case ev := <-c.Events():
+			if ev.Type == eventType {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for event type %d", eventType)
+		}
+	}
+}
+
+func TestAddWorldUsage(t *testing.T) {
+	t.Parallel()
+
+	c := client.New()
+	for _, cmd := range []string{"/addworld", "/addworld Freddie game.wotmud.org", "/addworld Freddie game.wotmud.org port"} {
+		if err := c.Interp.Eval(cmd); err == nil {
+			t.Errorf("Expected error for %q", cmd)
+		}
+	}
+
+	if err := c.Interp.Eval("/addworld Freddie game.wotmud.org 2224"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	w := c.World("freddie")
+	if w == nil {
+		t.Fatal("Expected world to be defined")
+	}
+	if w.Host != "game.wotmud.org" || w.Port != 2224 {
+		t.Errorf("Unexpected world definition %+v", *w)
+	}
+}
+
+func TestAddWorldPassword(t *testing.T) {
+	t.Parallel()
+
+	c := client.New()
+	if err := c.Interp.Eval("/addworld Freddie game.wotmud.org 2224 freddie secret"); err == nil {
+		t.Error("Expected an error without a credential store")
+	}
+	if c.World("Freddie") == nil {
+		t.Error("Expected the world to be defined all the same")
+	}
+
+	store := newTestStore(t)
+	c.Credentials = store
+	if err := c.Interp.Eval("/addworld Talia game.wotmud.org 2224 talia secret"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cred, ok := store.Get("Talia"); !ok || cred.Character != "talia" || cred.Passphrase != "secret" {
+		t.Errorf("Expected the login to be moved into the store but found %+v", cred)
+	}
+	waitMessages(t, c, "% Warning: /addworld with a password leaves it readable; use /addlogin instead.")
+}
+
+func TestAddLoginPassphrase(t *testing.T) {
+	t.Parallel()
+
+	c := client.New()
+	store := newTestStore(t)
+	c.Credentials = store
+	for _, cmd := range []string{"/addlogin Freddie freddie", "/addlogin Freddie  freddie secret"} {
+		if err := c.Interp.Eval(cmd); err == nil {
+			t.Errorf("Expected error for %q", cmd)
+		}
+	}
+
+	if err := c.Interp.Eval("/addlogin Freddie freddie correct  horse battery"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cred, ok := store.Get("Freddie"); !ok || cred.Character != "freddie" || cred.Passphrase != "correct  horse battery" {
+		t.Errorf("Expected the passphrase to be kept verbatim but found %+v", cred)
+	}
+	if err := c.Interp.Eval("/addworld Talia game.wotmud.org 2224 talia staple  horse"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cred, _ := store.Get("Talia"); cred.Passphrase != "staple  horse" {
+		t.Errorf("Expected the passphrase to be kept verbatim but found %q", cred.Passphrase)
+	}
+}
+
+func TestUnknownWorld(t *testing.T) {
+	t.Parallel()
+
+	c := client.New()
+	if err := c.Interp.Eval("/world Nobody"); err == nil {
+		t.Error("Expected error connecting to an undefined world")
+	}
+}
+
+func TestMultipleWorlds(t *testing.T) {
+	t.Parallel()
+
+	freddie := newTestServer(t)
+	defer freddie.Close()
+	talia := newTestServer(t)
+	defer talia.Close()
+
+	c := client.New()
+	defer c.Quit()
+	c.Input(freddie.addWorldCommand("Freddie"))
+	c.Input(talia.addWorldCommand("Talia"))
+
+	if _, err := c.Connect("Freddie"); err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	freddieConn := freddie.accept(t)
+	if _, err := c.Connect("Talia"); err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	taliaConn := talia.accept(t)
+
+	if fg := c.Foreground(); fg == nil || fg.World.Name != "Talia" {
+		t.Fatalf("Expected Talia in the foreground but found %v", fg)
+	}
+
+	c.Input("look")
+	talia.expectReceived(t, "look")
+
+	freddieConn.Write([]byte("A mirrored lantern has gone out!\r\n"))
+	ev := waitEvent(t, c, client.LineEvent)
+	if ev.Session.World.Name != "Freddie" || ev.Line.Raw != "A mirrored lantern has gone out!" {
+		t.Errorf("Unexpected line event from %s: %q", ev.Session.World.Name, ev.Line.Raw)
+	}
+
+	taliaConn.Write([]byte("* HP:Healthy MV:Full > "))
+	ev = waitEvent(t, c, client.PromptEvent)
+	if ev.Session.World.Name != "Talia" || ev.Session.PromptInfo() == nil {
+		t.Errorf("Expected prompt from Talia but found %q from %s", ev.Line.Raw, ev.Session.World.Name)
+	}
+	if c.Session("Freddie").PromptInfo() != nil {
+		t.Error("Prompt state should be kept per world")
+	}
+
+	c.Cycle(1)
+	if fg := c.Foreground(); fg.World.Name != "Freddie" {
+		t.Fatalf("Expected Freddie in the foreground but found %s", fg.World.Name)
+	}
+	c.Input("score")
+	freddie.expectReceived(t, "score")
+
+	if err := c.Interp.Eval("/fg Talia"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	c.Input("who")
+	talia.expectReceived(t, "who")
+}
+
+func TestCollect(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	c := client.New()
+	defer c.Quit()
+	c.Input(server.addWorldCommand("Freddie"))
+	if _, err := c.Connect("Freddie"); err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	conn := server.accept(t)
+
+	arrived := make(chan struct{}, 1)
+	remove := c.AddListener(func(ev client.Event) {
+		if ev.Type == client.LineEvent {
+			arrived <- struct{}{}
+		}
+	})
+	defer remove()
+
+	// More output than Events holds, with a line from the world meanwhile.
+	events := c.Collect(func() {
+		for i := 0; i < 300; i++ {
+			c.Echo(nil, fmt.Sprintf("number %d", i))
+		}
+		conn.Write([]byte("A mirrored lantern has gone out!\r\n"))
+		select {
+		case <-arrived:
+		case <-time.After(2 * time.Second):
+			t.Error("Timed out waiting for the world's line")
+		}
+	})
+
+	var echoed, lines int
+	for _, ev := range events {
+		switch ev.Type {
+		case client.MessageEvent:
+			if !strings.HasPrefix(ev.Text, "number ") {
+				continue
+			}
+			if ev.Text != fmt.Sprintf("number %d", echoed) {
+				t.Fatalf("Expected number %d but found %q", echoed, ev.Text)
+			}
+			echoed++
+		case client.LineEvent:
+			lines++
+		}
+	}
+	if echoed != 300 || lines != 1 {
+		t.Errorf("Expected 300 messages and a line but found %d and %d", echoed, lines)
+	}
+}
+
+func TestPromptEndsRoom(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	c := client.New()
+	defer c.Quit()
+	c.Input(server.addWorldCommand("Freddie"))
+
+	s, err := c.Connect("Freddie")
+	if err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	conn := server.accept(t)
+
+	conn.Write([]byte("\x1b[36mReception of the White Crescent\x1b[0m\r\nHere upstairs, a corridor leads to the bedrooms.\r\n"))
+	conn.Write(append([]byte("* HP:Healthy MV:Full > "), telnet.IAC, telnet.GA))
+	waitEvent(t, c, client.PromptEvent)
+	conn.Write([]byte("\r\n[ obvious exits: W D ]\r\n"))
+	for waitEvent(t, c, client.LineEvent).Line.Raw != "[ obvious exits: W D ]" {
+	}
+	if room := s.Mapper.Current(); room != nil {
+		t.Errorf("Expected a prompt to end the room but found %q", room.Name)
+	}
+
+	conn.Write([]byte("\x1b[36mThe White Crescent\x1b[0m\r\nA quiet room.\r\n[ obvious exits: N S ]\r\n"))
+	if ev := waitEvent(t, c, client.RoomEvent); ev.Room.Name != "The White Crescent" {
+		t.Errorf("Expected The White Crescent but found %q", ev.Room.Name)
+	}
+}
+
+func TestDisconnectKeepsSession(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	c := client.New()
+	defer c.Quit()
+	c.Input(server.addWorldCommand("Freddie"))
+
+	s, err := c.Connect("Freddie")
+	if err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	conn := server.accept(t)
+	conn.Write([]byte("You are standing.\r\n"))
+	waitEvent(t, c, client.LineEvent)
+	conn.Close()
+
+	ev := waitEvent(t, c, client.MessageEvent)
+	for !strings.Contains(ev.Text, "closed") {
+		ev = waitEvent(t, c, client.MessageEvent)
+	}
+	if s.Connected() {
+		t.Error("Session should be disconnected")
+	}
+	if history := s.History(10); len(history) != 1 {
+		t.Errorf("Expected session to keep its history but found %d lines", len(history))
+	}
+
+	if err := s.Send("look"); err != client.ErrNotConnected {
+		t.Errorf("Expected ErrNotConnected but found %v", err)
+	}
+	if again, _ := c.Connect("Freddie"); again != s {
+		t.Error("Reconnecting should reuse the session")
+	}
+	server.accept(t)
+}
+
+func TestCycleWithoutSessions(t *testing.T) {
+	t.Parallel()
+
+	c := client.New()
+	c.Cycle(1)
+	if c.Foreground() != nil {
+		t.Error("Expected no foreground session")
+	}
+}
+
+func TestAutoLoginOnConnect(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t)
+	defer server.Close()
+
+	c := client.New()
+	defer c.Quit()
+	c.Credentials = newTestStore(t)
+	c.Input(server.addWorldCommand("Freddie"))
+	c.Input("/addlogin Freddie freddie hunter2")
+
+	if _, err := c.Connect("Freddie"); err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	conn := server.accept(t)
+
+	conn.Write([]byte("By what name do you wish to be known? "))
+	server.expectReceived(t, "freddie")
+	conn.Write([]byte("Passphrase: "))
+	server.expectReceived(t, "hunter2")
+}
@@ -1,9 +1,123 @@
-package gofugue
+package main
 
 import (
+	"flag"
 	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/huntwj/gofugue/client"
+	"github.com/huntwj/gofugue/client/login"
+	"github.com/huntwj/gofugue/client/roster"
+	"github.com/huntwj/gofugue/client/ui"
 )
 
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
+
+func loadScript(c *client.Client, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return c.Interp.Load(f)
+}
+
+// masterKeyEnv names the environment variable holding the master passphrase
+// for the credential store. Without it the store is encrypted with a keyfile.
+const masterKeyEnv = "GOFUGUE_MASTER_KEY"
+
+func openCredentials(path, keyFile string) (*login.Store, error) {
+	source := login.KeyFile(expandHome(keyFile))
+	if master := os.Getenv(masterKeyEnv); master != "" {
+		source = login.MasterKey(master)
+	}
+	return login.Open(expandHome(path), source)
+}
+
+// startPlugins runs the executables and node scripts in nodeDir. The node
+// binary is taken from the -node flag, then the node_path variable, then
+// PATH.
+func startPlugins(c *client.Client, node, nodeDir string) error {
+	scripts, err := client.NodeScripts(nodeDir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if node == "" {
+		node, _ = c.Interp.Var(client.NodeVar)
+	}
+	if len(scripts) > 0 {
+		node, err = client.FindNode(node)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%d node scripts not started: %v\n", len(scripts), err)
+			node = ""
+		}
+	}
+	_, err = c.Plugins.LoadDir(node, nodeDir)
+	return err
+}
+
 func main() {
-	fmt.Println("Gofugue. Load up the terminal client here.")
+	rcFile := flag.String("rc", "~/.gofugue/init.tf", "Script of commands, such as /addworld, to run at startup.")
+	credFile := flag.String("credentials", "~/.gofugue/credentials", "Encrypted store of world logins.")
+	keyFile := flag.String("keyfile", "~/.gofugue/credentials.key", "Key for the credential store, unless "+masterKeyEnv+" is set.")
+	rosterFile := flag.String("roster", "~/.gofugue/roster.json", "Record of the players seen in who lists.")
+	logDir := flag.String("logdir", "~/.gofugue/logs", "Directory for world logs. Empty disables logging.")
+	nodeDir := flag.String("nodeDir", "~/.gofugue", "Directory of plugins: node scripts and executables.")
+	node := flag.String("node", "", "The node binary. Defaults to "+client.NodeVar+" or node on PATH.")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] [world]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s analyze <file.clog.gz>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.Arg(0) == "analyze" {
+		if err := analyze(os.Stdout, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	c := client.New()
+	store, err := openCredentials(*credFile, *keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Automatic login disabled: %v\n", err)
+	}
+	c.Credentials = store
+	if r, err := roster.Open(expandHome(*rosterFile)); err != nil {
+		fmt.Fprintf(os.Stderr, "Roster not kept: %v\n", err)
+	} else {
+		c.Roster = r
+	}
+	if *logDir != "" {
+		c.LogDir = expandHome(*logDir)
+	}
+
+	if err := loadScript(c, expandHome(*rcFile)); err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Error loading %s: %v\n", *rcFile, err)
+	}
+	if err := startPlugins(c, *node, expandHome(*nodeDir)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting plugins: %v\n", err)
+	}
+	if flag.NArg() > 0 {
+		c.Input("/world " + flag.Arg(0))
+	}
+
+	if err := ui.New(c, os.Stdin, os.Stdout).Run(); err != nil {
+		log.Fatal(err)
+	}
 }
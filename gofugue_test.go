@@ -1,10 +1,70 @@
-package gofugue_test
+package main
 
 import (
-	"fmt"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
-func TestSomething(t *testing.T) {
-	fmt.Println("Test something. I don't know what yet. This is a placeholder.")
+func TestExpandHome(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("No home directory available.")
+	}
+
+	if observed := expandHome("~/.gofugue/init.tf"); observed != filepath.Join(home, ".gofugue/init.tf") {
+		t.Errorf("Unexpected expansion '%s'", observed)
+	}
+	if observed := expandHome("/etc/init.tf"); observed != "/etc/init.tf" {
+		t.Errorf("Absolute path should not change but found '%s'", observed)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fight.clog")
+	log := "* R HP:Healthy MV:Fresh > <Sent: kill tree >\n" +
+		"^[32mYou slash the ancient tree's trunk hard.\r\n" +
+		"* R HP:Healthy MV:Fresh - the ancient tree: Scratched > \r\n" +
+		"The ancient tree tries to hit you, but you parry successfully.\r\n" +
+		"* R HP:Healthy MV:Fresh > \r\n"
+	if err := ioutil.WriteFile(path, []byte(log), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := analyze(&buf, []string{path}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, expected := range []string{
+		"1 fights\n",
+		"Fight 1: the ancient tree, 1 rounds, mostly slash\n",
+		"Dealt:  1 attacks, 1 hits (100%): 1 hard\n",
+		"parried 100%",
+		"Target: the ancient tree: Scratched\n",
+		"Total with slash: 1 rounds\n",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected %q in the report:\n%s", expected, buf.String())
+		}
+	}
+}
+
+func TestAnalyzeLog(t *testing.T) {
+	// A fight against the writhing grass from the test logs.
+	var buf bytes.Buffer
+	if err := analyze(&buf, []string{filepath.Join("testdata", "writhing-grass.clog")}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, expected := range []string{
+		"Fight 1: the writhing grass, 20 rounds, mostly slash\n",
+		"Dealt:  19 attacks, 19 hits (100%): 1 hard, 6 very hard, 5 extremely hard, 7 into fragments\n",
+		"Taken:  35 attacks, 1 hits (3%): 1 normal\n",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected %q in the report:\n%s", expected, buf.String())
+		}
+	}
 }
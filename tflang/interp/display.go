@@ -0,0 +1,154 @@
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// attrColors are the colours of the C attribute, in ANSI order.
+var attrColors = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// AttrCodes - Interpret the display attributes of a macro: g gags the lines
+// it matches, B, u, r and f make the matched text bold, underlined, reversed
+// or flashing, and Cname, such as Cred, colours it. It returns whether the
+// lines are gagged and the ANSI sequence starting the other attributes.
+func AttrCodes(attr string) (bool, string, error) {
+	gag := false
+	var codes []string
+	for idx := 0; idx < len(attr); idx++ {
+		switch ch := attr[idx]; ch {
+		case 'g':
+			gag = true
+		case 'B':
+			codes = append(codes, "1")
+		case 'u':
+			codes = append(codes, "4")
+		case 'f':
+			codes = append(codes, "5")
+		case 'r':
+			codes = append(codes, "7")
+		case 'C':
+			rest := strings.ToLower(attr[idx+1:])
+			found := false
+			for n, color := range attrColors {
+				if strings.HasPrefix(rest, color) {
+					codes = append(codes, fmt.Sprint(30+n))
+					idx += len(color)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false, "", fmt.Errorf("-a%s: unknown colour", attr)
+			}
+		default:
+			return false, "", fmt.Errorf("-a%s: unknown attribute %c", attr, ch)
+		}
+	}
+
+	if len(codes) == 0 {
+		return gag, "", nil
+	}
+	return gag, "\x1b[" + strings.Join(codes, ";") + "m", nil
+}
+
+// defineRule defines an unnamed trigger macro for /gag, /hilite and
+// /substitute from args of the form [-mstyle] [-wworld] pattern, where the
+// options allowed besides m and w are given by extra.
+func (in *Interp) defineRule(args, extra string, m *Macro) error {
+	opts, pattern, err := ParseOptions(args)
+	if err != nil {
+		return err
+	}
+	for opt := range opts {
+		if !strings.ContainsRune("mw"+extra, rune(opt)) {
+			return fmt.Errorf("-%c: unknown option", opt)
+		}
+	}
+	if pattern == "" {
+		return errors.New("no pattern given")
+	}
+	if attr, ok := opts['a']; ok {
+		m.Attr = attr
+	}
+	if _, _, err := AttrCodes(m.Attr); err != nil {
+		return err
+	}
+	if m.Trigger, err = compilePattern(opts['m'], pattern); err != nil {
+		return err
+	}
+	m.World = opts['w']
+	return in.Define(m)
+}
+
+// cmdGag implements "/gag [-mstyle] [-wworld] pattern", hiding the lines
+// matching pattern.
+func (in *Interp) cmdGag(args string) error {
+	return in.defineRule(args, "", &Macro{Attr: "g"})
+}
+
+// cmdHilite implements "/hilite [-aattrs] [-mstyle] [-wworld] pattern",
+// showing the text matching pattern in bold or the given attributes.
+func (in *Interp) cmdHilite(args string) error {
+	return in.defineRule(args, "a", &Macro{Attr: "B"})
+}
+
+// cmdSubstitute implements
+//
+//	/substitute [-mstyle] [-wworld] pattern = replacement
+//
+// showing the text matching pattern as replacement, which may refer to
+// submatches as $1 and so on.
+func (in *Interp) cmdSubstitute(args string) error {
+	idx := strings.IndexByte(args, '=')
+	if idx < 0 || strings.TrimSpace(args[idx+1:]) == "" {
+		return errors.New("usage: /substitute [-mstyle] [-wworld] pattern = replacement")
+	}
+	subst := strings.TrimSpace(args[idx+1:])
+	return in.defineRule(strings.TrimSpace(args[:idx]), "", &Macro{Subst: subst})
+}
+
+// removeRules undefines the macros made by a rule command for pattern, which
+// are those selected by kind.
+func (in *Interp) removeRules(args string, kind func(m *Macro) bool) error {
+	opts, pattern, err := ParseOptions(args)
+	if err != nil {
+		return err
+	}
+	re, err := compilePattern(opts['m'], pattern)
+	if err != nil {
+		return err
+	}
+
+	removed := false
+	for _, m := range in.Macros() {
+		if m.Trigger != nil && m.Trigger.String() == re.String() && kind(m) {
+			removed = in.Undefine(m.Name) || removed
+		}
+	}
+	if !removed {
+		return fmt.Errorf("%s: no such pattern", pattern)
+	}
+	return nil
+}
+
+func (in *Interp) cmdNoGag(args string) error {
+	return in.removeRules(args, func(m *Macro) bool {
+		gag, _, _ := AttrCodes(m.Attr)
+		return gag
+	})
+}
+
+func (in *Interp) cmdNoHilite(args string) error {
+	return in.removeRules(args, func(m *Macro) bool {
+		_, codes, _ := AttrCodes(m.Attr)
+		return codes != ""
+	})
+}
+
+func (in *Interp) cmdNoSubstitute(args string) error {
+	return in.removeRules(args, func(m *Macro) bool {
+		return m.Subst != ""
+	})
+}
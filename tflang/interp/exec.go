@@ -0,0 +1,486 @@
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxDepth bounds how deeply macros may call each other.
+const maxDepth = 32
+
+// maxIterations bounds the number of times a /while loop may run.
+const maxIterations = 10000
+
+// A Frame is the context a command runs in.
+type Frame struct {
+	// World names the world the frame belongs to. It is empty for the
+	// foreground world.
+	World string
+	// Name is the name of the macro being run, if any.
+	Name string
+	// Args holds the arguments a macro was called with. They are available
+	// to the macro as the positional parameters %1 to %9, %* and %-1.
+	Args string
+	// Vars are variables local to the frame, such as P0 to P9 holding the
+	// match of the trigger that is running.
+	Vars map[string]string
+
+	depth  int
+	parent *Frame
+}
+
+func (f *Frame) child(name, args string) *Frame {
+	return &Frame{World: f.World, Name: name, Args: args, depth: f.depth + 1, parent: f}
+}
+
+// within reports whether the frame is running the macro called name, or is
+// called from it.
+func (f *Frame) within(name string) bool {
+	for ; f != nil; f = f.parent {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Param - Find the value of a positional parameter of the frame: 0 for the
+// macro name, 1 to 9 for an argument, * for all of them, -N for all but the
+// first N, L for the last and -L for all but the last.
+func (f *Frame) Param(name string) (string, bool) {
+	words := strings.Fields(f.Args)
+	switch {
+	case name == "0":
+		return f.Name, true
+	case name == "*":
+		return f.Args, true
+	case name == "L":
+		if len(words) == 0 {
+			return "", false
+		}
+		return words[len(words)-1], true
+	case name == "-L":
+		if len(words) == 0 {
+			return "", false
+		}
+		return strings.Join(words[:len(words)-1], " "), true
+	}
+
+	skip := strings.HasPrefix(name, "-")
+	n, err := strconv.Atoi(strings.TrimPrefix(name, "-"))
+	if err != nil || n < 0 {
+		return "", false
+	}
+	if skip {
+		if n >= len(words) {
+			return "", true
+		}
+		return strings.Join(words[n:], " "), true
+	}
+	if n == 0 || n > len(words) {
+		return "", false
+	}
+	return words[n-1], true
+}
+
+// SetLocal - Set a variable local to the frame
+func (f *Frame) SetLocal(name, value string) {
+	if f.Vars == nil {
+		f.Vars = make(map[string]string)
+	}
+	f.Vars[name] = value
+}
+
+// Value - Find the value of a variable as seen from the frame f: a local
+// variable, a global one or one supplied by the Resolver, in that order.
+func (in *Interp) Value(f *Frame, name string) (string, bool) {
+	if value, ok := f.Vars[name]; ok {
+		return value, true
+	}
+	if value, ok := in.Var(name); ok {
+		return value, true
+	}
+	if in.Resolve != nil {
+		return in.Resolve(f, name)
+	}
+	return "", false
+}
+
+// Split - Break a macro body into its commands, which are separated by %;
+func Split(body string) []string {
+	var cmds []string
+	start := 0
+	for idx := 0; idx < len(body)-1; idx++ {
+		if body[idx] != '%' {
+			continue
+		}
+		switch body[idx+1] {
+		case '%':
+			idx++
+		case ';':
+			cmds = append(cmds, body[start:idx])
+			start = idx + 2
+			idx++
+		}
+	}
+	return append(cmds, body[start:])
+}
+
+func isNameByte(ch byte, first bool) bool {
+	return ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || !first && ch >= '0' && ch <= '9'
+}
+
+// closing finds the index of the bracket closing the one opened before
+// text[start], skipping over quoted strings.
+func closing(text string, start int, open, close byte) int {
+	depth := 1
+	var quote byte
+	for idx := start; idx < len(text); idx++ {
+		ch := text[idx]
+		switch {
+		case quote != 0:
+			if ch == '\\' {
+				idx++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == open:
+			depth++
+		case ch == close:
+			depth--
+			if depth == 0 {
+				return idx
+			}
+		}
+	}
+	return -1
+}
+
+// braceEnd finds the } closing a %{ reference whose name starts at
+// text[start]. The default may hold references of its own.
+func braceEnd(text string, start int) int {
+	depth := 1
+	for idx := start; idx < len(text); idx++ {
+		switch text[idx] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return idx
+			}
+		}
+	}
+	return -1
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// Substitute - Replace the variable references in text: %{name} or %name by
+// the variable's value, %1 to %9, %*, %-N and %{L} by the positional
+// parameters of the frame, %{name-default} by default, itself substituted,
+// when name is not set or empty, $[expr] by the value of the expression and %% and $$ by % and $.
+func (in *Interp) Substitute(f *Frame, text string) (string, error) {
+	var b strings.Builder
+	for idx := 0; idx < len(text); idx++ {
+		ch := text[idx]
+		if idx == len(text)-1 || ch != '%' && ch != '$' {
+			b.WriteByte(ch)
+			continue
+		}
+
+		next := text[idx+1]
+		switch {
+		case next == ch:
+			b.WriteByte(ch)
+			idx++
+
+		case ch == '$' && next == '[':
+			end := closing(text, idx+2, '[', ']')
+			if end < 0 {
+				return "", errors.New("missing ] in $[ expression")
+			}
+			value, err := in.Expr(f, text[idx+2:end])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			idx = end
+
+		case ch == '%' && next == '{':
+			end := braceEnd(text, idx+2)
+			if end < 0 {
+				return "", errors.New("missing } in %{ reference")
+			}
+			name := text[idx+2 : end]
+			def, hasDefault := "", false
+			if dash := strings.IndexByte(name[min(1, len(name)):], '-') + 1; dash > 0 {
+				name, def, hasDefault = name[:dash], name[dash+1:], true
+			}
+			value, ok := f.Param(name)
+			if !ok && name != "" && name[0] != '-' && !isDigit(name[0]) && name != "*" {
+				value, ok = in.Value(f, name)
+			}
+			if hasDefault && (!ok || value == "") {
+				var err error
+				if value, err = in.Substitute(f, def); err != nil {
+					return "", err
+				}
+			}
+			b.WriteString(value)
+			idx = end
+
+		case ch == '%' && (isDigit(next) || next == '*'):
+			value, _ := f.Param(text[idx+1 : idx+2])
+			b.WriteString(value)
+			idx++
+
+		case ch == '%' && next == '-' && idx+2 < len(text) && isDigit(text[idx+2]):
+			end := idx + 2
+			for end < len(text) && isDigit(text[end]) {
+				end++
+			}
+			value, _ := f.Param(text[idx+1 : end])
+			b.WriteString(value)
+			idx = end - 1
+
+		case ch == '%' && isNameByte(next, true):
+			end := idx + 1
+			for end < len(text) && isNameByte(text[end], false) {
+				end++
+			}
+			value, _ := in.Value(f, text[idx+1:end])
+			b.WriteString(value)
+			idx = end - 1
+
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String(), nil
+}
+
+// Call - Run the body of the macro m in the frame f
+func (in *Interp) Call(m *Macro, f *Frame) error {
+	if f.depth > maxDepth {
+		return fmt.Errorf("/%s: macros nested too deeply", m.Name)
+	}
+	return in.Exec(f, m.Body)
+}
+
+// block is an /if or /while being run.
+type block struct {
+	loop    bool
+	running bool // the commands in the block are being run
+	taken   bool // a branch of an /if has been run
+	start   int  // the /while command, for looping
+	count   int
+}
+
+// splitWord splits a command into its first word and the rest.
+func splitWord(cmd string) (string, string) {
+	if space := strings.IndexAny(cmd, " \t("); space >= 0 {
+		return cmd[:space], strings.TrimSpace(cmd[space:])
+	}
+	return cmd, ""
+}
+
+// separateControl puts the command following the condition of /if, /elseif
+// and /while, or following /else, into a command of its own.
+func separateControl(cmds []string) []string {
+	var separated []string
+	for _, cmd := range cmds {
+		cmd = strings.TrimSpace(cmd)
+		word, rest := splitWord(cmd)
+		after := ""
+		switch strings.ToLower(word) {
+		case "/if", "/elseif", "/while":
+			if strings.HasPrefix(rest, "(") {
+				if end := closing(rest, 1, '(', ')'); end >= 0 {
+					cmd, after = word+" "+rest[:end+1], strings.TrimSpace(rest[end+1:])
+				}
+			}
+		case "/else":
+			cmd, after = word, rest
+		}
+		separated = append(separated, cmd)
+		if after != "" {
+			separated = append(separated, separateControl([]string{after})...)
+		}
+	}
+	return separated
+}
+
+// Exec - Run a macro body in the frame f. The commands separated by %; are
+// run in order after substituting variables. Slash commands are evaluated
+// and any other text is passed to Send. The body may use
+//
+//	/if (expr) cmd %; /elseif (expr) cmd %; /else cmd %; /endif
+//	/while (expr) cmd %; /done
+//
+// where each cmd is optional. An /if left open at the end of the body is
+// closed there.
+func (in *Interp) Exec(f *Frame, body string) error {
+	cmds := separateControl(Split(body))
+	var stack []*block
+	running := func() bool {
+		return len(stack) == 0 || stack[len(stack)-1].running
+	}
+
+	for idx := 0; idx < len(cmds); idx++ {
+		cmd := cmds[idx]
+		word, rest := splitWord(cmd)
+
+		var top *block
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+		outer := len(stack) < 2 || stack[len(stack)-2].running
+
+		switch strings.ToLower(word) {
+		case "/if", "/while":
+			b := &block{loop: word[1] == 'w' || word[1] == 'W', start: idx}
+			if top != nil && top.loop && top.start == idx {
+				// Back at the top of a loop that is running again.
+				b = top
+				stack = stack[:len(stack)-1]
+			}
+			enclosing := running()
+			stack = append(stack, b)
+			if !enclosing {
+				b.running, b.taken = false, true
+				continue
+			}
+			cond, err := in.condition(f, rest)
+			if err != nil {
+				return err
+			}
+			b.running, b.taken = cond, cond
+
+		case "/elseif", "/else":
+			if top == nil || top.loop {
+				return fmt.Errorf("%s without /if", word)
+			}
+			if top.taken || !outer {
+				top.running = false
+				continue
+			}
+			if strings.EqualFold(word, "/elseif") {
+				cond, err := in.condition(f, rest)
+				if err != nil {
+					return err
+				}
+				if !cond {
+					continue
+				}
+			}
+			top.running, top.taken = true, true
+
+		case "/endif":
+			if top == nil || top.loop {
+				return errors.New("/endif without /if")
+			}
+			stack = stack[:len(stack)-1]
+
+		case "/done":
+			if top == nil || !top.loop {
+				return errors.New("/done without /while")
+			}
+			if !top.running {
+				stack = stack[:len(stack)-1]
+				continue
+			}
+			top.count++
+			if top.count >= maxIterations {
+				return fmt.Errorf("/while: loop ran %d times", maxIterations)
+			}
+			idx = top.start - 1
+
+		default:
+			if running() && cmd != "" {
+				if err := in.run(f, cmd); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for _, b := range stack {
+		if b.loop {
+			return errors.New("/while without /done")
+		}
+	}
+	return nil
+}
+
+// condition evaluates the parenthesized condition of /if and friends.
+func (in *Interp) condition(f *Frame, text string) (bool, error) {
+	if !strings.HasPrefix(text, "(") || !strings.HasSuffix(text, ")") {
+		return false, errors.New("condition must be in parentheses")
+	}
+	expr, err := in.Substitute(f, text[1:len(text)-1])
+	if err != nil {
+		return false, err
+	}
+	value, err := in.Expr(f, expr)
+	if err != nil {
+		return false, err
+	}
+	return truth(value), nil
+}
+
+// Input - Run a line of input in the frame f. Slash commands are evaluated,
+// a line starting with the name of a macro without a trigger or hook runs
+// the macro with the rest of the line as its arguments, and anything else
+// is passed to Send. An alias is not expanded again from its own body, so
+// "/alias look look %*%; glance" sends look followed by glance.
+func (in *Interp) Input(f *Frame, line string) error {
+	err := in.EvalFrame(f, line)
+	if err != ErrNotCommand {
+		return err
+	}
+
+	word, args := line, ""
+	if idx := strings.IndexAny(line, " \t"); idx >= 0 {
+		word, args = line[:idx], strings.TrimSpace(line[idx+1:])
+	}
+	if m := in.Macro(word); m != nil && m.IsAlias() && !f.within(m.Name) {
+		return in.Call(m, f.child(m.Name, args))
+	}
+
+	if in.Send == nil {
+		return fmt.Errorf("%s: nowhere to send text", line)
+	}
+	return in.Send(f, line)
+}
+
+// run substitutes variables in a single command and runs it.
+func (in *Interp) run(f *Frame, cmd string) error {
+	text, err := in.Substitute(f, cmd)
+	if err != nil {
+		return err
+	}
+
+	return in.Input(f, text)
+}
+
+// cmdLet implements "/let name=value", which sets a local variable.
+func (in *Interp) cmdLet(f *Frame, args string) error {
+	name, value := splitAssignment(args)
+	if name == "" {
+		return errors.New("usage: /let name=value")
+	}
+	f.SetLocal(name, value)
+	return nil
+}
+
+// cmdTest implements "/test expr", evaluating expr for its side effects.
+func (in *Interp) cmdTest(f *Frame, args string) error {
+	_, err := in.Expr(f, args)
+	return err
+}
@@ -0,0 +1,450 @@
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Expressions are evaluated on strings, the only type in the language. A
+// string that is a whole number is used as one by the arithmetic and
+// comparison operators, and any other string counts as zero there. In order
+// of increasing precedence the operators are
+//
+//	|                       logical or
+//	&                       logical and
+//	== != =~ !~ =/ !/       equal, string equal, glob match
+//	< <= > >=
+//	+ -
+//	* /
+//	! -                     unary
+//
+// Bare names are variables and name(args) calls one of the functions below.
+
+type exprToken struct {
+	kind byte // 'n'umber, 's'tring, 'i'dentifier, 'o'perator or 0 at the end
+	text string
+}
+
+var exprOperators = []string{"==", "!=", "=~", "!~", "=/", "!/", "<=", ">=", "<", ">", "+", "-", "*", "/", "!", "&", "|", "(", ")", ","}
+
+func lexExpr(expr string) ([]exprToken, error) {
+	var tokens []exprToken
+	for idx := 0; idx < len(expr); {
+		ch := expr[idx]
+		switch {
+		case ch == ' ' || ch == '\t':
+			idx++
+
+		case ch >= '0' && ch <= '9':
+			end := idx
+			for end < len(expr) && expr[end] >= '0' && expr[end] <= '9' {
+				end++
+			}
+			tokens = append(tokens, exprToken{'n', expr[idx:end]})
+			idx = end
+
+		case isNameByte(ch, true):
+			end := idx
+			for end < len(expr) && isNameByte(expr[end], false) {
+				end++
+			}
+			tokens = append(tokens, exprToken{'i', expr[idx:end]})
+			idx = end
+
+		case ch == '"' || ch == '\'':
+			var b strings.Builder
+			end := idx + 1
+			for ; end < len(expr) && expr[end] != ch; end++ {
+				if expr[end] == '\\' && end+1 < len(expr) {
+					end++
+				}
+				b.WriteByte(expr[end])
+			}
+			if end >= len(expr) {
+				return nil, errors.New("unterminated string in expression")
+			}
+			tokens = append(tokens, exprToken{'s', b.String()})
+			idx = end + 1
+
+		default:
+			op := ""
+			for _, candidate := range exprOperators {
+				if strings.HasPrefix(expr[idx:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q in expression", ch)
+			}
+			tokens = append(tokens, exprToken{'o', op})
+			idx += len(op)
+		}
+	}
+	return append(tokens, exprToken{}), nil
+}
+
+type exprParser struct {
+	in     *Interp
+	frame  *Frame
+	tokens []exprToken
+	pos    int
+}
+
+// Expr - Evaluate an expression in the frame f
+func (in *Interp) Expr(f *Frame, expr string) (string, error) {
+	tokens, err := lexExpr(expr)
+	if err != nil {
+		return "", err
+	}
+	p := &exprParser{in: in, frame: f, tokens: tokens}
+	value, err := p.binary(0)
+	if err != nil {
+		return "", err
+	}
+	if tok := p.peek(); tok.kind != 0 {
+		return "", fmt.Errorf("unexpected %q in expression", tok.text)
+	}
+	return value, nil
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != 0 {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *exprParser) expect(op string) error {
+	if tok := p.next(); tok.kind != 'o' || tok.text != op {
+		return fmt.Errorf("expected %q in expression", op)
+	}
+	return nil
+}
+
+// exprLevels lists the binary operators from the lowest precedence up.
+var exprLevels = [][]string{
+	{"|"},
+	{"&"},
+	{"==", "!=", "=~", "!~", "=/", "!/"},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/"},
+}
+
+func (p *exprParser) binary(level int) (string, error) {
+	if level == len(exprLevels) {
+		return p.unary()
+	}
+
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return "", err
+	}
+	for {
+		tok := p.peek()
+		if tok.kind != 'o' || !contains(exprLevels[level], tok.text) {
+			return left, nil
+		}
+		p.next()
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return "", err
+		}
+		if left, err = apply(tok.text, left, right); err != nil {
+			return "", err
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *exprParser) unary() (string, error) {
+	tok := p.peek()
+	if tok.kind == 'o' && (tok.text == "!" || tok.text == "-") {
+		p.next()
+		value, err := p.unary()
+		if err != nil {
+			return "", err
+		}
+		if tok.text == "!" {
+			return boolString(!truth(value)), nil
+		}
+		return strconv.FormatInt(-number(value), 10), nil
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (string, error) {
+	tok := p.next()
+	switch tok.kind {
+	case 'n', 's':
+		return tok.text, nil
+
+	case 'i':
+		if next := p.peek(); next.kind != 'o' || next.text != "(" {
+			value, _ := p.in.Value(p.frame, tok.text)
+			return value, nil
+		}
+		p.next()
+		var args []string
+		for {
+			if next := p.peek(); next.kind == 'o' && next.text == ")" {
+				p.next()
+				break
+			}
+			if len(args) > 0 {
+				if err := p.expect(","); err != nil {
+					return "", err
+				}
+			}
+			arg, err := p.binary(0)
+			if err != nil {
+				return "", err
+			}
+			args = append(args, arg)
+		}
+		return p.call(tok.text, args)
+
+	case 'o':
+		if tok.text == "(" {
+			value, err := p.binary(0)
+			if err != nil {
+				return "", err
+			}
+			return value, p.expect(")")
+		}
+	}
+
+	if tok.kind == 0 {
+		return "", errors.New("unexpected end of expression")
+	}
+	return "", fmt.Errorf("unexpected %q in expression", tok.text)
+}
+
+func truth(value string) bool {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n != 0
+	}
+	return value != ""
+}
+
+func boolString(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+func number(value string) int64 {
+	n, _ := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	return n
+}
+
+func isNumber(value string) bool {
+	_, err := strconv.ParseInt(value, 10, 64)
+	return err == nil
+}
+
+// compare orders two values, numerically when both are numbers.
+func compare(a, b string) int {
+	if isNumber(a) && isNumber(b) {
+		x, y := number(a), number(b)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+func apply(op, a, b string) (string, error) {
+	switch op {
+	case "|":
+		return boolString(truth(a) || truth(b)), nil
+	case "&":
+		return boolString(truth(a) && truth(b)), nil
+	case "==":
+		return boolString(compare(a, b) == 0), nil
+	case "!=":
+		return boolString(compare(a, b) != 0), nil
+	case "=~":
+		return boolString(a == b), nil
+	case "!~":
+		return boolString(a != b), nil
+	case "=/", "!/":
+		re, err := GlobRegexp(b)
+		if err != nil {
+			return "", err
+		}
+		return boolString(re.MatchString(a) == (op == "=/")), nil
+	case "<":
+		return boolString(compare(a, b) < 0), nil
+	case "<=":
+		return boolString(compare(a, b) <= 0), nil
+	case ">":
+		return boolString(compare(a, b) > 0), nil
+	case ">=":
+		return boolString(compare(a, b) >= 0), nil
+	case "+":
+		return strconv.FormatInt(number(a)+number(b), 10), nil
+	case "-":
+		return strconv.FormatInt(number(a)-number(b), 10), nil
+	case "*":
+		return strconv.FormatInt(number(a)*number(b), 10), nil
+	case "/":
+		if number(b) == 0 {
+			return "", errors.New("division by zero")
+		}
+		return strconv.FormatInt(number(a)/number(b), 10), nil
+	}
+	return "", fmt.Errorf("unknown operator %s", op)
+}
+
+// GlobRegexp - Convert a TinyFugue glob pattern into a regular expression
+// matching the whole of a string without regard to case. In the pattern *
+// matches any text, ? any single character, [...] a set of characters and
+// {a|b} either of the words a or b.
+func GlobRegexp(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?is)^")
+	for idx := 0; idx < len(glob); idx++ {
+		switch ch := glob[idx]; ch {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			end := strings.IndexByte(glob[idx:], ']')
+			if end < 0 {
+				return nil, errors.New("missing ] in glob pattern")
+			}
+			b.WriteString(glob[idx : idx+end+1])
+			idx += end
+		case '{':
+			end := strings.IndexByte(glob[idx:], '}')
+			if end < 0 {
+				return nil, errors.New("missing } in glob pattern")
+			}
+			words := strings.Split(glob[idx+1:idx+end], "|")
+			for i := range words {
+				words[i] = regexp.QuoteMeta(words[i])
+			}
+			b.WriteString("(?:" + strings.Join(words, "|") + ")")
+			idx += end
+		case '\\':
+			if idx+1 < len(glob) {
+				idx++
+			}
+			b.WriteString(regexp.QuoteMeta(glob[idx : idx+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// call runs one of the functions available to expressions.
+func (p *exprParser) call(name string, args []string) (string, error) {
+	arity := func(min, max int) error {
+		if len(args) < min || len(args) > max {
+			return fmt.Errorf("%s: wrong number of arguments", name)
+		}
+		return nil
+	}
+
+	switch strings.ToLower(name) {
+	case "strlen":
+		if err := arity(1, 1); err != nil {
+			return "", err
+		}
+		return strconv.Itoa(len(args[0])), nil
+
+	case "strcat":
+		return strings.Join(args, ""), nil
+
+	case "tolower", "toupper":
+		if err := arity(1, 1); err != nil {
+			return "", err
+		}
+		if strings.EqualFold(name, "tolower") {
+			return strings.ToLower(args[0]), nil
+		}
+		return strings.ToUpper(args[0]), nil
+
+	case "strstr":
+		if err := arity(2, 2); err != nil {
+			return "", err
+		}
+		return strconv.Itoa(strings.Index(args[0], args[1])), nil
+
+	case "substr":
+		if err := arity(2, 3); err != nil {
+			return "", err
+		}
+		s := args[0]
+		start := int(number(args[1]))
+		if start < 0 || start > len(s) {
+			return "", nil
+		}
+		end := len(s)
+		if len(args) == 3 {
+			if n := start + int(number(args[2])); n < end {
+				end = n
+			}
+		}
+		if end < start {
+			return "", nil
+		}
+		return s[start:end], nil
+
+	case "regmatch":
+		// regmatch(pattern, s) also sets P0 to P9 to the match.
+		if err := arity(2, 2); err != nil {
+			return "", err
+		}
+		re, err := regexp.Compile(args[0])
+		if err != nil {
+			return "", err
+		}
+		match := re.FindStringSubmatch(args[1])
+		if match == nil {
+			return "0", nil
+		}
+		SetMatch(p.frame, match)
+		return "1", nil
+	}
+
+	return "", fmt.Errorf("%s: no such function", name)
+}
+
+// SetMatch - Set the local variables P0 to P9 of the frame f to a regular
+// expression match and its subexpressions.
+func SetMatch(f *Frame, match []string) {
+	for idx := 0; idx < 10; idx++ {
+		value := ""
+		if idx < len(match) {
+			value = match[idx]
+		}
+		f.SetLocal("P"+strconv.Itoa(idx), value)
+	}
+}
@@ -0,0 +1,86 @@
+package interp_test
+
+import (
+	"testing"
+
+	"github.com/huntwj/gofugue/tflang/interp"
+)
+
+func TestExpr(t *testing.T) {
+	in := interp.New()
+	in.SetVar("health", "Wounded")
+	in.SetVar("count", "3")
+
+	tests := []struct {
+		expr, expected string
+	}{
+		{"1 + 2 * 3", "7"},
+		{"(1 + 2) * 3", "9"},
+		{"-count + 10 / 4", "-1"},
+		{"count >= 3 & count < 4", "1"},
+		{"health =~ 'Wounded' | 0", "1"},
+		{"health !~ \"Wounded\"", "0"},
+		{"health =/ 'w*d'", "1"},
+		{"health !/ '{healthy|fine}'", "1"},
+		{"10 > 9", "1"},
+		{"'10' == 10", "1"},
+		{"'abc' < 'abd'", "1"},
+		{"!missing", "1"},
+		{"strlen(health) + 1", "8"},
+		{"toupper(substr(health, 0, 3))", "WOU"},
+		{"strcat('a', count, \"b\")", "a3b"},
+		{"strstr(health, 'nd')", "3"},
+	}
+	for _, test := range tests {
+		value, err := in.Expr(&interp.Frame{}, test.expr)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", test.expr, err)
+		} else if value != test.expected {
+			t.Errorf("Expected %q to be '%s' but found '%s'", test.expr, test.expected, value)
+		}
+	}
+}
+
+func TestExprErrors(t *testing.T) {
+	in := interp.New()
+	for _, expr := range []string{"1 +", "(1", "1 / 0", "'open", "nosuch(1)", "strlen()", "1 2", "@"} {
+		if _, err := in.Expr(&interp.Frame{}, expr); err == nil {
+			t.Errorf("Expected an error for %q", expr)
+		}
+	}
+}
+
+func TestRegmatchSetsMatch(t *testing.T) {
+	in := interp.New()
+	f := &interp.Frame{}
+	value, err := in.Expr(f, `regmatch("(\\w+) tells you", "Talia tells you 'hi'")`)
+	if err != nil || value != "1" {
+		t.Fatalf("Expected a match but found '%s': %v", value, err)
+	}
+	if f.Vars["P1"] != "Talia" || f.Vars["P0"] != "Talia tells you" {
+		t.Errorf("Unexpected match variables %v", f.Vars)
+	}
+}
+
+func TestGlobRegexp(t *testing.T) {
+	tests := []struct {
+		glob, text string
+		match      bool
+	}{
+		{"*tells you*", "Talia tells you 'hi'", true},
+		{"?alia", "TALIA", true},
+		{"[ab]*", "cat", false},
+		{"{north|south}", "South", true},
+		{"{north|south}", "southwest", false},
+		{`a\*`, "a*", true},
+	}
+	for _, test := range tests {
+		re, err := interp.GlobRegexp(test.glob)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", test.glob, err)
+		}
+		if re.MatchString(test.text) != test.match {
+			t.Errorf("Expected %q matching %q to be %t", test.glob, test.text, test.match)
+		}
+	}
+}
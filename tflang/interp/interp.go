@@ -0,0 +1,230 @@
+package interp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/huntwj/gofugue/tflang/tokenizer"
+)
+
+// ErrNotCommand - Returned by Eval for input that is not a slash command
+var ErrNotCommand = errors.New("not a slash command")
+
+// Command - Implementation of a slash command. It receives the text following
+// the command name with surrounding whitespace removed.
+type Command func(args string) error
+
+// FrameCommand - Implementation of a slash command that needs to know the
+// frame it runs in, such as the world a trigger fired for.
+type FrameCommand func(f *Frame, args string) error
+
+// A Resolver supplies the value of variables that are not set, such as
+// information from the prompt. It reports false for names it does not know.
+type Resolver func(f *Frame, name string) (string, bool)
+
+// Interp - Data structure holding the slash commands, macros and global
+// variables known to the client.
+type Interp struct {
+	// Resolve, when set, is asked for variables that are neither local nor
+	// global.
+	Resolve Resolver
+	// OnDefine and OnUndefine, when set, are told about macros as they are
+	// defined and removed, so triggers and hooks can be attached to them.
+	OnDefine   func(m *Macro) error
+	OnUndefine func(m *Macro)
+	// Send is given the lines of macro bodies that are not slash commands.
+	Send FrameCommand
+	// Output shows the results of commands such as /list to the user.
+	Output func(text string)
+
+	mu       sync.RWMutex
+	commands map[string]FrameCommand
+	vars     map[string]string
+	macros   map[string]*Macro
+	nextID   int
+}
+
+// New - Create an interpreter with only the language commands defined
+func New() *Interp {
+	in := &Interp{
+		commands: make(map[string]FrameCommand),
+		vars:     make(map[string]string),
+		macros:   make(map[string]*Macro),
+	}
+	in.Register("set", in.cmdSet)
+	in.Register("unset", in.cmdUnset)
+	in.RegisterFrame("let", in.cmdLet)
+	in.RegisterFrame("test", in.cmdTest)
+	in.Register("def", in.cmdDef)
+	in.Register("undef", in.cmdUndef)
+	in.Register("list", in.cmdList)
+	in.Register("alias", in.cmdAlias)
+	in.Register("unalias", in.cmdUnalias)
+	in.Register("gag", in.cmdGag)
+	in.Register("nogag", in.cmdNoGag)
+	in.Register("hilite", in.cmdHilite)
+	in.Register("nohilite", in.cmdNoHilite)
+	in.Register("substitute", in.cmdSubstitute)
+	in.Register("nosubstitute", in.cmdNoSubstitute)
+	return in
+}
+
+// Register - Make a command available as /name. Command names are not case
+// sensitive. Registering an existing name replaces the old command.
+func (in *Interp) Register(name string, cmd Command) {
+	in.RegisterFrame(name, func(f *Frame, args string) error {
+		return cmd(args)
+	})
+}
+
+// RegisterFrame - Make a command that uses its frame available as /name
+func (in *Interp) RegisterFrame(name string, cmd FrameCommand) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	in.commands[strings.ToLower(name)] = cmd
+}
+
+// Lookup - Find the command registered under name
+func (in *Interp) Lookup(name string) (Command, bool) {
+	cmd, ok := in.lookup(name)
+	if !ok {
+		return nil, false
+	}
+	return func(args string) error {
+		return cmd(&Frame{}, args)
+	}, true
+}
+
+func (in *Interp) lookup(name string) (FrameCommand, bool) {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+
+	cmd, ok := in.commands[strings.ToLower(name)]
+	return cmd, ok
+}
+
+// SetVar - Set a global variable
+func (in *Interp) SetVar(name, value string) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	in.vars[name] = value
+}
+
+// Var - Get the value of a global variable
+func (in *Interp) Var(name string) (string, bool) {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+
+	value, ok := in.vars[name]
+	return value, ok
+}
+
+// UnsetVar - Remove a global variable
+func (in *Interp) UnsetVar(name string) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	delete(in.vars, name)
+}
+
+// splitAssignment splits "name=value" or "name value".
+func splitAssignment(args string) (string, string) {
+	if idx := strings.IndexAny(args, "= "); idx >= 0 {
+		return args[:idx], args[idx+1:]
+	}
+	return args, ""
+}
+
+// cmdSet implements "/set name=value" and "/set name value".
+func (in *Interp) cmdSet(args string) error {
+	name, value := splitAssignment(args)
+	if name == "" {
+		return errors.New("usage: /set name=value")
+	}
+	in.SetVar(name, value)
+	return nil
+}
+
+func (in *Interp) cmdUnset(args string) error {
+	if args == "" {
+		return errors.New("usage: /unset name")
+	}
+	in.UnsetVar(args)
+	return nil
+}
+
+// Eval - Run a single slash command such as "/world Freddie"
+func (in *Interp) Eval(line string) error {
+	return in.EvalFrame(&Frame{}, line)
+}
+
+// EvalFrame - Run a single slash command in the frame f. A macro is run in
+// a new frame for the same world.
+func (in *Interp) EvalFrame(f *Frame, line string) error {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "/") {
+		return ErrNotCommand
+	}
+
+	tok := tokenizer.Tokenize(line)
+	tokens := tok.Tokens()
+	first := <-tokens
+	for range tokens {
+	}
+	if first.Type != tokenizer.SlashCmd {
+		return ErrNotCommand
+	}
+
+	// The token holds the name as runes, in which invalid UTF-8 in the line
+	// takes up a different number of bytes, so it is cut from the line.
+	end := strings.IndexFunc(line, unicode.IsSpace)
+	if end < 0 {
+		end = len(line)
+	}
+	name := line[1:end]
+	args := strings.TrimSpace(line[end:])
+	if m := in.Macro(name); m != nil {
+		return in.Call(m, f.child(m.Name, args))
+	}
+	cmd, ok := in.lookup(name)
+	if !ok {
+		return fmt.Errorf("%s: no such command", first.Text)
+	}
+
+	return cmd(f, args)
+}
+
+// Load - Evaluate every command in a script. Blank lines and lines starting
+// with ';' are ignored, and a line ending in '\' continues on the next line.
+// Loading stops at the first failing command.
+func (in *Interp) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	cmd := ""
+	for scanner.Scan() {
+		lineNo++
+		text := strings.TrimRight(scanner.Text(), " \t")
+		if cmd == "" && (text == "" || strings.HasPrefix(text, ";")) {
+			continue
+		}
+		if strings.HasSuffix(text, "\\") {
+			cmd += strings.TrimSuffix(text, "\\")
+			continue
+		}
+
+		cmd += text
+		if err := in.Eval(cmd); err != nil {
+			return fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		cmd = ""
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,112 @@
+package interp_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/huntwj/gofugue/tflang/interp"
+)
+
+func TestEvalCommand(t *testing.T) {
+	in := interp.New()
+	var observed string
+	in.Register("world", func(args string) error {
+		observed = args
+		return nil
+	})
+
+	if err := in.Eval("/WORLD   Freddie  "); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if observed != "Freddie" {
+		t.Errorf("Expected args 'Freddie' but found '%s'", observed)
+	}
+}
+
+func TestEvalNotCommand(t *testing.T) {
+	in := interp.New()
+	if err := in.Eval("kill ancient"); err != interp.ErrNotCommand {
+		t.Errorf("Expected ErrNotCommand but found %v", err)
+	}
+}
+
+func TestEvalUnknownCommand(t *testing.T) {
+	in := interp.New()
+	if err := in.Eval("/nosuch thing"); err == nil {
+		t.Error("Expected an error for an unknown command")
+	}
+}
+
+func TestEvalInvalidUTF8(t *testing.T) {
+	in := interp.New()
+	var observed string
+	in.Register("echo", func(args string) error {
+		observed = args
+		return nil
+	})
+
+	if err := in.Eval("/\xf8"); err == nil {
+		t.Error("Expected an error for an unknown command")
+	}
+	if err := in.Eval("/echo \xf8\xf8 x"); err != nil || observed != "\xf8\xf8 x" {
+		t.Errorf("Expected args %q but found %q and %v", "\xf8\xf8 x", observed, err)
+	}
+}
+
+func TestLoadScript(t *testing.T) {
+	in := interp.New()
+	var observed []string
+	in.Register("addworld", func(args string) error {
+		observed = append(observed, args)
+		return nil
+	})
+
+	script := `; worlds for the team
+/addworld Freddie game.wotmud.org 2224
+
+/addworld Talia \
+  game.wotmud.org 2224
+`
+	if err := in.Load(strings.NewReader(script)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{"Freddie game.wotmud.org 2224", "Talia   game.wotmud.org 2224"}
+	if len(observed) != len(expected) {
+		t.Fatalf("Expected %d commands but found %d: %q", len(expected), len(observed), observed)
+	}
+	for idx := range expected {
+		if observed[idx] != expected[idx] {
+			t.Errorf("Expected '%s' but found '%s'", expected[idx], observed[idx])
+		}
+	}
+}
+
+func TestLoadStopsOnError(t *testing.T) {
+	in := interp.New()
+	err := in.Load(strings.NewReader("/nosuch\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("Expected error on line 1 but found %v", err)
+	}
+}
+
+func TestSetVariables(t *testing.T) {
+	in := interp.New()
+
+	for _, cmd := range []string{"/set target=ancient tree", "/set weapon staff"} {
+		if err := in.Eval(cmd); err != nil {
+			t.Fatalf("Unexpected error for %q: %v", cmd, err)
+		}
+	}
+	if value, ok := in.Var("target"); !ok || value != "ancient tree" {
+		t.Errorf("Expected target 'ancient tree' but found '%s' (%t)", value, ok)
+	}
+	if value, _ := in.Var("weapon"); value != "staff" {
+		t.Errorf("Expected weapon 'staff' but found '%s'", value)
+	}
+
+	in.Eval("/unset target")
+	if _, ok := in.Var("target"); ok {
+		t.Error("Expected target to be unset")
+	}
+}
@@ -0,0 +1,290 @@
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A Macro is a named body of commands defined with /def. A macro is run when
+// it is called as /name, when output matches its Trigger or when its Hook
+// fires.
+type Macro struct {
+	Name string
+	Body string
+	// Trigger, when set, runs the macro for lines of output it matches.
+	Trigger *regexp.Regexp
+	// Hook, when set, names the hook that runs the macro.
+	Hook string
+	// World, when set, limits the trigger and hook to that world.
+	World string
+	// Attr holds the display attributes, given with -a, for the lines the
+	// trigger matches. See AttrCodes.
+	Attr string
+	// Subst, when set, replaces the text the trigger matches, as made by
+	// /substitute.
+	Subst string
+}
+
+// Macro - Find the macro defined under name
+func (in *Interp) Macro(name string) *Macro {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+
+	return in.macros[strings.ToLower(name)]
+}
+
+// Macros - All defined macros in name order
+func (in *Interp) Macros() []*Macro {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+
+	macros := make([]*Macro, 0, len(in.macros))
+	for _, m := range in.macros {
+		macros = append(macros, m)
+	}
+	sort.Slice(macros, func(i, j int) bool {
+		return macros[i].Name < macros[j].Name
+	})
+	return macros
+}
+
+// Define - Add a macro, replacing any macro of the same name. A macro
+// without a name is given one. When OnDefine fails, the macro it would have
+// replaced is kept.
+func (in *Interp) Define(m *Macro) error {
+	in.mu.Lock()
+	if m.Name == "" {
+		in.nextID++
+		m.Name = fmt.Sprintf("#%d", in.nextID)
+	}
+	key := strings.ToLower(m.Name)
+	in.mu.Unlock()
+
+	if in.OnDefine != nil {
+		if err := in.OnDefine(m); err != nil {
+			return err
+		}
+	}
+
+	in.mu.Lock()
+	old := in.macros[key]
+	in.macros[key] = m
+	in.mu.Unlock()
+
+	if old != nil && in.OnUndefine != nil {
+		in.OnUndefine(old)
+	}
+	return nil
+}
+
+// Undefine - Remove the named macro, reporting whether it existed
+func (in *Interp) Undefine(name string) bool {
+	key := strings.ToLower(name)
+	in.mu.Lock()
+	m := in.macros[key]
+	delete(in.macros, key)
+	in.mu.Unlock()
+
+	if m != nil && in.OnUndefine != nil {
+		in.OnUndefine(m)
+	}
+	return m != nil
+}
+
+// ParseOptions - Split leading options such as -t"pattern" or -wFreddie off
+// args. Option values may be quoted with " or ', and -- ends the options.
+func ParseOptions(args string) (map[byte]string, string, error) {
+	opts := make(map[byte]string)
+	for {
+		args = strings.TrimLeft(args, " \t")
+		if !strings.HasPrefix(args, "-") || len(args) < 2 {
+			return opts, args, nil
+		}
+		if strings.HasPrefix(args, "--") {
+			return opts, strings.TrimLeft(args[2:], " \t"), nil
+		}
+
+		name := args[1]
+		args = args[2:]
+		value := ""
+		if len(args) > 0 && (args[0] == '"' || args[0] == '\'') {
+			quote := args[0]
+			var b strings.Builder
+			idx := 1
+			for ; idx < len(args) && args[idx] != quote; idx++ {
+				if args[idx] == '\\' && idx+1 < len(args) && args[idx+1] == quote {
+					idx++
+				}
+				b.WriteByte(args[idx])
+			}
+			if idx >= len(args) {
+				return nil, "", fmt.Errorf("-%c: missing closing quote", name)
+			}
+			value, args = b.String(), args[idx+1:]
+		} else {
+			end := strings.IndexAny(args, " \t")
+			if end < 0 {
+				end = len(args)
+			}
+			value, args = args[:end], args[end:]
+		}
+		opts[name] = value
+	}
+}
+
+// compilePattern compiles a trigger pattern written in the given matching
+// style: regexp, glob or simple, which must match the whole line.
+func compilePattern(style, pattern string) (*regexp.Regexp, error) {
+	switch style {
+	case "", "regexp":
+		return regexp.Compile(pattern)
+	case "glob":
+		return GlobRegexp(pattern)
+	case "simple":
+		return regexp.Compile("^" + regexp.QuoteMeta(pattern) + "$")
+	}
+	return nil, fmt.Errorf("-m%s: unknown matching style", style)
+}
+
+// cmdDef implements
+//
+//	/def [-t"pattern"] [-mregexp|glob|simple] [-h"hook"] [-w"world"] [-a"attrs"] [name] [= body]
+func (in *Interp) cmdDef(args string) error {
+	opts, rest, err := ParseOptions(args)
+	if err != nil {
+		return err
+	}
+	for opt := range opts {
+		if !strings.ContainsRune("tmhwa", rune(opt)) {
+			return fmt.Errorf("-%c: unknown option", opt)
+		}
+	}
+
+	name, body := rest, ""
+	if idx := strings.IndexByte(rest, '='); idx >= 0 {
+		name, body = rest[:idx], strings.TrimLeft(rest[idx+1:], " \t")
+	}
+	name = strings.TrimSpace(name)
+	if strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("%s: macro names cannot contain spaces", name)
+	}
+
+	m := &Macro{Name: name, Body: body, Hook: strings.ToUpper(opts['h']), World: opts['w'], Attr: opts['a']}
+	if _, _, err := AttrCodes(m.Attr); err != nil {
+		return err
+	}
+	if pattern, ok := opts['t']; ok {
+		if m.Trigger, err = compilePattern(opts['m'], pattern); err != nil {
+			return err
+		}
+	}
+	if name == "" && m.Trigger == nil && m.Hook == "" {
+		return errors.New("usage: /def [-t\"pattern\"] [-h\"hook\"] [-w\"world\"] [-a\"attrs\"] name = body")
+	}
+	return in.Define(m)
+}
+
+func (in *Interp) cmdUndef(args string) error {
+	if args == "" {
+		return errors.New("usage: /undef name")
+	}
+	for _, name := range strings.Fields(args) {
+		if !in.Undefine(name) {
+			return fmt.Errorf("%s: no such macro", name)
+		}
+	}
+	return nil
+}
+
+// String - The /def command that defines the macro
+func (m *Macro) String() string {
+	var b strings.Builder
+	if m.Subst != "" {
+		b.WriteString("/substitute")
+		if m.World != "" {
+			fmt.Fprintf(&b, " -w%q", m.World)
+		}
+		fmt.Fprintf(&b, " %s = %s", m.Trigger, m.Subst)
+		return b.String()
+	}
+
+	b.WriteString("/def")
+	if m.Attr != "" {
+		fmt.Fprintf(&b, " -a%q", m.Attr)
+	}
+	if m.Trigger != nil {
+		fmt.Fprintf(&b, " -t%q", m.Trigger.String())
+	}
+	if m.Hook != "" {
+		fmt.Fprintf(&b, " -h%q", m.Hook)
+	}
+	if m.World != "" {
+		fmt.Fprintf(&b, " -w%q", m.World)
+	}
+	fmt.Fprintf(&b, " %s = %s", m.Name, m.Body)
+	return b.String()
+}
+
+// cmdList implements "/list [glob]", showing the matching macros.
+func (in *Interp) cmdList(args string) error {
+	pattern := args
+	if pattern == "" {
+		pattern = "*"
+	}
+	re, err := GlobRegexp(pattern)
+	if err != nil {
+		return err
+	}
+	for _, m := range in.Macros() {
+		if re.MatchString(m.Name) && in.Output != nil {
+			in.Output(m.String())
+		}
+	}
+	return nil
+}
+
+// IsAlias - Whether the macro is run by typing its name rather than by a
+// trigger or hook
+func (m *Macro) IsAlias() bool {
+	return m.Trigger == nil && m.Hook == ""
+}
+
+// cmdAlias implements "/alias name body", the same as "/def name = body".
+// With only a name it shows that alias, and with no arguments all of them.
+func (in *Interp) cmdAlias(args string) error {
+	name, body := splitWord(args)
+	if body == "" {
+		for _, m := range in.Macros() {
+			if !m.IsAlias() || (name != "" && !strings.EqualFold(m.Name, name)) {
+				continue
+			}
+			if in.Output != nil {
+				in.Output(fmt.Sprintf("/alias %s %s", m.Name, m.Body))
+			}
+			if name != "" {
+				return nil
+			}
+		}
+		if name != "" {
+			return fmt.Errorf("%s: no such alias", name)
+		}
+		return nil
+	}
+	return in.Define(&Macro{Name: name, Body: body})
+}
+
+func (in *Interp) cmdUnalias(args string) error {
+	if args == "" {
+		return errors.New("usage: /unalias name")
+	}
+	for _, name := range strings.Fields(args) {
+		if m := in.Macro(name); m == nil || !m.IsAlias() {
+			return fmt.Errorf("%s: no such alias", name)
+		}
+		in.Undefine(name)
+	}
+	return nil
+}
@@ -0,0 +1,348 @@
+package interp_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/huntwj/gofugue/tflang/interp"
+)
+
+// recorder collects what an interpreter sends and outputs.
+type recorder struct {
+	sent   []string
+	output []string
+}
+
+func newRecordingInterp(r *recorder) *interp.Interp {
+	in := interp.New()
+	in.Send = func(f *interp.Frame, text string) error {
+		r.sent = append(r.sent, text)
+		return nil
+	}
+	in.Output = func(text string) {
+		r.output = append(r.output, text)
+	}
+	return in
+}
+
+func expectSent(t *testing.T, r *recorder, expected ...string) {
+	t.Helper()
+
+	if strings.Join(r.sent, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected to send %q but found %q", expected, r.sent)
+	}
+	r.sent = nil
+}
+
+func TestSplit(t *testing.T) {
+	cmds := interp.Split("kill ancient %; /echo 100%% %;rest")
+	expected := []string{"kill ancient ", " /echo 100%% ", "rest"}
+	if strings.Join(cmds, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected %q but found %q", expected, cmds)
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	in := interp.New()
+	in.SetVar("target", "ancient")
+	in.Resolve = func(f *interp.Frame, name string) (string, bool) {
+		if name == "world_name" {
+			return f.World, true
+		}
+		return "", false
+	}
+	f := &interp.Frame{World: "Freddie", Name: "greet", Args: "Talia  the Aiel", Vars: map[string]string{"P1": "Talia"}}
+
+	tests := []struct {
+		text, expected string
+	}{
+		{"kill %target", "kill ancient"},
+		{"kill %{target}s", "kill ancients"},
+		{"tell %{P1} hi from %{world_name}", "tell Talia hi from Freddie"},
+		{"wield %{weapon-staff}", "wield staff"},
+		{"say %missing.", "say ."},
+		{"say 100%% $$5", "say 100% $5"},
+		{"say $[1 + 2] %%1", "say 3 %1"},
+		{"%0 %1 and %3", "greet Talia and Aiel"},
+		{"%2 %{4-nobody} %{1-nobody}", "the nobody Talia"},
+		{"tell %1 %-1", "tell Talia the Aiel"},
+		{"say %*", "say Talia  the Aiel"},
+		{"%{L} %{-L}", "Aiel Talia the"},
+		{"%", "%"},
+	}
+	for _, test := range tests {
+		value, err := in.Substitute(f, test.text)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", test.text, err)
+		} else if value != test.expected {
+			t.Errorf("Expected %q to become %q but found %q", test.text, test.expected, value)
+		}
+	}
+}
+
+func TestDefineAndCall(t *testing.T) {
+	var r recorder
+	in := newRecordingInterp(&r)
+
+	if err := in.Eval("/def kk = /set target=ancient %; kill %target %; /echo done"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	in.Register("echo", func(args string) error {
+		r.output = append(r.output, args)
+		return nil
+	})
+	if err := in.Eval("/kk"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expectSent(t, &r, "kill ancient")
+	if len(r.output) != 1 || r.output[0] != "done" {
+		t.Errorf("Expected output 'done' but found %q", r.output)
+	}
+
+	if err := in.Eval("/undef kk"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := in.Eval("/kk"); err == nil {
+		t.Error("Expected an error calling an undefined macro")
+	}
+}
+
+func TestDefOptions(t *testing.T) {
+	in := interp.New()
+	var defined []*interp.Macro
+	in.OnDefine = func(m *interp.Macro) error {
+		defined = append(defined, m)
+		return nil
+	}
+
+	if err := in.Eval(`/def -t"^(\w+) tells you '(.*)'$" -w'Freddie' reply = tell %P1 afk`); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := in.Eval(`/def -mglob -t"*arrives from the *" = look`); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := in.Eval(`/def -hconnect = /echo hello`); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(defined) != 3 {
+		t.Fatalf("Expected 3 macros to be defined but found %d", len(defined))
+	}
+
+	m := defined[0]
+	if m.Name != "reply" || m.World != "Freddie" || m.Body != "tell %P1 afk" {
+		t.Errorf("Unexpected macro %+v", *m)
+	}
+	if match := m.Trigger.FindStringSubmatch("Talia tells you 'hi'"); len(match) != 3 || match[1] != "Talia" {
+		t.Errorf("Unexpected trigger match %q", match)
+	}
+	if defined[1].Name == "" || !defined[1].Trigger.MatchString("A guard arrives from the north.") {
+		t.Errorf("Expected a named glob trigger but found %+v", *defined[1])
+	}
+	if defined[2].Hook != "CONNECT" {
+		t.Errorf("Expected hook CONNECT but found '%s'", defined[2].Hook)
+	}
+
+	for _, cmd := range []string{"/def", "/def -x foo = bar", `/def -t"(" foo = bar`, "/def -mfuzzy -tfoo = bar", `/def -t"open foo = bar`} {
+		if err := in.Eval(cmd); err == nil {
+			t.Errorf("Expected an error for %q", cmd)
+		}
+	}
+}
+
+func TestRedefineFailure(t *testing.T) {
+	var r recorder
+	in := newRecordingInterp(&r)
+	var undefined []string
+	in.OnDefine = func(m *interp.Macro) error {
+		if m.Body == "broken" {
+			return errors.New("cannot attach")
+		}
+		return nil
+	}
+	in.OnUndefine = func(m *interp.Macro) {
+		undefined = append(undefined, m.Body)
+	}
+
+	if err := in.Eval("/def kk = kill ancient"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := in.Eval("/def kk = broken"); err == nil {
+		t.Error("Expected the failure to define the macro to be reported")
+	}
+	if len(undefined) != 0 {
+		t.Errorf("Expected the old macro to stay attached but found %q undefined", undefined)
+	}
+	if err := in.Eval("/kk"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expectSent(t, &r, "kill ancient")
+
+	if err := in.Eval("/def kk = kill rat"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(undefined, "|") != "kill ancient" {
+		t.Errorf("Expected the replaced macro to be undefined but found %q", undefined)
+	}
+}
+
+func TestIfElse(t *testing.T) {
+	var r recorder
+	in := newRecordingInterp(&r)
+	in.Eval("/def heal = /if (hp < 50) quaff %; /elseif (hp < 80) /if (mana) cast heal %; /else rest %; /endif %; /else smile %; /endif %; done")
+
+	for _, test := range []struct {
+		hp, mana string
+		expected []string
+	}{
+		{"30", "1", []string{"quaff", "done"}},
+		{"60", "1", []string{"cast heal", "done"}},
+		{"60", "0", []string{"rest", "done"}},
+		{"90", "1", []string{"smile", "done"}},
+	} {
+		in.SetVar("hp", test.hp)
+		in.SetVar("mana", test.mana)
+		if err := in.Eval("/heal"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expectSent(t, &r, test.expected...)
+	}
+
+	if err := in.Exec(&interp.Frame{}, "/if (1) one"); err != nil {
+		t.Errorf("Expected an unclosed /if to be allowed: %v", err)
+	}
+	expectSent(t, &r, "one")
+	for _, body := range []string{"/else", "/endif", "/done", "/if 1", "/while (0)", "/if (1"} {
+		if err := in.Exec(&interp.Frame{}, body); err == nil {
+			t.Errorf("Expected an error for %q", body)
+		}
+	}
+}
+
+func TestWhile(t *testing.T) {
+	var r recorder
+	in := newRecordingInterp(&r)
+
+	err := in.Exec(&interp.Frame{}, "/let i=0 %; /while (i < 3) /let i=$[i + 1] %; /if (i != 2) n%i %; /endif %; /done %; s")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expectSent(t, &r, "n1", "n3", "s")
+
+	if err := in.Exec(&interp.Frame{}, "/while (1) %; /done"); err == nil {
+		t.Error("Expected an endless loop to be stopped")
+	}
+}
+
+func TestRecursionLimit(t *testing.T) {
+	in := newRecordingInterp(&recorder{})
+	in.Eval("/def loop = /loop")
+	if err := in.Eval("/loop"); err == nil || !strings.Contains(err.Error(), "nested too deeply") {
+		t.Errorf("Expected recursion to be stopped but found %v", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	var r recorder
+	in := newRecordingInterp(&r)
+	in.Eval("/def kk = kill %target")
+	in.Eval("/def -t'^You are hungry' food = eat bread")
+
+	in.Eval("/list k*")
+	if len(r.output) != 1 || r.output[0] != "/def kk = kill %target" {
+		t.Errorf("Unexpected listing %q", r.output)
+	}
+	r.output = nil
+	in.Eval("/list")
+	if len(r.output) != 2 || r.output[0] != `/def -t"^You are hungry" food = eat bread` {
+		t.Errorf("Unexpected listing %q", r.output)
+	}
+}
+
+func TestAliases(t *testing.T) {
+	var r recorder
+	in := newRecordingInterp(&r)
+	f := &interp.Frame{}
+
+	in.Eval("/def tt = tell %1 %-1")
+	in.Eval("/alias bs backstab %{1-%{target-someone}}")
+	in.Eval("/alias k kill %*%; bs %1")
+	in.Eval("/def -h'CONNECT' greet = say hello")
+
+	for _, line := range []string{"tt talia how are you?", "k trolloc", "bs", "greet", "kt trolloc"} {
+		if err := in.Input(f, line); err != nil {
+			t.Fatalf("Unexpected error for %q: %v", line, err)
+		}
+	}
+	expectSent(t, &r, "tell talia how are you?", "kill trolloc", "backstab trolloc", "backstab someone", "greet", "kt trolloc")
+
+	in.Eval("/alias")
+	if strings.Join(r.output, "|") != "/alias bs backstab %{1-%{target-someone}}|/alias k kill %*%; bs %1|/alias tt tell %1 %-1" {
+		t.Errorf("Unexpected listing %q", r.output)
+	}
+	if err := in.Eval("/unalias greet"); err == nil {
+		t.Error("Expected /unalias to leave hooks alone")
+	}
+	if err := in.Eval("/unalias k"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	in.Input(f, "k trolloc")
+	expectSent(t, &r, "k trolloc")
+
+	// Aliases are not expanded from within themselves.
+	in.Eval("/alias ping pong %1")
+	in.Eval("/alias pong ping %1")
+	in.Eval("/alias k kill %1%; k %1")
+	in.Eval("/alias look look %*%; glance")
+	for _, line := range []string{"ping x", "k rat", "look north"} {
+		if err := in.Input(f, line); err != nil {
+			t.Fatalf("Unexpected error for %q: %v", line, err)
+		}
+	}
+	expectSent(t, &r, "ping x", "kill rat", "k rat", "look north", "glance")
+}
+
+func TestDisplayRules(t *testing.T) {
+	var r recorder
+	in := newRecordingInterp(&r)
+
+	for _, cmd := range []string{
+		`/gag ^The ancient tree tries to hit you, but you parry successfully\.$`,
+		"/hilite -aBCred hits your body hard",
+		"/substitute -msimple You receive your share of experience... = [exp]",
+		`/def -ag -t"^You are thirsty" -wFreddie thirst`,
+	} {
+		if err := in.Eval(cmd); err != nil {
+			t.Fatalf("Unexpected error for %q: %v", cmd, err)
+		}
+	}
+	in.Eval("/list")
+	expected := []string{
+		`/def -a"g" -t"^The ancient tree tries to hit you, but you parry successfully\\.$" #1 = `,
+		`/def -a"BCred" -t"hits your body hard" #2 = `,
+		`/substitute ^You receive your share of experience\.\.\.$ = [exp]`,
+		`/def -a"g" -t"^You are thirsty" -w"Freddie" thirst = `,
+	}
+	if strings.Join(r.output, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected listing %q but found %q", expected, r.output)
+	}
+
+	if gag, codes, err := interp.AttrCodes("gBCred"); err != nil || !gag || codes != "\x1b[1;31m" {
+		t.Errorf("Unexpected attributes %v %q %v", gag, codes, err)
+	}
+	for _, cmd := range []string{"/hilite -aCpuce x", "/def -aZ -tx", "/substitute x", "/gag"} {
+		if err := in.Eval(cmd); err == nil {
+			t.Errorf("Expected an error for %q", cmd)
+		}
+	}
+
+	if err := in.Eval("/nohilite hits your body hard"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := in.Eval("/nogag hits your body hard"); err == nil {
+		t.Error("Expected /nogag to leave highlights alone")
+	}
+	if len(in.Macros()) != 3 {
+		t.Errorf("Expected 3 macros left but found %d", len(in.Macros()))
+	}
+}
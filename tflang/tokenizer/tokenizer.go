@@ -3,6 +3,7 @@ package tokenizer
 import (
 	"io"
 	"strings"
+	"unicode"
 )
 
 // Token - Keeps track of all information relating to a lexical token
@@ -23,7 +24,7 @@ type Tokenizer struct {
 }
 
 func isValidSlashCommandRune(ch rune) bool {
-	return true
+	return !unicode.IsSpace(ch)
 }
 
 func (t *Tokenizer) lex(tokenChan chan Token) {
@@ -47,3 +47,15 @@ func TestTokenizeSimpleCommand(t *testing.T) {
 
 	assertEqualTokenArrays(t, expectedTokens, tokens, testStr)
 }
+
+func TestTokenizeCommandWithArgs(t *testing.T) {
+	testStr := "/addworld Freddie game.wotmud.org 2224"
+	expectedTokens := []tokenizer.Token{
+		tokenizer.NewToken(tokenizer.SlashCmd, "/addworld"),
+	}
+
+	tokenizer := tokenizer.Tokenize(testStr)
+	tokens := asSlice(tokenizer.Tokens())
+
+	assertEqualTokenArrays(t, expectedTokens, tokens, testStr)
+}
@@ -0,0 +1,29 @@
+package wotmud
+
+import "strings"
+
+// StripANSI - Remove the ANSI escape sequences, such as colours, from s
+func StripANSI(s string) string {
+	plain, _ := StripANSIOffsets(s)
+	return plain
+}
+
+// StripANSIOffsets - Remove the ANSI escape sequences from s, also returning
+// the offset in s of every byte of the result
+func StripANSIOffsets(s string) (string, []int) {
+	var plain strings.Builder
+	offsets := make([]int, 0, len(s))
+	for idx := 0; idx < len(s); idx++ {
+		if s[idx] == 0x1b && idx+1 < len(s) && s[idx+1] == '[' {
+			end := idx + 2
+			for end < len(s) && (s[end] < 0x40 || s[end] > 0x7e) {
+				end++
+			}
+			idx = end
+			continue
+		}
+		plain.WriteByte(s[idx])
+		offsets = append(offsets, idx)
+	}
+	return plain.String(), offsets
+}
@@ -0,0 +1,204 @@
+// Package clock models the game's clock from the messages shown as the sun
+// rises and sets, and whether the player's room is lit from the prompt.
+package clock
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/huntwj/gofugue/wotmud"
+)
+
+// DefaultHour is how long a game hour is taken to last until the clock has
+// seen enough of the sun to measure it. It is CircleMUD's default.
+const DefaultHour = 75 * time.Second
+
+// A Phase of the day.
+type Phase int
+
+// Phases of the day.
+const (
+	Unknown Phase = iota
+	Night
+	Sunrise
+	Day
+	Sunset
+)
+
+func (p Phase) String() string {
+	switch p {
+	case Night:
+		return "night"
+	case Sunrise:
+		return "sunrise"
+	case Day:
+		return "day"
+	case Sunset:
+		return "sunset"
+	}
+	return ""
+}
+
+// phaseAt is the phase of the day at hour.
+func phaseAt(hour int) Phase {
+	switch {
+	case hour == 5:
+		return Sunrise
+	case hour >= 6 && hour < 21:
+		return Day
+	case hour == 21:
+		return Sunset
+	}
+	return Night
+}
+
+// marks are the messages that start a phase of the day, with the hour at
+// which the game shows them.
+var marks = []struct {
+	pattern *regexp.Regexp
+	hour    int
+}{
+	{regexp.MustCompile(`^The sun (?:rises|peeks|crests)\b.*\.$`), 5},
+	{regexp.MustCompile(`^The day has begun\.$`), 6},
+	{regexp.MustCompile(`^The sun (?:sets|sinks|slowly sinks|slowly disappears)\b.*\.$`), 21},
+	{regexp.MustCompile(`^The night has begun\.$`), 22},
+}
+
+var pitchBlack = regexp.MustCompile(`^It is pitch black\.\.\.$`)
+
+// A Kind of Event.
+type Kind int
+
+// Kinds of events.
+const (
+	// PhaseChanged - The game showed the start of a phase of the day
+	PhaseChanged Kind = iota
+	// Darkened - The player's room became dark
+	Darkened
+	// Lit - The player's room became lit
+	Lit
+)
+
+// An Event is a change in the time of day or the light in the player's room.
+type Event struct {
+	Kind  Kind
+	Phase Phase
+}
+
+// A Clock follows the game's time of day and the light in the player's room.
+// It is safe for concurrent use.
+type Clock struct {
+	mu     sync.Mutex
+	hour   int       // the hour of the last mark
+	marked time.Time // when the last mark was seen, zero before the first
+	length time.Duration
+	dark   bool
+	known  bool // a prompt has shown whether the room is lit
+}
+
+// New - Create a Clock that does not know the time yet
+func New() *Clock {
+	return &Clock{length: DefaultHour}
+}
+
+// Observe - Feed a line of output, received at now, into the Clock,
+// returning the events it caused
+func (c *Clock) Observe(line wotmud.Line, now time.Time) []Event {
+	text := strings.TrimSpace(wotmud.StripANSI(line.Text()))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var events []Event
+	if info := line.Prompt(); info != nil {
+		if ev, ok := c.light(!info.IsLit); ok {
+			events = append(events, ev)
+		}
+		c.known = true
+	}
+	if pitchBlack.MatchString(text) {
+		if ev, ok := c.light(true); ok {
+			events = append(events, ev)
+		}
+	}
+	for _, mark := range marks {
+		if mark.pattern.MatchString(text) {
+			c.mark(mark.hour, now)
+			events = append(events, Event{Kind: PhaseChanged, Phase: phaseAt(mark.hour)})
+		}
+	}
+	return events
+}
+
+// light notes whether the room is dark, returning an event for a change.
+func (c *Clock) light(dark bool) (Event, bool) {
+	changed := c.known && dark != c.dark
+	c.dark = dark
+	if !changed {
+		return Event{}, false
+	}
+	if dark {
+		return Event{Kind: Darkened}, true
+	}
+	return Event{Kind: Lit}, true
+}
+
+// mark sets the clock to hour at now. When the previous mark was seen, the
+// time since then measures how long a game hour lasts. The game hours that
+// passed are taken to be those between the two marks plus however many
+// whole days best fit the current measure.
+func (c *Clock) mark(hour int, now time.Time) {
+	if !c.marked.IsZero() && now.After(c.marked) {
+		elapsed := now.Sub(c.marked)
+		hours := (hour - c.hour + 24) % 24
+		days := math.Round((float64(elapsed)/float64(c.length) - float64(hours)) / 24)
+		if days < 0 {
+			days = 0
+		}
+		if hours += 24 * int(days); hours > 0 {
+			c.length = elapsed / time.Duration(hours)
+		}
+	}
+	c.hour, c.marked = hour, now
+}
+
+// Hour - The game hour at now, from 0 to 23, reporting false until the clock
+// has seen the sun rise or set
+func (c *Clock) Hour(now time.Time) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.marked.IsZero() {
+		return 0, false
+	}
+	return (c.hour + int(now.Sub(c.marked)/c.length)) % 24, true
+}
+
+// Phase - The phase of the day at now, or Unknown until the clock has seen
+// the sun rise or set
+func (c *Clock) Phase(now time.Time) Phase {
+	hour, ok := c.Hour(now)
+	if !ok {
+		return Unknown
+	}
+	return phaseAt(hour)
+}
+
+// HourLength - How long a game hour lasts, as measured so far
+func (c *Clock) HourLength() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.length
+}
+
+// Dark - Whether the player's room is dark
+func (c *Clock) Dark() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.dark
+}
@@ -0,0 +1,103 @@
+package clock_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/clock"
+)
+
+func TestClock(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := clock.New()
+	if _, ok := c.Hour(start); ok || c.Phase(start) != clock.Unknown {
+		t.Error("Expected the clock not to know the time")
+	}
+
+	events := c.Observe(wotmud.NewLine("The day has begun."), start)
+	if !reflect.DeepEqual(events, []clock.Event{{Kind: clock.PhaseChanged, Phase: clock.Day}}) {
+		t.Errorf("Expected the day to begin but found %+v", events)
+	}
+	if hour, ok := c.Hour(start.Add(3 * clock.DefaultHour)); !ok || hour != 9 {
+		t.Errorf("Expected 9 o'clock but found %d", hour)
+	}
+
+	// Sixteen game hours later the night begins, a minute an hour.
+	events = c.Observe(wotmud.NewLine("* HP:Healthy MV:Fresh > The night has begun."), start.Add(16*time.Minute))
+	if len(events) != 1 || events[0].Phase != clock.Night {
+		t.Errorf("Expected the night to begin but found %+v", events)
+	}
+	if c.HourLength() != time.Minute {
+		t.Errorf("Expected an hour to last a minute but found %v", c.HourLength())
+	}
+	now := start.Add(16*time.Minute + 7*time.Minute + 30*time.Second)
+	if hour, _ := c.Hour(now); hour != 5 || c.Phase(now) != clock.Sunrise {
+		t.Errorf("Expected sunrise at 5 o'clock but found %d", hour)
+	}
+
+	// A day and a half later the sun rises: 31 game hours.
+	c.Observe(wotmud.NewLine("The sun rises in the east above the Spine of the World."), start.Add(47*time.Minute+31*time.Second))
+	if hour, _ := c.Hour(start.Add(48 * time.Minute)); hour != 5 {
+		t.Errorf("Expected 5 o'clock but found %d", hour)
+	}
+	if length := c.HourLength(); length != 61*time.Second {
+		t.Errorf("Expected an hour to last 61s but found %v", length)
+	}
+}
+
+func TestSunMessages(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		text  string
+		phase clock.Phase
+	}{
+		{"The sun rises.", clock.Sunrise},
+		{"The sun rises, cutting through the sharp profiles of rocky mountains.", clock.Sunrise},
+		{"The sun peeks over the eastern wall of the city.", clock.Sunrise},
+		{"The sun crests the horizon and light breaks through the trees.", clock.Sunrise},
+		{"The sun sets.", clock.Sunset},
+		{"The sun sets over the broken gorge.", clock.Sunset},
+		{"The sun slowly disappears in the west.", clock.Sunset},
+		{"The sun slowly sinks in the west, casting long shadows across the city.", clock.Sunset},
+		{"The sun sinks slowly beneath the rolling hills to the west.", clock.Sunset},
+	} {
+		events := clock.New().Observe(wotmud.NewLine(test.text), time.Now())
+		if len(events) != 1 || events[0].Phase != test.phase {
+			t.Errorf("Expected %q to change the phase to %v but found %+v", test.text, test.phase, events)
+		}
+	}
+
+	if events := clock.New().Observe(wotmud.NewLine("The sun hits the hay."), time.Now()); len(events) != 0 {
+		t.Errorf("Expected no events but found %+v", events)
+	}
+}
+
+func TestLight(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := clock.New()
+	observe := func(raw string) []clock.Event {
+		return c.Observe(wotmud.NewLine(raw), now)
+	}
+
+	if events := observe("* HP:Healthy MV:Fresh > "); len(events) != 0 || c.Dark() {
+		t.Errorf("Expected a lit room without events but found %+v", events)
+	}
+	events := observe("It is pitch black...")
+	if !reflect.DeepEqual(events, []clock.Event{{Kind: clock.Darkened}}) || !c.Dark() {
+		t.Errorf("Expected the room to go dark but found %+v", events)
+	}
+	if events := observe("o R HP:Healthy MV:Fresh > It is pitch black..."); len(events) != 0 {
+		t.Errorf("Expected the room to stay dark but found %+v", events)
+	}
+	events = observe("* R HP:Healthy MV:Fresh > ")
+	if !reflect.DeepEqual(events, []clock.Event{{Kind: clock.Lit}}) || c.Dark() {
+		t.Errorf("Expected the room to be lit but found %+v", events)
+	}
+}
@@ -0,0 +1,305 @@
+// Package combat recognizes the combat messages of WoTMUD, such as hits,
+// misses, parries and dodges, and keeps track of the fight the player is in.
+package combat
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/prompt"
+)
+
+// You is the name used in an Event for the player.
+const You = "you"
+
+// An Outcome is what became of an attack.
+type Outcome int
+
+const (
+	// Hit - The attack landed
+	Hit Outcome = iota
+	// Miss - The attack missed
+	Miss
+	// Parry - The defender parried the attack
+	Parry
+	// Deflect - The defender deflected the blow
+	Deflect
+	// Dodge - The defender dodged the attack
+	Dodge
+	// Join - The attacker joined the fight of the defender
+	Join
+)
+
+var outcomeNames = []string{"hit", "miss", "parry", "deflect", "dodge", "join"}
+
+func (o Outcome) String() string {
+	if int(o) < len(outcomeNames) {
+		return outcomeNames[o]
+	}
+	return "unknown"
+}
+
+// Severity words of hits, from the weakest to the strongest. A hit without
+// a severity word, such as "X hits your body.", has the severity Normal.
+const (
+	Tickle        = "tickle"
+	Barely        = "barely"
+	Normal        = ""
+	Hard          = "hard"
+	VeryHard      = "very hard"
+	ExtremelyHard = "extremely hard"
+	Fragments     = "into bloody fragments"
+)
+
+// An Event is a single combat message. Attacker and Defender are named as in
+// the message, or You for the player. For a Join, Attacker joined the fight
+// of Defender. Attack is the attack verb, such as hit, slash or pierce, and
+// Severity is set for hits, as is BodyPart when the message names one.
+type Event struct {
+	Attacker string
+	Defender string
+	Outcome  Outcome
+	Attack   string
+	BodyPart string
+	Severity string
+}
+
+// ByYou - Whether the player made the attack
+func (e *Event) ByYou() bool {
+	return e.Attacker == You
+}
+
+// AtYou - Whether the player was attacked
+func (e *Event) AtYou() bool {
+	return e.Defender == You
+}
+
+// attackVerbs are the attack types of the game, which are also the verbs of
+// its combat messages.
+var attackVerbs = []string{
+	"hit", "pierce", "slash", "blast", "strike", "pound", "crush", "stab",
+	"whip", "bite", "claw", "sting", "maul", "smash", "cleave", "lash",
+	"punch", "gore", "trample", "scratch", "kick", "slice", "bludgeon",
+}
+
+// thirdPerson maps the form of an attack verb used for others, such as
+// slashes, to the verb.
+var thirdPerson = make(map[string]string)
+
+// verb and verbs match an attack verb and the form used for others.
+var verb, verbs string
+
+func init() {
+	var others []string
+	for _, v := range attackVerbs {
+		other := v + "s"
+		if strings.HasSuffix(v, "sh") || strings.HasSuffix(v, "ch") || strings.HasSuffix(v, "s") {
+			other = v + "es"
+		}
+		thirdPerson[other] = v
+		others = append(others, other)
+	}
+	verb = "(" + strings.Join(attackVerbs, "|") + ")"
+	verbs = "(" + strings.Join(others, "|") + ")"
+	patterns = compilePatterns()
+}
+
+var defenses = map[string]Outcome{
+	"parry successfully":   Parry,
+	"parries successfully": Parry,
+	"deflect the blow":     Deflect,
+	"deflects the blow":    Deflect,
+	"dodge the attack":     Dodge,
+	"dodges the attack":    Dodge,
+}
+
+const (
+	defense  = `(parry successfully|deflect the blow|dodge the attack)`
+	defends  = `(parries successfully|deflects the blow|dodges the attack)`
+	pronoun  = `(?:he|she|it)`
+	severity = `(?: (extremely hard|very hard|hard|into bloody fragments))?`
+	end      = `[.!]$` // the strongest hits end with an exclamation mark
+)
+
+// A pattern recognizes one form of combat message. Its fields give the
+// submatch holding each part of the event, or 0 when the message does not
+// name it; the event is filled in with what else is known by fill.
+type pattern struct {
+	re                                  *regexp.Regexp
+	attacker, defender, verb, part, sev int
+	outcome                             int // submatch naming the defense
+	fill                                Event
+}
+
+var patterns []pattern
+
+func compilePatterns() []pattern {
+	return []pattern{
+		{re: regexp.MustCompile(`^(.+) tries to ` + verb + ` you, but you ` + defense + `\.$`),
+			attacker: 1, verb: 2, outcome: 3, fill: Event{Defender: You}},
+		{re: regexp.MustCompile(`^You try to ` + verb + ` (.+), but ` + pronoun + ` ` + defends + `\.$`),
+			verb: 1, defender: 2, outcome: 3, fill: Event{Attacker: You}},
+		{re: regexp.MustCompile(`^(.+) tries to ` + verb + ` (.+), but ` + pronoun + ` ` + defends + `\.$`),
+			attacker: 1, verb: 2, defender: 3, outcome: 4},
+		{re: regexp.MustCompile(`^You swiftly dodge (.+)'s attempt to ` + verb + ` you\.$`),
+			attacker: 1, verb: 2, fill: Event{Defender: You, Outcome: Dodge}},
+		{re: regexp.MustCompile(`^(.+) swiftly dodges your attempt to ` + verb + ` (?:him|her|it)\.$`),
+			defender: 1, verb: 2, fill: Event{Attacker: You, Outcome: Dodge}},
+		{re: regexp.MustCompile(`^(.+) swiftly dodges (.+)'s attempt to ` + verb + ` (?:him|her|it)\.$`),
+			defender: 1, attacker: 2, verb: 3, fill: Event{Outcome: Dodge}},
+		{re: regexp.MustCompile(`^You swing wildly\.\. but miss completely\.\.\.$`),
+			fill: Event{Attacker: You, Outcome: Miss}},
+		{re: regexp.MustCompile(`^(.+) tries to ` + verb + ` you\.$`),
+			attacker: 1, verb: 2, fill: Event{Defender: You, Outcome: Miss}},
+		{re: regexp.MustCompile(`^You try to ` + verb + ` (.+)\.$`),
+			verb: 1, defender: 2, fill: Event{Attacker: You, Outcome: Miss}},
+		{re: regexp.MustCompile(`^(.+) tries to ` + verb + ` (.+)\.$`),
+			attacker: 1, verb: 2, defender: 3, fill: Event{Outcome: Miss}},
+		{re: regexp.MustCompile(`^(.+) joins your fight!$`),
+			attacker: 1, fill: Event{Defender: You, Outcome: Join}},
+		{re: regexp.MustCompile(`^(.+) joins (.+)'s fight!$`),
+			attacker: 1, defender: 2, fill: Event{Outcome: Join}},
+		{re: regexp.MustCompile(`^You fail to ` + verb + ` (.+)\.$`),
+			verb: 1, defender: 2, fill: Event{Attacker: You, Outcome: Miss}},
+		{re: regexp.MustCompile(`^(.+?) ` + verbs + ` at (.+), but .+ passes through (?:him|her|it)\.$`),
+			attacker: 1, verb: 2, defender: 3, fill: Event{Outcome: Miss}},
+		{re: regexp.MustCompile(`^You tickle (.+)'s (.+) with your ` + verb + `\.$`),
+			defender: 1, part: 2, verb: 3, fill: Event{Attacker: You, Severity: Tickle}},
+		{re: regexp.MustCompile(`^You barely ` + verb + ` (.+)'s (.+)\.$`),
+			verb: 1, defender: 2, part: 3, fill: Event{Attacker: You, Severity: Barely}},
+		{re: regexp.MustCompile(`^You ` + verb + ` (.+)'s (.+?)` + severity + end),
+			verb: 1, defender: 2, part: 3, sev: 4, fill: Event{Attacker: You}},
+		{re: regexp.MustCompile(`^You ` + verb + ` ([^']+?)` + severity + end),
+			verb: 1, defender: 2, sev: 3, fill: Event{Attacker: You}},
+		{re: regexp.MustCompile(`^(.+) tickles your (.+) with (?:his|her|its) ` + verb + `\.$`),
+			attacker: 1, part: 2, verb: 3, fill: Event{Defender: You, Severity: Tickle}},
+		{re: regexp.MustCompile(`^(.+) barely ` + verbs + ` your (.+)\.$`),
+			attacker: 1, verb: 2, part: 3, fill: Event{Defender: You, Severity: Barely}},
+		{re: regexp.MustCompile(`^(.+) ` + verbs + ` your (.+?)` + severity + end),
+			attacker: 1, verb: 2, part: 3, sev: 4, fill: Event{Defender: You}},
+		{re: regexp.MustCompile(`^(.+) ` + verbs + ` you` + severity + end),
+			attacker: 1, verb: 2, sev: 3, fill: Event{Defender: You}},
+		{re: regexp.MustCompile(`^(.+) tickles (.+)'s (.+) with (?:his|her|its) ` + verb + `\.$`),
+			attacker: 1, defender: 2, part: 3, verb: 4, fill: Event{Severity: Tickle}},
+		{re: regexp.MustCompile(`^(.+) barely ` + verbs + ` (.+)'s (.+)\.$`),
+			attacker: 1, verb: 2, defender: 3, part: 4, fill: Event{Severity: Barely}},
+		{re: regexp.MustCompile(`^(.+?) ` + verbs + ` (.+)'s (.+?)` + severity + end),
+			attacker: 1, verb: 2, defender: 3, part: 4, sev: 5},
+	}
+}
+
+// attackVerb finds the attack named by word, which may be in the form used
+// for others, such as slashes.
+func attackVerb(word string) string {
+	if verb, ok := thirdPerson[word]; ok {
+		return verb
+	}
+	return word
+}
+
+// Parse - Recognize a combat message in text, the part of a line of output
+// following any prompt with its ANSI codes removed. It returns nil for text
+// that is not a combat message.
+func Parse(text string) *Event {
+	text = strings.TrimSpace(text)
+	for _, p := range patterns {
+		match := p.re.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+
+		ev := p.fill
+		if p.verb != 0 {
+			ev.Attack = attackVerb(match[p.verb])
+		}
+		if p.attacker != 0 {
+			ev.Attacker = match[p.attacker]
+		}
+		if p.defender != 0 {
+			ev.Defender = match[p.defender]
+		}
+		if p.part != 0 {
+			ev.BodyPart = match[p.part]
+		}
+		if p.sev != 0 {
+			ev.Severity = match[p.sev]
+		}
+		if p.outcome != 0 {
+			ev.Outcome = defenses[match[p.outcome]]
+		}
+		return &ev
+	}
+	return nil
+}
+
+// State - What is known about the fight the player is in
+type State struct {
+	// Combat is the fight as shown by the last prompt, nil when the prompt
+	// showed none.
+	Combat *prompt.Combat
+	// Attackers lists, in order of their first attack, those who have
+	// attacked the player during the fight.
+	Attackers []string
+	// Last is the last combat message of the fight.
+	Last *Event
+}
+
+// A Tracker watches the lines of a session for combat messages, keeping the
+// State of the fight up to date. The fight is over once a prompt shows no
+// combat.
+type Tracker struct {
+	mu    sync.Mutex
+	state State
+}
+
+// New creates a Tracker for a player who is not fighting.
+func New() *Tracker {
+	return &Tracker{}
+}
+
+// Observe feeds a line of output into the Tracker, returning the combat
+// message it holds, if any.
+func (t *Tracker) Observe(line wotmud.Line) *Event {
+	ev := Parse(wotmud.StripANSI(line.Text()))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if info := line.Prompt(); info != nil {
+		t.state.Combat = info.Combat
+		if info.Combat == nil && ev == nil {
+			t.state.Attackers = nil
+			t.state.Last = nil
+		}
+	}
+	if ev == nil {
+		return nil
+	}
+
+	t.state.Last = ev
+	if ev.AtYou() && ev.Outcome != Join && !contains(t.state.Attackers, ev.Attacker) {
+		t.state.Attackers = append(t.state.Attackers, ev.Attacker)
+	}
+	return ev
+}
+
+func contains(names []string, name string) bool {
+	for _, existing := range names {
+		if strings.EqualFold(existing, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// State - A copy of what is known about the current fight
+func (t *Tracker) State() State {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state := t.state
+	state.Attackers = append([]string(nil), t.state.Attackers...)
+	return state
+}
@@ -0,0 +1,263 @@
+package combat_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/combat"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		text     string
+		expected combat.Event
+	}{
+		{"The ancient tree tries to hit you, but you parry successfully.",
+			combat.Event{Attacker: "The ancient tree", Defender: combat.You, Outcome: combat.Parry, Attack: "hit"}},
+		{"The writhing grass tries to hit you, but you deflect the blow.",
+			combat.Event{Attacker: "The writhing grass", Defender: combat.You, Outcome: combat.Deflect, Attack: "hit"}},
+		{"A vernarsh tries to bite you, but you dodge the attack.",
+			combat.Event{Attacker: "A vernarsh", Defender: combat.You, Outcome: combat.Dodge, Attack: "bite"}},
+		{"You swiftly dodge the brigand trooper's attempt to pierce you.",
+			combat.Event{Attacker: "the brigand trooper", Defender: combat.You, Outcome: combat.Dodge, Attack: "pierce"}},
+		{"You try to slash the brigand sergeant, but he deflects the blow.",
+			combat.Event{Attacker: combat.You, Defender: "the brigand sergeant", Outcome: combat.Deflect, Attack: "slash"}},
+		{"Dal tries to hit the ancient tree, but it parries successfully.",
+			combat.Event{Attacker: "Dal", Defender: "the ancient tree", Outcome: combat.Parry, Attack: "hit"}},
+		{"You try to slash the ancient tree.",
+			combat.Event{Attacker: combat.You, Defender: "the ancient tree", Outcome: combat.Miss, Attack: "slash"}},
+		{"The ancient tree tries to hit Dal.",
+			combat.Event{Attacker: "The ancient tree", Defender: "Dal", Outcome: combat.Miss, Attack: "hit"}},
+		{"You swing wildly.. but miss completely...",
+			combat.Event{Attacker: combat.You, Outcome: combat.Miss}},
+		{"You slash the ancient tree's trunk extremely hard.",
+			combat.Event{Attacker: combat.You, Defender: "the ancient tree", Attack: "slash", BodyPart: "trunk", Severity: combat.ExtremelyHard}},
+		{"You slash a big brown bear's left foreleg very hard.",
+			combat.Event{Attacker: combat.You, Defender: "a big brown bear", Attack: "slash", BodyPart: "left foreleg", Severity: combat.VeryHard}},
+		{"You slash a lithe woman's body.",
+			combat.Event{Attacker: combat.You, Defender: "a lithe woman", Attack: "slash", BodyPart: "body"}},
+		{"You barely pierce a handsome stag's head.",
+			combat.Event{Attacker: combat.You, Defender: "a handsome stag", Attack: "pierce", BodyPart: "head", Severity: combat.Barely}},
+		{"You tickle a tusked pig's body with your pierce.",
+			combat.Event{Attacker: combat.You, Defender: "a tusked pig", Attack: "pierce", BodyPart: "body", Severity: combat.Tickle}},
+		{"The brigand trooper pierces your left leg hard.",
+			combat.Event{Attacker: "The brigand trooper", Defender: combat.You, Attack: "pierce", BodyPart: "left leg", Severity: combat.Hard}},
+		{"The ancient tree barely hits your body.",
+			combat.Event{Attacker: "The ancient tree", Defender: combat.You, Attack: "hit", BodyPart: "body", Severity: combat.Barely}},
+		{"A sly, black snake tickles your body with its bite.",
+			combat.Event{Attacker: "A sly, black snake", Defender: combat.You, Attack: "bite", BodyPart: "body", Severity: combat.Tickle}},
+		{"Dal strikes the ancient tree's roots extremely hard.",
+			combat.Event{Attacker: "Dal", Defender: "the ancient tree", Attack: "strike", BodyPart: "roots", Severity: combat.ExtremelyHard}},
+		{"The angry tree slashes Dal's body.",
+			combat.Event{Attacker: "The angry tree", Defender: "Dal", Attack: "slash", BodyPart: "body"}},
+		{"You slash the ancient tree's trunk into bloody fragments!",
+			combat.Event{Attacker: combat.You, Defender: "the ancient tree", Attack: "slash", BodyPart: "trunk", Severity: combat.Fragments}},
+		{"Cailte blasts the chief of the wretches's body into bloody fragments!",
+			combat.Event{Attacker: "Cailte", Defender: "the chief of the wretches", Attack: "blast", BodyPart: "body", Severity: combat.Fragments}},
+		{"You slash a wispy fog.",
+			combat.Event{Attacker: combat.You, Defender: "a wispy fog", Attack: "slash"}},
+		{"A wispy fog hits you very hard.",
+			combat.Event{Attacker: "A wispy fog", Defender: combat.You, Attack: "hit", Severity: combat.VeryHard}},
+		{"You fail to slash a wispy fog.",
+			combat.Event{Attacker: combat.You, Defender: "a wispy fog", Outcome: combat.Miss, Attack: "slash"}},
+		{"Dal strikes at a wispy fog, but a large steel spear passes through it.",
+			combat.Event{Attacker: "Dal", Defender: "a wispy fog", Outcome: combat.Miss, Attack: "strike"}},
+		{"The writhing grass joins the ancient tree's fight!",
+			combat.Event{Attacker: "The writhing grass", Defender: "the ancient tree", Outcome: combat.Join}},
+		{"Dal joins your fight!",
+			combat.Event{Attacker: "Dal", Defender: combat.You, Outcome: combat.Join}},
+	}
+	for _, test := range tests {
+		ev := combat.Parse(test.text)
+		if ev == nil {
+			t.Errorf("Expected %q to be a combat message", test.text)
+			continue
+		}
+		if *ev != test.expected {
+			t.Errorf("For %q expected %+v but found %+v", test.text, test.expected, *ev)
+		}
+	}
+
+	for _, text := range []string{
+		"You try to quietly draw a shiny copper dagger from a dark, hooded cloak.",
+		"An armorer stands here, working hard.",
+		"Dal thinks hard.",
+		"patch of half dead grass, or a stunted tree tries to grow. The road itself",
+		"You tell Dal 'I'm dodge, so I'm otherwise limited to trees.'",
+	} {
+		if ev := combat.Parse(text); ev != nil {
+			t.Errorf("Expected %q not to be a combat message but found %+v", text, *ev)
+		}
+	}
+}
+
+func TestTracker(t *testing.T) {
+	t.Parallel()
+
+	tracker := combat.New()
+	observe := func(raw string) *combat.Event {
+		return tracker.Observe(wotmud.NewLine(raw))
+	}
+
+	observe("* R HP:Healthy MV:Fresh > ")
+	if state := tracker.State(); state.Combat != nil || state.Last != nil {
+		t.Errorf("Expected no fight but found %+v", state)
+	}
+
+	ev := observe("* R HP:Healthy MV:Fresh - the ancient tree: Hurt > \x1b[32mYou slash the ancient tree's trunk hard.\x1b[0m")
+	if ev == nil || ev.Defender != "the ancient tree" || !ev.ByYou() {
+		t.Fatalf("Expected a hit on the ancient tree but found %+v", ev)
+	}
+	observe("The ancient tree tries to hit you, but you parry successfully.")
+	observe("The writhing grass joins the ancient tree's fight!")
+	observe("The writhing grass hits your body very hard.")
+	observe("The ancient tree barely hits your right leg.")
+
+	state := tracker.State()
+	if state.Combat == nil || state.Combat.Target.Name != "the ancient tree" || state.Combat.Target.Health != "Hurt" {
+		t.Errorf("Expected the prompt's combat but found %+v", state.Combat)
+	}
+	if got := strings.Join(state.Attackers, ","); got != "The ancient tree,The writhing grass" {
+		t.Errorf("Expected both attackers but found %q", got)
+	}
+	if state.Last == nil || state.Last.Severity != combat.Barely {
+		t.Errorf("Expected the last message to be kept but found %+v", state.Last)
+	}
+
+	observe("* R HP:Hurt MV:Fresh > ")
+	if state := tracker.State(); state.Combat != nil || state.Attackers != nil || state.Last != nil {
+		t.Errorf("Expected the fight to be over but found %+v", state)
+	}
+}
+
+// logCodes matches the color codes in the test logs, which were written with
+// the escape character shown as ^[.
+var logCodes = regexp.MustCompile(`\^\[\[?[0-9;]*m`)
+
+// attackLike matches lines that look like one player or creature attacking
+// another, such as "You slash the ancient tree's trunk hard." or "The rat
+// bites your body.".
+var attackLike = regexp.MustCompile(`^(?:You|[A-Z][\w ,'-]*) (?:\w+ )?(?:hit|pierce|slash|blast|strike|pound|crush|stab|whip|bite|claw|sting|maul|smash|cleave|lash|punch|gore|trample|scratch|kick|slice|bludgeon)(?:e?s)? (?:your |you\b|[\w ,-]+'s )`)
+
+func TestOnLogFiles(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("Skipping log file tests when short.")
+	}
+	logDir := "../testdata"
+	dir, err := ioutil.ReadDir(logDir)
+	if err != nil {
+		t.Fatalf("Error opening directory: %v", err)
+	}
+
+	counts := make(map[combat.Outcome]int)
+	unknown := make(map[string]int)
+	for _, fileInfo := range dir {
+		if !strings.HasSuffix(fileInfo.Name(), ".gz") {
+			continue
+		}
+		f, err := os.Open(filepath.Join(logDir, fileInfo.Name()))
+		if err != nil {
+			t.Fatalf("Could not open log: %v", err)
+		}
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("Could not open gzip stream: %v", err)
+		}
+
+		tracker := combat.New()
+		scanner := bufio.NewScanner(gr)
+		for scanner.Scan() {
+			raw := strings.TrimRight(logCodes.ReplaceAllString(scanner.Text(), ""), "\r")
+			line := wotmud.NewLine(raw)
+			ev := tracker.Observe(line)
+			if ev == nil {
+				if text := strings.TrimSpace(line.Text()); attackLike.MatchString(text) {
+					unknown[text]++
+				}
+				continue
+			}
+			counts[ev.Outcome]++
+			if ev.Attacker == "" && ev.Outcome != combat.Miss {
+				t.Errorf("Expected an attacker in %q", raw)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			t.Errorf("Error reading %s: %v", fileInfo.Name(), err)
+		}
+		gr.Close()
+		f.Close()
+	}
+
+	for _, outcome := range []combat.Outcome{combat.Hit, combat.Miss, combat.Parry, combat.Deflect, combat.Dodge, combat.Join} {
+		if counts[outcome] == 0 {
+			t.Errorf("Expected the logs to hold some %v messages", outcome)
+		}
+	}
+	for text, count := range unknown {
+		if count >= 5 {
+			t.Errorf("Expected %q, seen %d times, to be a combat message", text, count)
+		}
+	}
+}
+
+func TestAnalyzer(t *testing.T) {
+	t.Parallel()
+
+	var analyzer combat.Analyzer
+	for _, raw := range []string{
+		"* R HP:Healthy MV:Fresh > ",
+		"You slash the ancient tree's trunk hard.",
+		"* R HP:Healthy MV:Fresh - the ancient tree: Scratched > ",
+		"The ancient tree tries to hit you, but you parry successfully.",
+		"The ancient tree hits your body very hard.",
+		"You try to slash the ancient tree, but it dodges the attack.",
+		"* R HP:Scratched MV:Fresh - the ancient tree: Hurt > ",
+		"The ancient tree tries to hit you, but you deflect the blow.",
+		"You pierce the ancient tree's roots extremely hard.",
+		"You slash the ancient tree's roots extremely hard.",
+		"* R HP:Scratched MV:Fresh - the ancient tree: Hurt > ",
+		"* R HP:Scratched MV:Fresh > ",
+		"A mirrored lantern has gone out!",
+		"* R HP:Scratched MV:Fresh - a wild dog: Healthy > ",
+	} {
+		analyzer.Observe(wotmud.NewLine(raw))
+	}
+
+	fights := analyzer.Fights()
+	if len(fights) != 2 {
+		t.Fatalf("Expected two fights but found %d", len(fights))
+	}
+	f := fights[0]
+	if !f.Over || f.Rounds != 3 || strings.Join(f.Opponents, ",") != "the ancient tree" {
+		t.Errorf("Unexpected fight %+v", f)
+	}
+	if f.Dealt.Attacks() != 4 || f.Dealt.Landed() != 3 || f.Dealt.Hits[combat.ExtremelyHard] != 2 || f.Dealt.Dodges != 1 {
+		t.Errorf("Unexpected attacks dealt %+v", f.Dealt)
+	}
+	if f.Taken.Attacks() != 3 || f.Taken.Ratio(combat.Parry) != 1.0/3 || f.Taken.Hits[combat.VeryHard] != 1 {
+		t.Errorf("Unexpected attacks taken %+v", f.Taken)
+	}
+	if f.Weapon() != "slash" || f.Weapons["pierce"] != 1 {
+		t.Errorf("Unexpected weapons %v", f.Weapons)
+	}
+	if f.StartHealth != "Healthy" || f.LowHealth != "Scratched" || f.HealthLost() != 1 {
+		t.Errorf("Unexpected health %s to %s", f.StartHealth, f.LowHealth)
+	}
+	if len(f.TargetHealth) != 2 || f.TargetHealth[1].Health != "Hurt" {
+		t.Errorf("Unexpected target health %v", f.TargetHealth)
+	}
+	if fights[1].Over || fights[1].Opponents[0] != "a wild dog" {
+		t.Errorf("Expected an unfinished fight with a wild dog but found %+v", fights[1])
+	}
+}
@@ -0,0 +1,207 @@
+package combat
+
+import (
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/prompt"
+)
+
+// A Tally counts the outcomes of a series of attacks. Hits are counted by
+// their severity word.
+type Tally struct {
+	Hits     map[string]int
+	Misses   int
+	Parries  int
+	Deflects int
+	Dodges   int
+}
+
+func (t *Tally) add(ev *Event) {
+	switch ev.Outcome {
+	case Hit:
+		if t.Hits == nil {
+			t.Hits = make(map[string]int)
+		}
+		t.Hits[ev.Severity]++
+	case Miss:
+		t.Misses++
+	case Parry:
+		t.Parries++
+	case Deflect:
+		t.Deflects++
+	case Dodge:
+		t.Dodges++
+	}
+}
+
+// Add - Count the attacks of other as well
+func (t *Tally) Add(other Tally) {
+	for severity, n := range other.Hits {
+		if t.Hits == nil {
+			t.Hits = make(map[string]int)
+		}
+		t.Hits[severity] += n
+	}
+	t.Misses += other.Misses
+	t.Parries += other.Parries
+	t.Deflects += other.Deflects
+	t.Dodges += other.Dodges
+}
+
+// Landed - The number of hits
+func (t *Tally) Landed() int {
+	n := 0
+	for _, hits := range t.Hits {
+		n += hits
+	}
+	return n
+}
+
+// Attacks - The number of attacks, whatever became of them
+func (t *Tally) Attacks() int {
+	return t.Landed() + t.Misses + t.Parries + t.Deflects + t.Dodges
+}
+
+// Ratio - The share of the attacks that had the given outcome, from 0 to 1
+func (t *Tally) Ratio(outcome Outcome) float64 {
+	attacks := t.Attacks()
+	if attacks == 0 {
+		return 0
+	}
+	n := map[Outcome]int{
+		Hit:     t.Landed(),
+		Miss:    t.Misses,
+		Parry:   t.Parries,
+		Deflect: t.Deflects,
+		Dodge:   t.Dodges,
+	}[outcome]
+	return float64(n) / float64(attacks)
+}
+
+// A Fight summarizes one fight of the player, from the first prompt or
+// combat message showing it to the prompt showing it over. Logs do not
+// record the time, so its length is counted in Rounds, the prompts showing
+// the fight.
+type Fight struct {
+	// Opponents lists those the player attacked or was attacked by, in the
+	// order they joined the fight.
+	Opponents []string
+	Rounds    int
+	// Dealt counts the player's attacks and Taken the attacks on the player.
+	Dealt Tally
+	Taken Tally
+	// Weapons counts the player's attacks by their verb, such as slash.
+	Weapons map[string]int
+	// StartHealth, LowHealth and EndHealth are the player's health when the
+	// fight began, at its worst and when it ended.
+	StartHealth string
+	LowHealth   string
+	EndHealth   string
+	// TargetHealth is the target shown by the prompt each time its name or
+	// health changed.
+	TargetHealth []prompt.Combatant
+	// Over is set once a prompt showed the fight had ended.
+	Over bool
+}
+
+// HealthLost - How many health levels the player fell during the fight
+func (f *Fight) HealthLost() int {
+	start, low := prompt.HealthRank(f.StartHealth), prompt.HealthRank(f.LowHealth)
+	if start < 0 || low < start {
+		return 0
+	}
+	return low - start
+}
+
+// Weapon - The attack the player used most in the fight
+func (f *Fight) Weapon() string {
+	weapon := ""
+	for verb, n := range f.Weapons {
+		if n > f.Weapons[weapon] || (n == f.Weapons[weapon] && verb < weapon) {
+			weapon = verb
+		}
+	}
+	return weapon
+}
+
+func (f *Fight) addOpponent(name string) {
+	if name != "" && name != You && !contains(f.Opponents, name) {
+		f.Opponents = append(f.Opponents, name)
+	}
+}
+
+func (f *Fight) health(health string) {
+	if f.StartHealth == "" {
+		f.StartHealth, f.LowHealth = health, health
+	}
+	if prompt.HealthRank(health) > prompt.HealthRank(f.LowHealth) {
+		f.LowHealth = health
+	}
+	f.EndHealth = health
+}
+
+// An Analyzer splits the lines of a session into Fights.
+type Analyzer struct {
+	fights []*Fight
+	fight  *Fight
+	health string // the player's health at the last prompt
+}
+
+// Observe - Feed a line of output into the Analyzer
+func (a *Analyzer) Observe(line wotmud.Line) {
+	ev := Parse(wotmud.StripANSI(line.Text()))
+	info := line.Prompt()
+
+	if a.fight == nil && (ev != nil && (ev.ByYou() || ev.AtYou()) || info != nil && info.Combat != nil) {
+		a.fight = &Fight{}
+		a.fights = append(a.fights, a.fight)
+		if a.health != "" {
+			a.fight.health(a.health)
+		}
+	}
+	if info != nil {
+		a.health = info.Health
+	}
+
+	f := a.fight
+	if f == nil {
+		return
+	}
+	if info != nil {
+		f.health(info.Health)
+		if info.Combat != nil {
+			f.Rounds++
+			target := info.Combat.Target
+			f.addOpponent(target.Name)
+			if last := len(f.TargetHealth) - 1; last < 0 || f.TargetHealth[last] != target {
+				f.TargetHealth = append(f.TargetHealth, target)
+			}
+		}
+	}
+
+	if ev != nil && ev.Outcome != Join {
+		switch {
+		case ev.ByYou():
+			f.Dealt.add(ev)
+			f.addOpponent(ev.Defender)
+			if ev.Attack != "" {
+				if f.Weapons == nil {
+					f.Weapons = make(map[string]int)
+				}
+				f.Weapons[ev.Attack]++
+			}
+		case ev.AtYou():
+			f.Taken.add(ev)
+			f.addOpponent(ev.Attacker)
+		}
+	}
+
+	if info != nil && info.Combat == nil && ev == nil {
+		f.Over = true
+		a.fight = nil
+	}
+}
+
+// Fights - The fights seen so far, the last of which may not be over
+func (a *Analyzer) Fights() []*Fight {
+	return a.fights
+}
@@ -0,0 +1,145 @@
+// Package comm recognizes what players and mobs say to each other on the
+// communication channels of WoTMUD, such as chat, narrate and tell, and keeps
+// a searchable history of it.
+package comm
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// You is the name used in a Message for the player.
+const You = "you"
+
+// Channels of communication.
+const (
+	Chat    = "chat"
+	Narrate = "narrate"
+	Say     = "say"
+	Tell    = "tell"
+	Whisper = "whisper"
+	Yell    = "yell"
+	Shout   = "shout"
+	Bellow  = "bellow"
+)
+
+// A Message is one thing said on a channel. Speaker and, for tells and
+// whispers, To are named as in the line, or You for the player.
+type Message struct {
+	Speaker string
+	Channel string
+	To      string
+	Text    string
+}
+
+// FromYou - Whether the player said it
+func (m *Message) FromYou() bool {
+	return m.Speaker == You
+}
+
+// ToYou - Whether it was told or whispered to the player
+func (m *Message) ToYou() bool {
+	return m.To == You
+}
+
+// channels maps the verbs of a line to their channel, in both the form used
+// for the player and the form used for others.
+var channels = map[string]string{
+	"chat": Chat, "chats": Chat,
+	"narrate": Narrate, "narrates": Narrate,
+	"say": Say, "says": Say,
+	"yell": Yell, "yells": Yell,
+	"shout": Shout, "shouts": Shout,
+	"bellow": Bellow, "bellows": Bellow,
+	"tell": Tell, "tells": Tell, "reply to": Tell,
+	"whisper to": Whisper, "whispers to": Whisper,
+}
+
+var (
+	yourSpeech = regexp.MustCompile(`^You (chat|narrate|say|yell|shout|bellow) '(.*)'$`)
+	speech     = regexp.MustCompile(`^(.+?) (chats|narrates|says|yells|shouts|bellows) '(.*)'$`)
+	yourTell   = regexp.MustCompile(`^You (tell|reply to|whisper to) (.+?),? '(.*)'$`)
+	tellToYou  = regexp.MustCompile(`^(.+?) (tells|whispers to) you,? '(.*)'$`)
+)
+
+// Parse - Recognize a message in text, the part of a line of output following
+// any prompt with its ANSI codes removed. It returns nil for text that is not
+// a message.
+func Parse(text string) *Message {
+	text = strings.TrimSpace(text)
+	if match := yourSpeech.FindStringSubmatch(text); match != nil {
+		return &Message{Speaker: You, Channel: channels[match[1]], Text: match[2]}
+	}
+	if match := yourTell.FindStringSubmatch(text); match != nil {
+		return &Message{Speaker: You, Channel: channels[match[1]], To: match[2], Text: match[3]}
+	}
+	if match := tellToYou.FindStringSubmatch(text); match != nil {
+		return &Message{Speaker: match[1], Channel: channels[match[2]], To: You, Text: match[3]}
+	}
+	if match := speech.FindStringSubmatch(text); match != nil {
+		return &Message{Speaker: match[1], Channel: channels[match[2]], Text: match[3]}
+	}
+	return nil
+}
+
+// DefaultCapacity is how many messages a History keeps by default.
+const DefaultCapacity = 1000
+
+// An Entry is a Message kept in a History with the world it came from and
+// the line that carried it.
+type Entry struct {
+	World string
+	Line  string
+	Message
+}
+
+// A History keeps the latest messages, dropping the oldest once it holds its
+// capacity. It is safe for concurrent use.
+type History struct {
+	mu       sync.Mutex
+	entries  []Entry
+	capacity int
+}
+
+// NewHistory - Create a History of up to capacity messages
+func NewHistory(capacity int) *History {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	return &History{capacity: capacity}
+}
+
+// Add - Keep an entry
+func (h *History) Add(entry Entry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > h.capacity {
+		h.entries = append(h.entries[:0:0], h.entries[len(h.entries)-h.capacity:]...)
+	}
+}
+
+// Search - The entries, oldest first, whose line matches re. A nil re
+// matches every entry.
+func (h *History) Search(re *regexp.Regexp) []Entry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var found []Entry
+	for _, entry := range h.entries {
+		if re == nil || re.MatchString(entry.Line) {
+			found = append(found, entry)
+		}
+	}
+	return found
+}
+
+// Len - The number of entries kept
+func (h *History) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.entries)
+}
@@ -0,0 +1,78 @@
+package comm_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/huntwj/gofugue/wotmud/comm"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		text     string
+		expected comm.Message
+	}{
+		{"Erulisse narrates 'seanchan altara'",
+			comm.Message{Speaker: "Erulisse", Channel: comm.Narrate, Text: "seanchan altara"}},
+		{"Vaeyl chats 'it's south'",
+			comm.Message{Speaker: "Vaeyl", Channel: comm.Chat, Text: "it's south"}},
+		{"You chat 'anyone want/need an exping buddy?'",
+			comm.Message{Speaker: comm.You, Channel: comm.Chat, Text: "anyone want/need an exping buddy?"}},
+		{"You say 'hello'",
+			comm.Message{Speaker: comm.You, Channel: comm.Say, Text: "hello"}},
+		{"A peddler says 'Fine wares!'",
+			comm.Message{Speaker: "A peddler", Channel: comm.Say, Text: "Fine wares!"}},
+		{"Dal tells you 'where are you?'",
+			comm.Message{Speaker: "Dal", Channel: comm.Tell, To: comm.You, Text: "where are you?"}},
+		{"The innkeeper tells you, '   15 copper for an oddly curved longsword.'",
+			comm.Message{Speaker: "The innkeeper", Channel: comm.Tell, To: comm.You, Text: "   15 copper for an oddly curved longsword."}},
+		{"A banker whispers to you 'Your balance is 3 gold.'",
+			comm.Message{Speaker: "A banker", Channel: comm.Whisper, To: comm.You, Text: "Your balance is 3 gold."}},
+		{"You tell Dal 'by the ford'",
+			comm.Message{Speaker: comm.You, Channel: comm.Tell, To: "Dal", Text: "by the ford"}},
+		{"You reply to Cailte 'on my way'",
+			comm.Message{Speaker: comm.You, Channel: comm.Tell, To: "Cailte", Text: "on my way"}},
+		{"The Whitebridge town crier bellows 'Hear ye!'",
+			comm.Message{Speaker: "The Whitebridge town crier", Channel: comm.Bellow, Text: "Hear ye!"}},
+	}
+	for _, test := range tests {
+		msg := comm.Parse(test.text)
+		if msg == nil {
+			t.Errorf("Expected %q to be a message", test.text)
+			continue
+		}
+		if *msg != test.expected {
+			t.Errorf("For %q expected %+v but found %+v", test.text, test.expected, *msg)
+		}
+	}
+
+	for _, text := range []string{
+		"Scattered animal droppings tell you this area is not deserted, despite",
+		"A ragged looking man asks for spare coins.",
+		"roads can be heard like whispers on the breeze through the trees, but the",
+	} {
+		if msg := comm.Parse(text); msg != nil {
+			t.Errorf("Expected %q not to be a message but found %+v", text, *msg)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+
+	h := comm.NewHistory(2)
+	for _, line := range []string{"Dal chats 'one'", "Dal chats 'two'", "Isolda narrates 'three'"} {
+		h.Add(comm.Entry{World: "Freddie", Line: line, Message: *comm.Parse(line)})
+	}
+	if h.Len() != 2 {
+		t.Errorf("Expected the oldest message to be dropped but found %d", h.Len())
+	}
+	if found := h.Search(regexp.MustCompile(`^Dal`)); len(found) != 1 || found[0].Text != "two" {
+		t.Errorf("Unexpected search result %+v", found)
+	}
+	if found := h.Search(nil); len(found) != 2 || found[1].Speaker != "Isolda" {
+		t.Errorf("Unexpected history %+v", found)
+	}
+}
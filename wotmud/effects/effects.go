@@ -0,0 +1,261 @@
+// Package effects keeps track of the effects the player is subjected to,
+// such as NO QUIT, from the listing shown by the game and from the messages
+// shown as effects start and end. How long each effect lasts is learned from
+// the times it was seen to start and end, so that the time left on an active
+// effect can be estimated.
+package effects
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/huntwj/gofugue/wotmud"
+)
+
+// NoQuit is the effect that keeps the player from quitting after a fight.
+// It is renewed on every round of combat.
+const NoQuit = "NO QUIT"
+
+// historySize is how many durations are kept for each effect.
+const historySize = 10
+
+var (
+	header = regexp.MustCompile(`^You are subjected to the following effects:$`)
+	listed = regexp.MustCompile(`^- (.+)$`)
+)
+
+// An Effect is an effect the player is subjected to. Since is when it
+// started or was last renewed, and is zero when that was not seen. Seen is
+// when it was last known to be active. Duration is how long it usually
+// lasts, or 0 when that is not known yet.
+type Effect struct {
+	Name     string
+	Since    time.Time
+	Seen     time.Time
+	Duration time.Duration
+}
+
+// Remaining - The estimated time left on the effect at now, reporting false
+// when there is no estimate
+func (e Effect) Remaining(now time.Time) (time.Duration, bool) {
+	if e.Since.IsZero() || e.Duration == 0 {
+		return 0, false
+	}
+	left := e.Since.Add(e.Duration).Sub(now)
+	if left < 0 {
+		left = 0
+	}
+	return left, true
+}
+
+// A Change is an effect starting or ending. Estimated is set for an effect
+// taken to have ended because its usual duration has passed.
+type Change struct {
+	Name      string
+	Ended     bool
+	Estimated bool
+}
+
+// messages are the lines that start and end an effect.
+type messages struct {
+	name       string
+	start, end *regexp.Regexp
+}
+
+// A Tracker follows the player's effects. It is safe for concurrent use.
+type Tracker struct {
+	mu        sync.Mutex
+	active    map[string]*Effect
+	durations map[string][]time.Duration
+	messages  []messages
+	listing   bool            // reading the listing of effects
+	listed    map[string]bool // the effects listed so far
+}
+
+// New - Create a Tracker for a player subjected to no effects
+func New() *Tracker {
+	return &Tracker{
+		active:    make(map[string]*Effect),
+		durations: make(map[string][]time.Duration),
+	}
+}
+
+// Track - Recognize lines matching start or end as the named effect starting
+// or ending. Either may be nil.
+func (t *Tracker) Track(name string, start, end *regexp.Regexp) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.messages = append(t.messages, messages{name: name, start: start, end: end})
+}
+
+// Observe - Feed a line of output, received at now, into the Tracker,
+// returning the effects it started or ended
+func (t *Tracker) Observe(line wotmud.Line, now time.Time) []Change {
+	text := strings.TrimSpace(wotmud.StripANSI(line.Text()))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var changes []Change
+	if info := line.Prompt(); info != nil && info.Combat != nil {
+		changes = append(changes, t.start(NoQuit, now)...)
+	}
+
+	// The listing ends with a blank line or a prompt. Other output, such as
+	// a narrate, can arrive in the middle of it.
+	if t.listing {
+		if match := listed.FindStringSubmatch(text); match != nil && line.Prompt() == nil {
+			name := strings.TrimSpace(match[1])
+			t.listed[name] = true
+			if e, ok := t.active[name]; ok {
+				e.Seen = now
+			} else {
+				t.active[name] = &Effect{Name: name, Seen: now}
+				changes = append(changes, Change{Name: name})
+			}
+			return changes
+		}
+		if text == "" || line.Prompt() != nil {
+			changes = append(changes, t.endListing(now)...)
+		}
+	}
+
+	if header.MatchString(text) {
+		t.listing, t.listed = true, make(map[string]bool)
+		return changes
+	}
+	for _, m := range t.messages {
+		if m.start != nil && m.start.MatchString(text) {
+			changes = append(changes, t.start(m.name, now)...)
+		}
+		if m.end != nil && m.end.MatchString(text) {
+			if e, ok := t.active[m.name]; ok {
+				changes = append(changes, t.end(e, now))
+			}
+		}
+	}
+	return changes
+}
+
+// start notes that the named effect started or was renewed at now.
+func (t *Tracker) start(name string, now time.Time) []Change {
+	if e, ok := t.active[name]; ok {
+		e.Since, e.Seen = now, now
+		return nil
+	}
+	t.active[name] = &Effect{Name: name, Since: now, Seen: now}
+	return []Change{{Name: name}}
+}
+
+// endListing ends the effects missing from a listing that ended at now.
+// Having been seen active last at e.Seen, each is taken to have ended half
+// way between then and now.
+func (t *Tracker) endListing(now time.Time) []Change {
+	var changes []Change
+	for _, name := range t.names() {
+		if e := t.active[name]; !t.listed[name] {
+			changes = append(changes, t.end(e, e.Seen.Add(now.Sub(e.Seen)/2)))
+		}
+	}
+	t.listing, t.listed = false, nil
+	return changes
+}
+
+// end removes an effect that ended at the given time, learning its duration
+// when its start was seen.
+func (t *Tracker) end(e *Effect, at time.Time) Change {
+	delete(t.active, e.Name)
+	if !e.Since.IsZero() && at.After(e.Since) {
+		durations := append(t.durations[e.Name], at.Sub(e.Since))
+		if len(durations) > historySize {
+			durations = durations[len(durations)-historySize:]
+		}
+		t.durations[e.Name] = durations
+	}
+	return Change{Name: e.Name, Ended: true}
+}
+
+// estimate is the average of the durations seen for the named effect.
+func (t *Tracker) estimate(name string) time.Duration {
+	durations := t.durations[name]
+	if len(durations) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	return total / time.Duration(len(durations))
+}
+
+// names lists the active effects in order.
+func (t *Tracker) names() []string {
+	names := make([]string, 0, len(t.active))
+	for name := range t.active {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Expire - End the effects whose estimated duration has run out by now
+func (t *Tracker) Expire(now time.Time) []Change {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var changes []Change
+	for _, name := range t.names() {
+		e := t.active[name]
+		e.Duration = t.estimate(name)
+		if left, ok := e.Remaining(now); ok && left == 0 {
+			delete(t.active, name)
+			changes = append(changes, Change{Name: name, Ended: true, Estimated: true})
+		}
+	}
+	return changes
+}
+
+// Next - When the first of the active effects is estimated to run out,
+// reporting false when there is no estimate for any of them
+func (t *Tracker) Next() (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var next time.Time
+	for name, e := range t.active {
+		if duration := t.estimate(name); !e.Since.IsZero() && duration > 0 {
+			if at := e.Since.Add(duration); next.IsZero() || at.Before(next) {
+				next = at
+			}
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// Active - The active effects, ordered by name
+func (t *Tracker) Active() []Effect {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	effects := make([]Effect, 0, len(t.active))
+	for _, name := range t.names() {
+		e := *t.active[name]
+		e.Duration = t.estimate(name)
+		effects = append(effects, e)
+	}
+	return effects
+}
+
+// Estimate - How long the named effect usually lasts, reporting false when
+// it has not been seen to start and end
+func (t *Tracker) Estimate(name string) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	d := t.estimate(name)
+	return d, d > 0
+}
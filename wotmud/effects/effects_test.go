@@ -0,0 +1,141 @@
+package effects_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/effects"
+)
+
+func TestListing(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := effects.New()
+	observe := func(at time.Duration, lines ...string) []effects.Change {
+		var changes []effects.Change
+		for _, raw := range lines {
+			changes = append(changes, tracker.Observe(wotmud.NewLine(raw), start.Add(at))...)
+		}
+		return changes
+	}
+
+	changes := observe(0, "You are subjected to the following effects:", "- NO QUIT ", "- NOTICE ", "", "* HP:Healthy MV:Fresh > ")
+	if len(changes) != 2 || changes[0] != (effects.Change{Name: "NO QUIT"}) || changes[1] != (effects.Change{Name: "NOTICE"}) {
+		t.Errorf("Expected two effects to start but found %+v", changes)
+	}
+	active := tracker.Active()
+	if len(active) != 2 || !active[0].Since.IsZero() {
+		t.Errorf("Expected listed effects with unknown starts but found %+v", active)
+	}
+
+	changes = observe(time.Minute, "* HP:Healthy MV:Fresh > You are subjected to the following effects:", "- NOTICE ", "* HP:Healthy MV:Fresh > ")
+	if len(changes) != 1 || changes[0] != (effects.Change{Name: "NO QUIT", Ended: true}) {
+		t.Errorf("Expected NO QUIT to end but found %+v", changes)
+	}
+	if _, ok := tracker.Estimate(effects.NoQuit); ok {
+		t.Error("Expected no estimate for an effect whose start was not seen")
+	}
+
+	changes = observe(2*time.Minute, "You are subjected to the following effects:", "* HP:Healthy MV:Fresh > ")
+	if len(changes) != 1 || changes[0].Name != "NOTICE" || len(tracker.Active()) != 0 {
+		t.Errorf("Expected an empty listing to end NOTICE but found %+v", changes)
+	}
+}
+
+func TestListingInterrupted(t *testing.T) {
+	t.Parallel()
+
+	tracker := effects.New()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, raw := range []string{"You are subjected to the following effects:", "- NO QUIT ", "- NOTICE ", "", "* HP:Healthy MV:Fresh > "} {
+		tracker.Observe(wotmud.NewLine(raw), now)
+	}
+
+	var changes []effects.Change
+	for _, raw := range []string{
+		"You are subjected to the following effects:",
+		"\x1b[33mZygoat narrates 'ragan'\x1b[0m",
+		"- NO QUIT ",
+		"- NOTICE ",
+		"",
+		"* HP:Healthy MV:Haggard > ",
+	} {
+		changes = append(changes, tracker.Observe(wotmud.NewLine(raw), now.Add(time.Minute))...)
+	}
+	if len(changes) != 0 || len(tracker.Active()) != 2 {
+		t.Errorf("Expected a narrate not to end the listing but found %+v", changes)
+	}
+}
+
+func TestNoQuitAfterFight(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := effects.New()
+	observe := func(at time.Duration, raw string) []effects.Change {
+		return tracker.Observe(wotmud.NewLine(raw), start.Add(at))
+	}
+
+	changes := observe(0, "* R HP:Healthy MV:Fresh - the ancient tree: Hurt > ")
+	if len(changes) != 1 || changes[0] != (effects.Change{Name: effects.NoQuit}) {
+		t.Fatalf("Expected a fight to start NO QUIT but found %+v", changes)
+	}
+	if changes := observe(10*time.Second, "* R HP:Healthy MV:Fresh - the ancient tree: Critical > "); len(changes) != 0 {
+		t.Errorf("Expected NO QUIT to be renewed but found %+v", changes)
+	}
+	if _, ok := tracker.Next(); ok {
+		t.Error("Expected no estimate before NO QUIT has been seen to end")
+	}
+
+	// Seen at 2m10s and missing at 2m50s, it ended at 2m30s: 2m20s after the
+	// last round.
+	observe(2*time.Minute+10*time.Second, "You are subjected to the following effects:")
+	observe(2*time.Minute+10*time.Second, "- NO QUIT")
+	observe(2*time.Minute+10*time.Second, "")
+	observe(2*time.Minute+50*time.Second, "You are subjected to the following effects:")
+	observe(2*time.Minute+50*time.Second, "")
+	if d, ok := tracker.Estimate(effects.NoQuit); !ok || d != 2*time.Minute+20*time.Second {
+		t.Errorf("Expected NO QUIT to last 2m20s but found %v", d)
+	}
+
+	observe(time.Hour, "* R HP:Healthy MV:Fresh - a wild dog: Hurt > ")
+	next, ok := tracker.Next()
+	if !ok || !next.Equal(start.Add(time.Hour+2*time.Minute+20*time.Second)) {
+		t.Errorf("Unexpected estimated end %v", next)
+	}
+	if left, ok := tracker.Active()[0].Remaining(start.Add(time.Hour + time.Minute)); !ok || left != 80*time.Second {
+		t.Errorf("Expected 80s left but found %v", left)
+	}
+	if changes := tracker.Expire(start.Add(time.Hour + 2*time.Minute)); len(changes) != 0 {
+		t.Errorf("Expected NO QUIT not to have run out yet but found %+v", changes)
+	}
+	changes = tracker.Expire(next)
+	if len(changes) != 1 || changes[0] != (effects.Change{Name: effects.NoQuit, Ended: true, Estimated: true}) {
+		t.Errorf("Expected NO QUIT to run out but found %+v", changes)
+	}
+	if d, _ := tracker.Estimate(effects.NoQuit); d != 2*time.Minute+20*time.Second {
+		t.Errorf("Expected an estimated end not to change the estimate but found %v", d)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := effects.New()
+	tracker.Track("INCOGNITO", regexp.MustCompile(`^You are now incognito\.$`), regexp.MustCompile(`^You are no longer incognito\.$`))
+
+	if changes := tracker.Observe(wotmud.NewLine("* HP:Healthy MV:Fresh > You are now incognito."), start); len(changes) != 1 {
+		t.Errorf("Expected INCOGNITO to start but found %+v", changes)
+	}
+	changes := tracker.Observe(wotmud.NewLine("\x1b[33mYou are no longer incognito.\x1b[0m"), start.Add(time.Minute))
+	if len(changes) != 1 || !changes[0].Ended {
+		t.Errorf("Expected INCOGNITO to end but found %+v", changes)
+	}
+	if d, ok := tracker.Estimate("INCOGNITO"); !ok || d != time.Minute {
+		t.Errorf("Expected INCOGNITO to last a minute but found %v", d)
+	}
+}
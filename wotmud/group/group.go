@@ -0,0 +1,157 @@
+// Package group keeps track of the player's group from the output of the
+// group command and the messages shown as members join and leave.
+package group
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/prompt"
+)
+
+// A Member is someone in the group. Health and Moves are the words the game
+// uses in the prompt, and are empty unless the group output showed them.
+type Member struct {
+	Name   string
+	Leader bool
+	Health string
+	Moves  string
+}
+
+var (
+	header  = regexp.MustCompile(`^Your group consists of:$`)
+	joined  = regexp.MustCompile(`^(.+) is now a member of your group\.$`)
+	left    = regexp.MustCompile(`^(.+) is no longer a member of your group\.$`)
+	leader  = regexp.MustCompile(`\s*\(Head of group\)`)
+	status  = regexp.MustCompile(`\s+\[?(?:HP:)?` + alternatives(prompt.Healths) + `\s+(?:MV:)?` + alternatives(prompt.Movements) + `\]?$`)
+	spacing = regexp.MustCompile(`\s+`)
+)
+
+// alternatives builds a regexp group matching any of words.
+func alternatives(words []string) string {
+	quoted := make([]string, len(words))
+	for idx, word := range words {
+		quoted[idx] = regexp.QuoteMeta(word)
+	}
+	return "(" + strings.Join(quoted, "|") + ")"
+}
+
+// parseMember reads a line listing a member in the output of the group
+// command, such as "Freddie (Head of group)".
+func parseMember(text string) Member {
+	var m Member
+	if match := status.FindStringSubmatchIndex(text); match != nil {
+		m.Health, m.Moves = text[match[2]:match[3]], text[match[4]:match[5]]
+		text = text[:match[0]]
+	}
+	if loc := leader.FindStringIndex(text); loc != nil {
+		m.Leader = true
+		text = text[:loc[0]] + text[loc[1]:]
+	}
+	m.Name = spacing.ReplaceAllString(strings.TrimSpace(text), " ")
+	return m
+}
+
+// A Group is the live model of the player's group. The member list is
+// replaced whenever the group command lists it and updated as members join
+// and leave. It is safe for concurrent use.
+type Group struct {
+	mu      sync.Mutex
+	members []Member
+	listing bool     // reading the output of the group command
+	pending []Member // the members listed so far
+}
+
+// New - Create a Group for a player who is not known to be in one
+func New() *Group {
+	return &Group{}
+}
+
+// Observe - Feed a line of output into the Group, reporting whether the
+// group changed
+func (g *Group) Observe(line wotmud.Line) bool {
+	text := strings.TrimSpace(wotmud.StripANSI(line.Text()))
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.listing {
+		if text != "" && line.Prompt() == nil {
+			g.pending = append(g.pending, parseMember(text))
+			return false
+		}
+		g.listing = false
+		g.members, g.pending = g.pending, nil
+		return true
+	}
+
+	switch {
+	case header.MatchString(text):
+		g.listing, g.pending = true, nil
+	case joined.MatchString(text):
+		name := joined.FindStringSubmatch(text)[1]
+		if g.find(name) < 0 {
+			g.members = append(g.members, Member{Name: name})
+			return true
+		}
+	case left.MatchString(text):
+		name := left.FindStringSubmatch(text)[1]
+		if idx := g.find(name); idx >= 0 {
+			g.members = append(g.members[:idx:idx], g.members[idx+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Group) find(name string) int {
+	for idx, m := range g.members {
+		if strings.EqualFold(m.Name, name) {
+			return idx
+		}
+	}
+	return -1
+}
+
+// Members - The members of the group, in the order the game lists them
+func (g *Group) Members() []Member {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return append([]Member(nil), g.members...)
+}
+
+// Leader - The name of the head of the group, or "" if it is not known
+func (g *Group) Leader() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, m := range g.members {
+		if m.Leader {
+			return m.Name
+		}
+	}
+	return ""
+}
+
+// Member - Find the member called name, ignoring case
+func (g *Group) Member(name string) (Member, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if idx := g.find(name); idx >= 0 {
+		return g.members[idx], true
+	}
+	return Member{}, false
+}
+
+// Tank - The member of the group tanking in the fight shown by a prompt. It
+// reports false when nobody else is tanking or the tank is not in the group.
+func (g *Group) Tank(c *prompt.Combat) (Member, bool) {
+	if c == nil || c.Tank == nil {
+		return Member{}, false
+	}
+	return g.Member(c.Tank.Name)
+}
@@ -0,0 +1,81 @@
+package group_test
+
+import (
+	"testing"
+
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/group"
+)
+
+func observe(g *group.Group, lines ...string) {
+	for _, raw := range lines {
+		g.Observe(wotmud.NewLine(raw))
+	}
+}
+
+func names(g *group.Group) []string {
+	var names []string
+	for _, m := range g.Members() {
+		names = append(names, m.Name)
+	}
+	return names
+}
+
+func TestGroupListing(t *testing.T) {
+	t.Parallel()
+
+	g := group.New()
+	observe(g,
+		"Your group consists of:",
+		"Freddie (Head of group)",
+		"a warhorse",
+		"Dal            HP:Hurt MV:Tiring",
+	)
+	if len(g.Members()) != 0 {
+		t.Errorf("Expected the listing to apply once it ends but found %v", names(g))
+	}
+	observe(g, "", "* HP:Healthy MV:Full - a grayish-green moss: Wounded > ")
+
+	members := g.Members()
+	if len(members) != 3 || g.Leader() != "Freddie" {
+		t.Fatalf("Unexpected group %+v", members)
+	}
+	if members[1] != (group.Member{Name: "a warhorse"}) {
+		t.Errorf("Unexpected member %+v", members[1])
+	}
+	if members[2] != (group.Member{Name: "Dal", Health: "Hurt", Moves: "Tiring"}) {
+		t.Errorf("Unexpected member %+v", members[2])
+	}
+
+	observe(g,
+		"\x1b[32mDal is no longer a member of your group.\x1b[0m",
+		"A trained peregrine falcon is now a member of your group.",
+	)
+	if got := names(g); len(got) != 3 || got[1] != "a warhorse" || got[2] != "A trained peregrine falcon" {
+		t.Errorf("Unexpected members after leaving and joining %v", got)
+	}
+
+	observe(g, "* HP:Healthy MV:Full > Your group consists of:", "Dal (Head of group)", "* HP:Healthy MV:Full > ")
+	if got := names(g); len(got) != 1 || g.Leader() != "Dal" {
+		t.Errorf("Expected the listing to replace the group but found %v", got)
+	}
+}
+
+func TestGroupTank(t *testing.T) {
+	t.Parallel()
+
+	g := group.New()
+	observe(g, "Your group consists of:", "Freddie (Head of group)", "Dal", "")
+
+	line := wotmud.NewLine("* HP:Healthy MV:Fresh - Dal: Hurt - the ancient tree: Wounded > ")
+	if tank, ok := g.Tank(line.Prompt().Combat); !ok || tank.Name != "Dal" {
+		t.Errorf("Expected Dal to be the tank but found %+v", tank)
+	}
+	line = wotmud.NewLine("* HP:Healthy MV:Fresh - a Trolloc: Hurt - the ancient tree: Wounded > ")
+	if tank, ok := g.Tank(line.Prompt().Combat); ok {
+		t.Errorf("Expected no member to be the tank but found %+v", tank)
+	}
+	if _, ok := g.Tank(nil); ok {
+		t.Error("Expected no tank outside of a fight")
+	}
+}
@@ -0,0 +1,338 @@
+// Package inventory keeps track of what the player carries and uses, from
+// the output of the inventory and equipment commands, the listings of
+// containers looked in and the messages shown as items are moved about.
+package inventory
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/huntwj/gofugue/wotmud"
+)
+
+// An Item is one or more identical items, named as the game shows them.
+type Item struct {
+	Name  string
+	Count int
+}
+
+// Worn is an item in use, with the slot the equipment command shows it in
+// such as "worn on finger" or "used as light".
+type Worn struct {
+	Slot string
+	Item string
+}
+
+// A Container is the listing of a container looked in. Where is how the
+// game described it: "used", "here" or "carried".
+type Container struct {
+	Name     string
+	Where    string
+	Contents []Item
+}
+
+// Slots used by the messages that equip items.
+const (
+	Wielded    = "wielded"
+	TwoHanded  = "wielded two-handed"
+	Held       = "held"
+	Light      = "used as light"
+	wornPrefix = "worn "
+)
+
+var (
+	carrying  = regexp.MustCompile(`^You are carrying:$`)
+	using     = regexp.MustCompile(`^You are using:$`)
+	container = regexp.MustCompile(`^(.+?) \((used|here|carried)\) :$`)
+	counted   = regexp.MustCompile(`^\[(\d+)\]\s+(.+)$`)
+	slot      = regexp.MustCompile(`^<([^>]+)>\s+(.+)$`)
+	liquid    = regexp.MustCompile(`^It's (empty|(?:less than half |about half |more than half )?full)\b`)
+
+	getFrom  = regexp.MustCompile(`^You get (.+?) from (.+)\.$`)
+	get      = regexp.MustCompile(`^You get (.+)\.$`)
+	putIn    = regexp.MustCompile(`^You put (.+?) in (.+)\.$`)
+	wear     = regexp.MustCompile(`^You (?:wear|put) (.+?) (on|around|about|over) your (.+?)\.$`)
+	wieldTwo = regexp.MustCompile(`^You wield (.+) with both hands\.$`)
+	wield    = regexp.MustCompile(`^You wield (.+)\.$`)
+	hold     = regexp.MustCompile(`^You hold (.+)\.$`)
+	light    = regexp.MustCompile(`^You hold (.+) above your head\.$`)
+	stop     = regexp.MustCompile(`^You stop using (.+)\.$`)
+	drop     = regexp.MustCompile(`^You (?:drop|junk) (.+)\.$`)
+	give     = regexp.MustCompile(`^You give (.+?) to (.+)\.$`)
+	given    = regexp.MustCompile(`^(.+?) gives you (.+)\.$`)
+	lookIn   = regexp.MustCompile(`^(?:l|lo|loo|look|exa|exam|exami|examin|examine)\s+(?:in\s+)?(?:\d+\.)?(\S+)$`)
+)
+
+// listing is the kind of listing being read.
+type listing int
+
+const (
+	none listing = iota
+	carried
+	used
+	contents
+)
+
+// An Inventory is the live model of the player's items. It is safe for
+// concurrent use.
+type Inventory struct {
+	mu         sync.Mutex
+	carried    []Item
+	used       []Worn
+	containers map[string]*Container
+	liquids    map[string]string
+
+	listing listing
+	open    *Container // the container being listed
+	looking string     // the keyword of the item last looked in
+}
+
+// New - Create an Inventory for a player whose items are not known yet
+func New() *Inventory {
+	return &Inventory{
+		containers: make(map[string]*Container),
+		liquids:    make(map[string]string),
+	}
+}
+
+// Sent - Note a command sent to the world, so that how full an item looked
+// in is can be kept
+func (inv *Inventory) Sent(cmd string) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	inv.looking = ""
+	if match := lookIn.FindStringSubmatch(strings.TrimSpace(cmd)); match != nil {
+		inv.looking = strings.ToLower(match[1])
+	}
+}
+
+// Observe - Feed a line of output into the Inventory, reporting whether what
+// the player carries or uses changed
+func (inv *Inventory) Observe(line wotmud.Line) bool {
+	text := strings.TrimSpace(wotmud.StripANSI(line.Text()))
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	changed := false
+	if inv.listing != none {
+		if text != "" && line.Prompt() == nil {
+			inv.listed(text)
+			return false
+		}
+		changed = inv.listing != contents
+		inv.listing, inv.open = none, nil
+	}
+
+	switch {
+	case carrying.MatchString(text):
+		inv.listing, inv.carried = carried, nil
+	case using.MatchString(text):
+		inv.listing, inv.used = used, nil
+	case container.MatchString(text):
+		match := container.FindStringSubmatch(text)
+		inv.open = &Container{Name: match[1], Where: match[2]}
+		inv.containers[strings.ToLower(match[1])] = inv.open
+		inv.listing = contents
+	case liquid.MatchString(text):
+		if inv.looking != "" {
+			inv.liquids[inv.looking] = liquid.FindStringSubmatch(text)[1]
+		}
+	default:
+		return inv.moved(text) || changed
+	}
+	return changed
+}
+
+// listed reads a line of the listing being read.
+func (inv *Inventory) listed(text string) {
+	if text == "Nothing." {
+		return
+	}
+	switch inv.listing {
+	case carried:
+		inv.carried = add(inv.carried, parseItem(text))
+	case used:
+		if match := slot.FindStringSubmatch(text); match != nil {
+			inv.used = append(inv.used, Worn{Slot: match[1], Item: strings.TrimSpace(match[2])})
+		}
+	case contents:
+		inv.open.Contents = add(inv.open.Contents, parseItem(text))
+	}
+}
+
+// moved follows the messages moving an item, reporting whether what the
+// player carries or uses changed.
+func (inv *Inventory) moved(text string) bool {
+	if match := getFrom.FindStringSubmatch(text); match != nil {
+		if c := inv.container(match[2]); c != nil {
+			c.Contents = remove(c.Contents, match[1])
+		}
+		return inv.gain(match[1])
+	}
+	if match := putIn.FindStringSubmatch(text); match != nil {
+		inv.carried = remove(inv.carried, match[1])
+		if c := inv.container(match[2]); c != nil {
+			c.Contents = add(c.Contents, Item{Name: match[1], Count: 1})
+		}
+		return true
+	}
+	if match := wear.FindStringSubmatch(text); match != nil {
+		return inv.equip(wornPrefix+match[2]+" "+match[3], match[1])
+	}
+	if match := wieldTwo.FindStringSubmatch(text); match != nil {
+		return inv.equip(TwoHanded, match[1])
+	}
+	if match := wield.FindStringSubmatch(text); match != nil {
+		return inv.equip(Wielded, match[1])
+	}
+	if match := light.FindStringSubmatch(text); match != nil {
+		return inv.equip(Light, match[1])
+	}
+	if match := hold.FindStringSubmatch(text); match != nil {
+		return inv.equip(Held, match[1])
+	}
+	if match := stop.FindStringSubmatch(text); match != nil {
+		for idx, worn := range inv.used {
+			if worn.Item == match[1] {
+				inv.used = append(inv.used[:idx:idx], inv.used[idx+1:]...)
+				break
+			}
+		}
+		inv.carried = add(inv.carried, Item{Name: match[1], Count: 1})
+		return true
+	}
+	if match := get.FindStringSubmatch(text); match != nil {
+		return inv.gain(match[1])
+	}
+	if match := given.FindStringSubmatch(text); match != nil {
+		return inv.gain(match[2])
+	}
+	if match := drop.FindStringSubmatch(text); match != nil {
+		inv.carried = remove(inv.carried, match[1])
+		return true
+	}
+	if match := give.FindStringSubmatch(text); match != nil {
+		inv.carried = remove(inv.carried, match[1])
+		return true
+	}
+	return false
+}
+
+// gain adds an item to those carried.
+func (inv *Inventory) gain(name string) bool {
+	inv.carried = add(inv.carried, Item{Name: name, Count: 1})
+	return true
+}
+
+// equip moves an item from those carried into a slot.
+func (inv *Inventory) equip(slot, name string) bool {
+	inv.carried = remove(inv.carried, name)
+	inv.used = append(inv.used, Worn{Slot: slot, Item: name})
+	return true
+}
+
+// container finds the listing of the container an item message names, such
+// as "a soft leather pouch" for the listing of "pouch".
+func (inv *Inventory) container(name string) *Container {
+	name = strings.ToLower(name)
+	for key, c := range inv.containers {
+		if name == key || strings.HasSuffix(name, " "+key) {
+			return c
+		}
+	}
+	return nil
+}
+
+// parseItem reads an item of a listing, such as "[2] a lantern".
+func parseItem(text string) Item {
+	if match := counted.FindStringSubmatch(text); match != nil {
+		count, _ := strconv.Atoi(match[1])
+		return Item{Name: strings.TrimSpace(match[2]), Count: count}
+	}
+	return Item{Name: text, Count: 1}
+}
+
+// add puts item among items, counting it with any of the same name.
+func add(items []Item, item Item) []Item {
+	for idx := range items {
+		if items[idx].Name == item.Name {
+			items[idx].Count += item.Count
+			return items
+		}
+	}
+	return append(items, item)
+}
+
+// remove takes one of the items called name from items.
+func remove(items []Item, name string) []Item {
+	for idx := range items {
+		if items[idx].Name != name {
+			continue
+		}
+		if items[idx].Count > 1 {
+			items[idx].Count--
+			return items
+		}
+		return append(items[:idx:idx], items[idx+1:]...)
+	}
+	return items
+}
+
+// Carried - The items the player carries, in the order the game lists them
+func (inv *Inventory) Carried() []Item {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	return append([]Item(nil), inv.carried...)
+}
+
+// Used - The items the player uses, in the order the game lists them
+func (inv *Inventory) Used() []Worn {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	return append([]Worn(nil), inv.used...)
+}
+
+// InSlot - The items used in a slot such as "held" or "worn on finger"
+func (inv *Inventory) InSlot(slot string) []string {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	var items []string
+	for _, worn := range inv.used {
+		if worn.Slot == slot {
+			items = append(items, worn.Item)
+		}
+	}
+	return items
+}
+
+// Container - The last listing of the container called name, such as
+// "backpack"
+func (inv *Inventory) Container(name string) (Container, bool) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	c, ok := inv.containers[strings.ToLower(name)]
+	if !ok {
+		return Container{}, false
+	}
+	copied := *c
+	copied.Contents = append([]Item(nil), c.Contents...)
+	return copied, true
+}
+
+// Liquid - How full the item last looked in with the given keyword was:
+// "empty", "less than half full", "about half full", "more than half full"
+// or "full". It is "" when the item has not been looked in.
+func (inv *Inventory) Liquid(keyword string) string {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	return inv.liquids[strings.ToLower(keyword)]
+}
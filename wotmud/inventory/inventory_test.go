@@ -0,0 +1,144 @@
+package inventory_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/inventory"
+)
+
+func observe(inv *inventory.Inventory, lines ...string) bool {
+	changed := false
+	for _, raw := range lines {
+		if inv.Observe(wotmud.NewLine(raw)) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+func TestListings(t *testing.T) {
+	t.Parallel()
+
+	inv := inventory.New()
+	observe(inv,
+		"* HP:Healthy MV:Fresh > You are carrying:",
+		"a reinforced boarskin helmet ",
+		"a few pink foxgloves",
+		"[2] a pair of riveted chainmail sleeves ",
+		"",
+		"You are using:",
+		"<used as light>      a mirrored lantern",
+		"<worn on finger>     a gold ring delicately carved with ivy ",
+		"<worn on finger>     a gold ring delicately carved with ivy ",
+		"<wielded two-handed> a bladed feather staff ",
+		"<worn on belt>       a leather water flask",
+		"* HP:Healthy MV:Fresh > backpack (used) : ",
+		"an oilstone ",
+		"[2] a lantern",
+		"* HP:Healthy MV:Fresh > ",
+	)
+
+	expected := []inventory.Item{
+		{Name: "a reinforced boarskin helmet", Count: 1},
+		{Name: "a few pink foxgloves", Count: 1},
+		{Name: "a pair of riveted chainmail sleeves", Count: 2},
+	}
+	if carried := inv.Carried(); !reflect.DeepEqual(carried, expected) {
+		t.Errorf("Expected %+v but found %+v", expected, carried)
+	}
+	if used := inv.Used(); len(used) != 5 || used[4] != (inventory.Worn{Slot: "worn on belt", Item: "a leather water flask"}) {
+		t.Errorf("Unexpected equipment %+v", used)
+	}
+	if rings := inv.InSlot("worn on finger"); len(rings) != 2 {
+		t.Errorf("Expected two rings but found %v", rings)
+	}
+	if staff := inv.InSlot(inventory.TwoHanded); len(staff) != 1 || staff[0] != "a bladed feather staff" {
+		t.Errorf("Unexpected weapon %v", staff)
+	}
+	pack, ok := inv.Container("backpack")
+	if !ok || pack.Where != "used" || len(pack.Contents) != 2 || pack.Contents[1] != (inventory.Item{Name: "a lantern", Count: 2}) {
+		t.Errorf("Unexpected backpack %+v", pack)
+	}
+
+	observe(inv, "You are carrying:", " Nothing.", "")
+	if carried := inv.Carried(); len(carried) != 0 {
+		t.Errorf("Expected to carry nothing but found %+v", carried)
+	}
+}
+
+func TestMovingItems(t *testing.T) {
+	t.Parallel()
+
+	inv := inventory.New()
+	observe(inv, "backpack (used) : ", "[2] a lantern", "")
+
+	tests := []struct {
+		line     string
+		carried  []inventory.Item
+		backpack int
+	}{
+		{"You get a lantern from a backpack.", []inventory.Item{{"a lantern", 1}}, 1},
+		{"You get a rapier from the corpse of the brigand trooper.", []inventory.Item{{"a lantern", 1}, {"a rapier", 1}}, 1},
+		{"You get eight gold crowns from a soft leather pouch.", []inventory.Item{{"a lantern", 1}, {"a rapier", 1}, {"eight gold crowns", 1}}, 1},
+		{"You put a rapier in a backpack.", []inventory.Item{{"a lantern", 1}, {"eight gold crowns", 1}}, 2},
+		{"You put eight gold crowns in a backpack.", []inventory.Item{{"a lantern", 1}}, 3},
+		{"You hold a lantern above your head.", nil, 3},
+		{"You stop using a lantern.", []inventory.Item{{"a lantern", 1}}, 3},
+		{"You drop a lantern.", nil, 3},
+		{"A stable hand gives you a stable ticket.", []inventory.Item{{"a stable ticket", 1}}, 3},
+		{"You give a stable ticket to a stable hand.", nil, 3},
+	}
+	for _, test := range tests {
+		observe(inv, test.line)
+		if carried := inv.Carried(); !reflect.DeepEqual(carried, test.carried) {
+			t.Errorf("After %q expected %+v but found %+v", test.line, test.carried, carried)
+		}
+		if pack, _ := inv.Container("backpack"); len(pack.Contents) != test.backpack {
+			t.Errorf("After %q expected %d items in the backpack but found %+v", test.line, test.backpack, pack.Contents)
+		}
+	}
+
+	observe(inv,
+		"You get a leather water flask.",
+		"You put a leather water flask on your belt.",
+		"You wield a bladed feather staff with both hands.",
+	)
+	expected := []inventory.Worn{
+		{Slot: "worn on belt", Item: "a leather water flask"},
+		{Slot: inventory.TwoHanded, Item: "a bladed feather staff"},
+	}
+	if used := inv.Used(); !reflect.DeepEqual(used, expected) {
+		t.Errorf("Expected %+v but found %+v", expected, used)
+	}
+	if carried := inv.Carried(); len(carried) != 0 {
+		t.Errorf("Expected to carry nothing but found %+v", carried)
+	}
+}
+
+func TestLiquid(t *testing.T) {
+	t.Parallel()
+
+	inv := inventory.New()
+	inv.Sent("look in flask")
+	observe(inv, "It's full of a clear liquid.")
+	if liquid := inv.Liquid("flask"); liquid != "full" {
+		t.Errorf("Expected a full flask but found %q", liquid)
+	}
+	inv.Sent("l in 2.flask")
+	observe(inv, "* HP:Healthy MV:Fresh > It's less than half full of a clear liquid.")
+	if liquid := inv.Liquid("FLASK"); liquid != "less than half full" {
+		t.Errorf("Expected a flask less than half full but found %q", liquid)
+	}
+	inv.Sent("look in flask")
+	observe(inv, "It's empty.")
+	if liquid := inv.Liquid("flask"); liquid != "empty" {
+		t.Errorf("Expected an empty flask but found %q", liquid)
+	}
+	inv.Sent("n")
+	observe(inv, "It's empty.")
+	if liquid := inv.Liquid("n"); liquid != "" {
+		t.Errorf("Expected other commands to be ignored but found %q", liquid)
+	}
+}
@@ -4,13 +4,34 @@ import (
 	"github.com/huntwj/gofugue/wotmud/prompt"
 )
 
+// A Line is a single logical line of server output. If the line starts with a
+// prompt, PromptInfo holds the parsed prompt and PromptEnd is the offset in
+// Raw where the rest of the line begins. Partial is set for text handed out
+// before its newline arrived, which is how prompts are delivered.
 type Line struct {
 	Raw        string
 	PromptInfo *prompt.Info
 	PromptEnd  int
+	Partial    bool
+}
+
+// NewLine builds a Line from clean raw text, parsing the prompt at its start
+// if there is one.
+func NewLine(raw string) Line {
+	info, end := prompt.Parse(raw)
+	return Line{
+		Raw:        raw,
+		PromptInfo: info,
+		PromptEnd:  end,
+	}
 }
 
 // Prompt - get the prompt info for the line
 func (l *Line) Prompt() *prompt.Info {
-	return nil
+	return l.PromptInfo
+}
+
+// Text - get the part of the line following the prompt, if any
+func (l *Line) Text() string {
+	return l.Raw[l.PromptEnd:]
 }
@@ -1 +1,225 @@
 package mapper
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/huntwj/gofugue/wotmud"
+)
+
+// Room titles are the only lines the server prints entirely in cyan.
+var titleRegex = regexp.MustCompile("^\x1b\\[36m(.+)\x1b\\[0m$")
+var exitsRegex = regexp.MustCompile(`^\[ obvious exits: ([^\]]*)\]`)
+
+// darkRegex matches the message shown instead of a room when the room
+// reached is too dark to see.
+var darkRegex = regexp.MustCompile(`^It is pitch black\.\.\.$`)
+
+// directions are the movement commands, by the exit abbreviation they use.
+var directions = map[string]string{
+	"n": "n", "north": "n",
+	"e": "e", "east": "e",
+	"s": "s", "south": "s",
+	"w": "w", "west": "w",
+	"u": "u", "up": "u",
+	"d": "d", "down": "d",
+}
+
+// opposites are the directions leading back the way a move came.
+var opposites = map[string]string{"n": "s", "e": "w", "s": "n", "w": "e", "u": "d", "d": "u"}
+
+// A Room is a location observed in the game. Exits holds the one or two
+// letter abbreviations the server shows, such as N, E, S, W, U and D.
+type Room struct {
+	Name        string
+	Description []string
+	Exits       []string
+}
+
+// A Mapper watches the lines of a session and keeps track of the rooms seen
+// and where the player currently is. A room is observed when its title line is
+// followed by a description and closed by the obvious exits line. Moves sent
+// to the world link the room left to the room seen next, which lets the
+// Mapper find routes between rooms it has seen.
+type Mapper struct {
+	mu      sync.Mutex
+	current *Room
+	pending *Room
+	moves   []string                     // directions sent and not yet seen through
+	moved   bool                         // whether a room was reached since the last prompt
+	links   map[string]map[string]string // room key to direction to room key
+	rooms   map[string]*Room             // the last observation of each room key
+}
+
+// New creates a Mapper that has not seen any rooms yet.
+func New() *Mapper {
+	return &Mapper{
+		links: make(map[string]map[string]string),
+		rooms: make(map[string]*Room),
+	}
+}
+
+// key identifies a room by its name and description.
+func key(room *Room) string {
+	parts := []string{room.Name}
+	for _, line := range room.Description {
+		parts = append(parts, strings.TrimSpace(line))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// Sent notes a command sent to the world, so that a move can link the room it
+// leaves to the room it reaches.
+func (m *Mapper) Sent(cmd string) {
+	dir, ok := directions[strings.ToLower(strings.TrimSpace(cmd))]
+	if !ok {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.moves = append(m.moves, dir)
+}
+
+// Observe feeds a line of output into the Mapper. It returns the room when
+// the line completes a room observation and nil otherwise.
+func (m *Mapper) Observe(line wotmud.Line) *Room {
+	text := line.Text()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if line.PromptInfo != nil && strings.TrimSpace(text) == "" {
+		// A prompt ends the output of a command, so a move that has not
+		// reached a room by then failed.
+		if !m.moved && len(m.moves) > 0 {
+			m.moves = m.moves[1:]
+		}
+		m.moved = false
+	}
+
+	if len(m.moves) > 0 && darkRegex.MatchString(strings.TrimSpace(wotmud.StripANSI(text))) {
+		// The player moved, but there is no telling where to.
+		m.moves = m.moves[1:]
+		m.current = nil
+		m.moved = true
+		return nil
+	}
+
+	if matches := titleRegex.FindStringSubmatch(text); matches != nil {
+		m.pending = &Room{Name: matches[1]}
+		return nil
+	}
+
+	if m.pending == nil {
+		return nil
+	}
+
+	if matches := exitsRegex.FindStringSubmatch(text); matches != nil {
+		room := m.pending
+		room.Exits = strings.Fields(matches[1])
+		m.pending = nil
+		m.enter(room)
+		return room
+	}
+
+	if line.PromptInfo != nil {
+		// A prompt before the exits line means that wasn't a room after all.
+		m.pending = nil
+		return nil
+	}
+
+	m.pending.Description = append(m.pending.Description, text)
+	return nil
+}
+
+// Current returns the room the player was last seen in, or nil if unknown.
+func (m *Mapper) Current() *Room {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.current
+}
+
+// enter makes room the current one, linking it to the room left when a move
+// was sent.
+func (m *Mapper) enter(room *Room) {
+	previous := m.current
+	m.current = room
+	m.moved = true
+	to := key(room)
+	m.rooms[to] = room
+	if len(m.moves) == 0 {
+		return
+	}
+	dir := m.moves[0]
+	m.moves = m.moves[1:]
+	if previous == nil {
+		return
+	}
+	from := key(previous)
+	if from == to {
+		return
+	}
+	m.link(from, dir, to)
+	for _, exit := range room.Exits {
+		if strings.EqualFold(exit, opposites[dir]) {
+			m.link(to, opposites[dir], from)
+		}
+	}
+}
+
+func (m *Mapper) link(from, dir, to string) {
+	if m.links[from] == nil {
+		m.links[from] = make(map[string]string)
+	}
+	m.links[from][dir] = to
+}
+
+// Route returns the directions leading from the current room to the nearest
+// room for which safe is true, over the moves seen so far. It is empty when
+// the current room is safe and nil when no route is known.
+func (m *Mapper) Route(safe func(*Room) bool) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.current == nil {
+		return nil
+	}
+	start := key(m.current)
+	if safe(m.current) {
+		return []string{}
+	}
+
+	// A breadth first search finds the route with the fewest moves.
+	type step struct {
+		from, dir string
+	}
+	came := map[string]step{start: {}}
+	queue := []string{start}
+	for len(queue) > 0 {
+		at := queue[0]
+		queue = queue[1:]
+		for _, dir := range []string{"n", "e", "s", "w", "u", "d"} {
+			next, ok := m.links[at][dir]
+			if !ok {
+				continue
+			}
+			if _, seen := came[next]; seen {
+				continue
+			}
+			came[next] = step{from: at, dir: dir}
+			if safe(m.rooms[next]) {
+				var route []string
+				for k := next; k != start; k = came[k].from {
+					route = append([]string{came[k].dir}, route...)
+				}
+				return route
+			}
+			queue = append(queue, next)
+		}
+	}
+	return nil
+}
@@ -1,9 +1,128 @@
 package mapper_test
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/mapper"
 )
 
+func observeAll(m *mapper.Mapper, raw ...string) *mapper.Room {
+	var room *mapper.Room
+	for _, r := range raw {
+		if observed := m.Observe(wotmud.NewLine(r)); observed != nil {
+			room = observed
+		}
+	}
+	return room
+}
+
 func TestRoomEvent(t *testing.T) {
-	t.Skip("Receiving lines with an observed at the end should result in a room observation event.")
+	m := mapper.New()
+	room := observeAll(m,
+		"\x1b[36mThe White Crescent\x1b[0m",
+		"Two wall-sized stone fireplaces with brick surrounds and beamed shelves",
+		"face each another here in the main room of the White Crescent Inn. The",
+		"[ obvious exits: S W U ]",
+		"West: \x1b[33mAn old woman weaves a pattern on her loom.",
+	)
+
+	if room == nil {
+		t.Fatal("Expected a room observation")
+	}
+	if room.Name != "The White Crescent" {
+		t.Errorf("Unexpected room name '%s'", room.Name)
+	}
+	if len(room.Description) != 2 {
+		t.Errorf("Expected 2 description lines but found %d", len(room.Description))
+	}
+	if len(room.Exits) != 3 || room.Exits[0] != "S" || room.Exits[2] != "U" {
+		t.Errorf("Unexpected exits %v", room.Exits)
+	}
+	if m.Current() != room {
+		t.Error("Observed room should be current")
+	}
+}
+
+func TestRoomTitleAfterPrompt(t *testing.T) {
+	m := mapper.New()
+	room := observeAll(m,
+		"* HP:Healthy MV:Full > \x1b[36mA Wide Paved Street\x1b[0m",
+		"Paved streets, characteristic of the inner city, go off in all directions here",
+		"[ obvious exits: N E S W ]",
+	)
+
+	if room == nil || room.Name != "A Wide Paved Street" {
+		t.Fatalf("Expected 'A Wide Paved Street' but found %v", room)
+	}
+}
+
+func TestNoRoomWithoutExits(t *testing.T) {
+	m := mapper.New()
+	room := observeAll(m,
+		"\x1b[36mReception of the White Crescent\x1b[0m",
+		"Here upstairs, a corridor leads to the bedrooms, which people rent for the",
+		"* HP:Healthy MV:Full > ",
+		"[ obvious exits: W D ]",
+	)
+
+	if room != nil {
+		t.Errorf("Expected no room observation but found %v", room)
+	}
+	if m.Current() != nil {
+		t.Error("Expected no current room")
+	}
+}
+
+func room(name, exits string) []string {
+	return []string{"\x1b[36m" + name + "\x1b[0m", "A room called " + name + ".", "[ obvious exits: " + exits + " ]", "* HP:Healthy MV:Full > "}
+}
+
+func TestRoute(t *testing.T) {
+	m := mapper.New()
+	observeAll(m, room("The Inn", "E")...)
+	m.Sent("east")
+	observeAll(m, room("The Square", "E S W")...)
+	m.Sent("s")
+	observeAll(m, "Alas, you cannot go that way...", "* HP:Healthy MV:Full > ")
+	m.Sent("e")
+	observeAll(m, room("The Road", "W")...)
+
+	safe := func(r *mapper.Room) bool { return r.Name == "The Inn" }
+	if route := m.Route(safe); strings.Join(route, " ") != "w w" {
+		t.Errorf("Expected the route w w but found %v", route)
+	}
+	if route := m.Route(func(r *mapper.Room) bool { return r.Name == "The Road" }); route == nil || len(route) != 0 {
+		t.Errorf("Expected an empty route in a safe room but found %v", route)
+	}
+	if route := m.Route(func(r *mapper.Room) bool { return r.Name == "The Docks" }); route != nil {
+		t.Errorf("Expected no route to an unknown room but found %v", route)
+	}
+}
+
+func TestFailedMove(t *testing.T) {
+	m := mapper.New()
+	observeAll(m, room("The Stable", "N W")...)
+	m.Sent("n")
+	observeAll(m, "You can't ride in there.", "* HP:Healthy MV:Full > ")
+	m.Sent("w")
+	observeAll(m, room("The Yard", "E")...)
+
+	if route := m.Route(func(r *mapper.Room) bool { return r.Name == "The Stable" }); strings.Join(route, " ") != "e" {
+		t.Errorf("Expected the route e but found %v", route)
+	}
+}
+
+func TestMoveIntoDarkness(t *testing.T) {
+	m := mapper.New()
+	observeAll(m, room("The Stable", "N W")...)
+	m.Sent("n")
+	m.Sent("n")
+	observeAll(m, "It is pitch black...", "* HP:Healthy MV:Full > ")
+	observeAll(m, room("The Loft", "S")...)
+
+	if route := m.Route(func(r *mapper.Room) bool { return r.Name == "The Stable" }); route != nil {
+		t.Errorf("Expected no route through a dark room but found %v", route)
+	}
 }
@@ -0,0 +1,234 @@
+// Package mount keeps track of the player's mount and followers: whether the
+// player is riding, which mount is led or following, and when the mount is
+// left behind.
+package mount
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/mapper"
+)
+
+// A Kind of Event.
+type Kind int
+
+// Kinds of events.
+const (
+	// Mounted - The player started riding
+	Mounted Kind = iota
+	// Dismounted - The player stopped riding, by choice or not
+	Dismounted
+	// LeftBehind - The player moved to another room without the mount
+	LeftBehind
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Mounted:
+		return "mounted"
+	case Dismounted:
+		return "dismounted"
+	case LeftBehind:
+		return "left behind"
+	}
+	return "unknown"
+}
+
+// An Event is a change in the player's relationship with the mount, which is
+// named as the game names it, such as "A warhorse", or "" when not known.
+type Event struct {
+	Kind  Kind
+	Mount string
+}
+
+var (
+	startFollowing = regexp.MustCompile(`^(.+) starts following you\.$`)
+	stopFollowing  = regexp.MustCompile(`^(.+) stops following you\.$`)
+	startRiding    = regexp.MustCompile(`^You start riding (?:him|her|it)\.$`)
+	stopRiding     = regexp.MustCompile(`^You stop riding (?:him|her|it)\.$`)
+	startLeading   = regexp.MustCompile(`^You start leading (?:him|her|it)\.$`)
+	stopLeading    = regexp.MustCompile(`^You stop leading (?:him|her|it)\.$`)
+	arrived        = regexp.MustCompile(`^(.+) has arrived(?: from .+)?\.$`)
+	stabled        = regexp.MustCompile(`^.+ gives you a stable ticket\.$`)
+)
+
+// A Tracker follows the player's mount. It is safe for concurrent use.
+type Tracker struct {
+	mu        sync.Mutex
+	riding    bool
+	known     bool // a prompt has shown whether the player is riding
+	leading   bool
+	mount     string
+	followers []string
+	stopped   string // the follower that last stopped following
+	leadStart bool   // leading started; the mount will start following
+	withUs    bool   // the mount is in the player's room
+
+	room     *mapper.Room
+	awaiting bool // moved with the mount following, which has not arrived yet
+	behind   bool // moved with the mount not following
+}
+
+// New - Create a Tracker for a player without a mount
+func New() *Tracker {
+	return &Tracker{}
+}
+
+// Observe - Feed a line of output into the Tracker, returning the events it
+// caused
+func (t *Tracker) Observe(line wotmud.Line) []Event {
+	text := strings.TrimSpace(wotmud.StripANSI(line.Text()))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var events []Event
+	if info := line.Prompt(); info != nil {
+		if t.known && info.IsRiding != t.riding {
+			events = append(events, t.ride(info.IsRiding))
+		}
+		t.riding, t.known = info.IsRiding, true
+	}
+
+	switch {
+	case startFollowing.MatchString(text):
+		name := startFollowing.FindStringSubmatch(text)[1]
+		t.remove(name)
+		t.followers = append(t.followers, name)
+		if t.leadStart {
+			t.mount, t.leadStart = name, false
+		}
+		if strings.EqualFold(name, t.mount) {
+			t.withUs = true
+		}
+	case stopFollowing.MatchString(text):
+		name := stopFollowing.FindStringSubmatch(text)[1]
+		t.remove(name)
+		t.stopped = name
+		if strings.EqualFold(name, t.mount) {
+			t.leading = false
+		}
+	case startRiding.MatchString(text):
+		if t.stopped != "" {
+			t.mount = t.stopped
+		}
+		t.withUs = true
+		if !t.riding {
+			events = append(events, t.ride(true))
+		}
+	case stopRiding.MatchString(text):
+		if t.riding {
+			events = append(events, t.ride(false))
+		}
+	case startLeading.MatchString(text):
+		t.leading, t.leadStart = true, true
+	case stopLeading.MatchString(text):
+		t.leading = false
+	case stabled.MatchString(text):
+		// The stable hand has taken the mount away.
+		t.remove(t.mount)
+		t.leading, t.withUs = false, false
+	case arrived.MatchString(text):
+		if t.awaiting && strings.EqualFold(arrived.FindStringSubmatch(text)[1], t.mount) {
+			t.awaiting = false
+		}
+	}
+
+	// The mount arrives, if it does, before the prompt following the room.
+	if line.Prompt() != nil && (t.behind || t.awaiting) {
+		t.remove(t.mount)
+		t.behind, t.awaiting = false, false
+		t.leading, t.withUs = false, false
+		events = append(events, Event{Kind: LeftBehind, Mount: t.mount})
+	}
+	return events
+}
+
+// ride notes the player starting or stopping riding.
+func (t *Tracker) ride(riding bool) Event {
+	t.riding = riding
+	if riding {
+		t.leading = false
+		return Event{Kind: Mounted, Mount: t.mount}
+	}
+	return Event{Kind: Dismounted, Mount: t.mount}
+}
+
+// remove drops a follower.
+func (t *Tracker) remove(name string) {
+	for idx, follower := range t.followers {
+		if strings.EqualFold(follower, name) {
+			t.followers = append(t.followers[:idx:idx], t.followers[idx+1:]...)
+			return
+		}
+	}
+}
+
+// Enter - Note that the player saw room. When it is a different room from
+// the last one and the player is not riding, a mount that is following must
+// arrive by the next prompt or it is taken to have been left behind, as is a
+// mount that was not following.
+func (t *Tracker) Enter(room *mapper.Room) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	previous := t.room
+	t.room = room
+	if previous == nil || sameRoom(previous, room) || t.riding || t.mount == "" {
+		return
+	}
+	if t.following(t.mount) {
+		t.awaiting = true
+	} else if t.withUs {
+		t.behind = true
+	}
+}
+
+// sameRoom reports whether a and b are the same room, as far as can be told.
+func sameRoom(a, b *mapper.Room) bool {
+	return a.Name == b.Name && strings.Join(a.Description, "\n") == strings.Join(b.Description, "\n")
+}
+
+func (t *Tracker) following(name string) bool {
+	for _, follower := range t.followers {
+		if strings.EqualFold(follower, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Riding - Whether the player is riding
+func (t *Tracker) Riding() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.riding
+}
+
+// Leading - Whether the player is leading the mount
+func (t *Tracker) Leading() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.leading
+}
+
+// Mount - The player's mount, or "" when not known
+func (t *Tracker) Mount() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.mount
+}
+
+// Followers - Those following the player, the mount included when it is led
+func (t *Tracker) Followers() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]string(nil), t.followers...)
+}
@@ -0,0 +1,112 @@
+package mount_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/mapper"
+	"github.com/huntwj/gofugue/wotmud/mount"
+)
+
+// feed runs lines through a mapper and the tracker as a session does,
+// returning the events.
+func feed(m *mapper.Mapper, tracker *mount.Tracker, lines ...string) []mount.Event {
+	var events []mount.Event
+	for _, raw := range lines {
+		line := wotmud.NewLine(raw)
+		if room := m.Observe(line); room != nil {
+			tracker.Enter(room)
+		}
+		events = append(events, tracker.Observe(line)...)
+	}
+	return events
+}
+
+func room(name string) []string {
+	return []string{"\x1b[36m" + name + "\x1b[0m", "A room called " + name + ".", "[ obvious exits: E W ]"}
+}
+
+func TestRiding(t *testing.T) {
+	t.Parallel()
+
+	m, tracker := mapper.New(), mount.New()
+	events := feed(m, tracker,
+		"* HP:Healthy MV:Strong > You give a stable ticket to a stable hand.",
+		"A warhorse starts following you.",
+		"* HP:Healthy MV:Strong > A warhorse stops following you.",
+		"You start riding him.",
+		"* R HP:Healthy MV:Strong > ",
+	)
+	if !reflect.DeepEqual(events, []mount.Event{{Kind: mount.Mounted, Mount: "A warhorse"}}) {
+		t.Errorf("Expected to mount the warhorse but found %+v", events)
+	}
+	if !tracker.Riding() || tracker.Mount() != "A warhorse" || len(tracker.Followers()) != 0 {
+		t.Errorf("Unexpected state riding %v %q %v", tracker.Riding(), tracker.Mount(), tracker.Followers())
+	}
+
+	events = feed(m, tracker, "* HP:Healthy MV:Strong > ")
+	if !reflect.DeepEqual(events, []mount.Event{{Kind: mount.Dismounted, Mount: "A warhorse"}}) {
+		t.Errorf("Expected the prompt to show a dismount but found %+v", events)
+	}
+
+	events = feed(m, tracker, "* HP:Healthy MV:Strong > You start leading him.", "A warhorse starts following you.", "* HP:Healthy MV:Strong > ")
+	if len(events) != 0 || !tracker.Leading() || tracker.Followers()[0] != "A warhorse" {
+		t.Errorf("Expected to lead the warhorse but found %+v", events)
+	}
+	events = feed(m, tracker, "* R HP:Healthy MV:Strong > ")
+	if len(events) != 1 || events[0].Kind != mount.Mounted || tracker.Leading() {
+		t.Errorf("Expected the prompt to show a mount but found %+v", events)
+	}
+}
+
+func TestLeftBehind(t *testing.T) {
+	t.Parallel()
+
+	m, tracker := mapper.New(), mount.New()
+	feed(m, tracker, room("The Stable")...)
+	feed(m, tracker,
+		"* HP:Healthy MV:Full > You start leading him.",
+		"A warhorse starts following you.",
+		"* HP:Healthy MV:Full > ",
+	)
+
+	lines := append(room("The Woman of Tanchico"), "A warhorse has arrived from the west.", "", "* HP:Healthy MV:Full > ")
+	if events := feed(m, tracker, lines...); len(events) != 0 {
+		t.Errorf("Expected the warhorse to follow but found %+v", events)
+	}
+	lines = append(room("The Woman of Tanchico"), "", "* HP:Healthy MV:Full > ")
+	if events := feed(m, tracker, lines...); len(events) != 0 {
+		t.Errorf("Expected looking not to be a move but found %+v", events)
+	}
+
+	lines = append(room("Reception"), "", "* HP:Healthy MV:Full > ")
+	events := feed(m, tracker, lines...)
+	if !reflect.DeepEqual(events, []mount.Event{{Kind: mount.LeftBehind, Mount: "A warhorse"}}) {
+		t.Errorf("Expected the warhorse to be left behind but found %+v", events)
+	}
+	if len(tracker.Followers()) != 0 || tracker.Leading() {
+		t.Errorf("Expected the warhorse to stop following but found %v", tracker.Followers())
+	}
+	if events := feed(m, tracker, append(room("Hallway"), "* HP:Healthy MV:Full > ")...); len(events) != 0 {
+		t.Errorf("Expected to be warned once but found %+v", events)
+	}
+}
+
+func TestWalkingAwayAfterDismount(t *testing.T) {
+	t.Parallel()
+
+	m, tracker := mapper.New(), mount.New()
+	feed(m, tracker, room("The Road")...)
+	feed(m, tracker,
+		"* HP:Healthy MV:Full > A warhorse stops following you.",
+		"You start riding him.",
+		"* R HP:Healthy MV:Full > You can't ride in there.",
+		"* R HP:Healthy MV:Full > You stop riding him.",
+		"* HP:Healthy MV:Full > ",
+	)
+	events := feed(m, tracker, append(room("The Inn"), "* HP:Healthy MV:Full > ")...)
+	if !reflect.DeepEqual(events, []mount.Event{{Kind: mount.LeftBehind, Mount: "A warhorse"}}) {
+		t.Errorf("Expected the warhorse to be left behind but found %+v", events)
+	}
+}
@@ -0,0 +1,67 @@
+package wotmud
+
+// A Normalizer rebuilds logical lines from the raw text sent by the WoTMUD
+// server. Most lines end in "\r\n", but wrapped description paragraphs end in
+// "\n\r" instead, which leaves a stray carriage return at the start of the
+// following line when the text is naively split on newlines. The server also
+// sends NUL bytes as part of some telnet line endings. The Normalizer drops
+// all of these and splits only on '\n', so every Line it produces has a clean
+// Raw value.
+//
+// Prompts arrive without a trailing newline. Text that has not yet been
+// terminated is kept as a partial line which can be inspected with Partial
+// or handed out early with Flush.
+type Normalizer struct {
+	buf []byte
+
+	// flushed is set when a partial line was handed out by Flush. The newline
+	// that eventually terminates it on the wire must not produce an extra
+	// empty line.
+	flushed bool
+}
+
+// Write feeds raw server text into the Normalizer and returns all of the
+// logical lines completed by it. Incomplete text is kept until a later Write
+// or Flush.
+func (n *Normalizer) Write(p []byte) []Line {
+	var lines []Line
+	for _, b := range p {
+		switch b {
+		case '\r', 0:
+			continue
+		case '\n':
+			if n.flushed && len(n.buf) == 0 {
+				n.flushed = false
+				continue
+			}
+			lines = append(lines, NewLine(string(n.buf)))
+			n.buf = n.buf[:0]
+		default:
+			n.buf = append(n.buf, b)
+		}
+		n.flushed = false
+	}
+
+	return lines
+}
+
+// Partial returns the text received since the last complete line without
+// consuming it. The boolean is false when there is no such text.
+func (n *Normalizer) Partial() (Line, bool) {
+	if len(n.buf) == 0 {
+		return Line{}, false
+	}
+	return NewLine(string(n.buf)), true
+}
+
+// Flush hands out the partial line, if any, as if it had been terminated. It
+// is used when the end of a prompt is detected or the connection closes.
+func (n *Normalizer) Flush() (Line, bool) {
+	line, ok := n.Partial()
+	if ok {
+		line.Partial = true
+		n.buf = n.buf[:0]
+		n.flushed = true
+	}
+	return line, ok
+}
@@ -0,0 +1,115 @@
+package wotmud_test
+
+import (
+	"testing"
+
+	"github.com/huntwj/gofugue/wotmud"
+)
+
+func assertLines(t *testing.T, observed []wotmud.Line, expected ...string) {
+	t.Helper()
+
+	if len(observed) != len(expected) {
+		t.Errorf("Expected %d lines but received %d: %v", len(expected), len(observed), observed)
+		return
+	}
+	for idx, line := range observed {
+		if line.Raw != expected[idx] {
+			t.Errorf("Line %d mismatch. Expected %q but found %q", idx, expected[idx], line.Raw)
+		}
+	}
+}
+
+func TestNormalizeCRLF(t *testing.T) {
+	t.Parallel()
+
+	var n wotmud.Normalizer
+	lines := n.Write([]byte("You were rented for 0.00 days.\r\n\r\nA ledger lies on a small stand.\r\n"))
+
+	assertLines(t, lines, "You were rented for 0.00 days.", "", "A ledger lies on a small stand.")
+}
+
+func TestNormalizeLFCR(t *testing.T) {
+	t.Parallel()
+
+	var n wotmud.Normalizer
+	lines := n.Write([]byte("Here upstairs, a corridor leads to the bedrooms, which people rent for the\n\r" +
+		"night. The back and side of the inn are set directly into the inner wall,\n\r" +
+		"\r\n" +
+		"A ledger lies on a small stand.\r\n"))
+
+	assertLines(t, lines,
+		"Here upstairs, a corridor leads to the bedrooms, which people rent for the",
+		"night. The back and side of the inn are set directly into the inner wall,",
+		"",
+		"A ledger lies on a small stand.")
+}
+
+func TestNormalizeSplitWrites(t *testing.T) {
+	t.Parallel()
+
+	var n wotmud.Normalizer
+	var lines []wotmud.Line
+	for _, chunk := range []string{"West: A young woman walks by.\n", "\rDown: An ink-smudged", " gleeman writes in his book.\r", "\n"} {
+		lines = append(lines, n.Write([]byte(chunk))...)
+	}
+
+	assertLines(t, lines, "West: A young woman walks by.", "Down: An ink-smudged gleeman writes in his book.")
+}
+
+func TestNormalizeDropsNUL(t *testing.T) {
+	t.Parallel()
+
+	var n wotmud.Normalizer
+	lines := n.Write([]byte("\x00\r\nWelcome to the Wheel of Time!\r\n"))
+
+	assertLines(t, lines, "", "Welcome to the Wheel of Time!")
+}
+
+func TestNormalizeKeepsPrompt(t *testing.T) {
+	t.Parallel()
+
+	var n wotmud.Normalizer
+	lines := n.Write([]byte("Your rent credit covers it all.\r\n\r\n* HP:Healthy MV:Strong > "))
+	assertLines(t, lines, "Your rent credit covers it all.", "")
+
+	partial, ok := n.Partial()
+	if !ok {
+		t.Fatal("Expected a partial prompt line")
+	}
+	if partial.Prompt() == nil {
+		t.Errorf("Expected partial line %q to have a prompt", partial.Raw)
+	}
+
+	lines = n.Write([]byte("You have 364(364) hit and 150(152) movement points.\r\n"))
+	assertLines(t, lines, "* HP:Healthy MV:Strong > You have 364(364) hit and 150(152) movement points.")
+	if lines[0].Prompt() == nil {
+		t.Fatal("Expected prompt on completed line")
+	}
+	if text := lines[0].Text(); text != "You have 364(364) hit and 150(152) movement points." {
+		t.Errorf("Unexpected text after prompt: %q", text)
+	}
+	if _, ok := n.Partial(); ok {
+		t.Error("Expected no partial line after newline")
+	}
+}
+
+func TestNormalizeFlush(t *testing.T) {
+	t.Parallel()
+
+	var n wotmud.Normalizer
+	if _, ok := n.Flush(); ok {
+		t.Error("Expected nothing to flush from an empty normalizer")
+	}
+
+	n.Write([]byte("* HP:Healthy MV:Full > "))
+	line, ok := n.Flush()
+	if !ok || line.Raw != "* HP:Healthy MV:Full > " || !line.Partial {
+		t.Fatalf("Expected flushed prompt but found %q (%t)", line.Raw, ok)
+	}
+
+	// The newline that terminates the flushed prompt must not show up as an
+	// empty line of its own, but a real blank line after it must.
+	lines := n.Write([]byte("\r\n\r\nA mirrored lantern is about to go out!\r\n"))
+	assertLines(t, lines, "", "A mirrored lantern is about to go out!")
+}
@@ -1,13 +1,10 @@
 package prompt
 
 import (
-	"fmt"
 	"regexp"
+	"strings"
 )
 
-var weakCandidate = regexp.MustCompile(">")
-var ignoreRegex = regexp.MustCompile(`(<Sent: .* >|^<|^ >|Press <Return> to continue|==>|^(\^\[33m)?\w+ (chats|narrates|says|tells you))`)
-
 type substring struct {
 	start, end int
 }
@@ -64,12 +61,6 @@ func Parse(str string) (*Info, int) {
 	matches := promptRegex.FindStringSubmatchIndex(str)
 
 	if matches == nil {
-		matches := weakCandidate.FindStringIndex(str)
-		if matches != nil && ignoreRegex.FindStringIndex(str) == nil {
-			fmt.Printf("%s failed prompt...\n", str)
-			fmt.Println("But weak candidate passed...")
-		}
-
 		return nil, 0
 	}
 
@@ -112,9 +103,29 @@ func Parse(str string) (*Info, int) {
 	return l.promptInfo, l.promptEnd
 }
 
-var allHealthPattern = `(Healthy|Scratched|Hurt|Wounded|Battered|Beaten|Critical|Incapacitated|Dead\?)`
+// Healths are the health levels shown by the prompt, from the best to the
+// worst.
+var Healths = []string{"Healthy", "Scratched", "Hurt", "Wounded", "Battered", "Beaten", "Critical", "Incapacitated", "Dead?"}
+
+// HealthRank - How many levels below Healthy health is, or -1 for a health
+// that is not known
+func HealthRank(health string) int {
+	for idx, known := range Healths {
+		if known == health {
+			return idx
+		}
+	}
+	return -1
+}
+
+var allHealthPattern = `(` + strings.Replace(strings.Join(Healths, "|"), "?", `\?`, -1) + `)`
 var allSpellPattern = `(Bursting|Full|Strong|Good|Fading|Trickling)`
-var allMovementPattern = `(Full|Fresh|Strong|Winded|Weary|Tiring|Haggard)`
+
+// Movements are the movement levels shown by the prompt, from the best to
+// the worst.
+var Movements = []string{"Full", "Fresh", "Strong", "Winded", "Weary", "Tiring", "Haggard"}
+
+var allMovementPattern = `(` + strings.Join(Movements, "|") + `)`
 var otherPlayerOrMobPattern = `([\w \-,]+)`
 var promptRegex = regexp.MustCompile(
 	`^([\*o]) (R )?HP:` + allHealthPattern +
@@ -0,0 +1,252 @@
+// Package safety watches the player's mood and wimpy settings, health and
+// attempts to flee, and runs an escape plan: fleeing when health falls too
+// low in a fight and then walking to a safe room.
+package safety
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/mapper"
+	"github.com/huntwj/gofugue/wotmud/prompt"
+)
+
+// A Kind of Event.
+type Kind int
+
+// Kinds of events.
+const (
+	// Settings - The game showed the player's mood or wimpy setting
+	Settings Kind = iota
+	// Danger - The player's health fell to the threshold in a fight
+	Danger
+	// Panicked - The game's wimpy made the player try to flee
+	Panicked
+	// FleeFailed - An attempt to flee failed
+	FleeFailed
+	// Fled - The player fled, and the room fled to has been seen
+	Fled
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Settings:
+		return "settings"
+	case Danger:
+		return "danger"
+	case Panicked:
+		return "panicked"
+	case FleeFailed:
+		return "flee failed"
+	case Fled:
+		return "fled"
+	}
+	return "unknown"
+}
+
+// An Event is a change in the player's safety, with the health the prompt
+// last showed.
+type Event struct {
+	Kind   Kind
+	Health string
+}
+
+var (
+	moodAndWimpy = regexp.MustCompile(`^Your mood is: (\w+)\. You will flee below: (\d+) Hit Points$`)
+	moodChanged  = regexp.MustCompile(`^Mood changed to: (\w+)$`)
+	wimpyReset   = regexp.MustCompile(`^(?:Wimpy reset to: |You will now flee if you go below )(\d+) hit points\.$`)
+	wimpyOff     = regexp.MustCompile(`^You won't flee from any fight now\.$`)
+	panicked     = regexp.MustCompile(`You panic and attempt to flee!$`)
+	fleeFailed   = regexp.MustCompile(`^PANIC!  You couldn't escape!$`)
+	fled         = regexp.MustCompile(`^You flee head over heels\.$`)
+)
+
+// A Monitor follows the player's mood, wimpy and health. It is safe for
+// concurrent use.
+type Monitor struct {
+	mu        sync.Mutex
+	mood      string
+	wimpy     int
+	health    string
+	threshold int  // the health rank that is dangerous in a fight, or -1
+	danger    bool // health is at the threshold in a fight
+	fleeing   bool // fled, and the room fled to is yet to be followed by a prompt
+}
+
+// New - Create a Monitor that knows nothing of the player's settings and
+// sees no health as dangerous
+func New() *Monitor {
+	return &Monitor{threshold: -1}
+}
+
+// SetThreshold - Take the health level, as the prompt shows it, at which
+// the player is in danger in a fight. Any other value turns it off.
+func (m *Monitor) SetThreshold(health string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.threshold = prompt.HealthRank(health)
+}
+
+// Observe - Feed a line of output into the Monitor, returning the events it
+// caused
+func (m *Monitor) Observe(line wotmud.Line) []Event {
+	text := strings.TrimSpace(wotmud.StripANSI(line.Text()))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var events []Event
+	if info := line.Prompt(); info != nil {
+		m.health = info.Health
+		if m.fleeing {
+			m.fleeing = false
+			events = append(events, m.event(Fled))
+		}
+		rank := prompt.HealthRank(info.Health)
+		danger := m.threshold >= 0 && info.Combat != nil && rank >= m.threshold
+		if danger && !m.danger {
+			events = append(events, m.event(Danger))
+		}
+		m.danger = danger
+	}
+
+	switch {
+	case moodAndWimpy.MatchString(text):
+		match := moodAndWimpy.FindStringSubmatch(text)
+		m.mood = match[1]
+		m.wimpy, _ = strconv.Atoi(match[2])
+		events = append(events, m.event(Settings))
+	case moodChanged.MatchString(text):
+		m.mood = moodChanged.FindStringSubmatch(text)[1]
+		events = append(events, m.event(Settings))
+	case wimpyReset.MatchString(text):
+		m.wimpy, _ = strconv.Atoi(wimpyReset.FindStringSubmatch(text)[1])
+		events = append(events, m.event(Settings))
+	case wimpyOff.MatchString(text):
+		m.wimpy = 0
+		events = append(events, m.event(Settings))
+	case panicked.MatchString(text):
+		events = append(events, m.event(Panicked))
+	case fleeFailed.MatchString(text):
+		events = append(events, m.event(FleeFailed))
+	case fled.MatchString(text):
+		// The room fled to follows; the flight is reported at the prompt.
+		m.fleeing, m.danger = true, false
+	}
+	return events
+}
+
+func (m *Monitor) event(kind Kind) Event {
+	return Event{Kind: kind, Health: m.health}
+}
+
+// Mood - The player's mood, such as "Wimpy" or "Brave", or "" when not known
+func (m *Monitor) Mood() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.mood
+}
+
+// Wimpy - The hit points below which the game makes the player flee, 0 when
+// it does not
+func (m *Monitor) Wimpy() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.wimpy
+}
+
+// Health - The player's health as the prompt last showed it
+func (m *Monitor) Health() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.health
+}
+
+// InDanger - Whether the player's health is at the threshold in a fight
+func (m *Monitor) InDanger() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.danger
+}
+
+// DefaultFlee is the command an Escape flees with unless told otherwise.
+const DefaultFlee = "flee"
+
+// DefaultAttempts is how many times an Escape tries to flee unless told
+// otherwise.
+const DefaultAttempts = 3
+
+// An Escape is an escape plan: when the player is in danger, flee up to
+// Attempts times, and once away, by the plan or by the game's wimpy, walk
+// along the mapper's route to the nearest room named in Safe. It is not safe
+// for concurrent use.
+type Escape struct {
+	Flee     string
+	Attempts int
+	Safe     []string
+
+	tries int // attempts made to flee the current danger, 0 when not fleeing
+}
+
+// Step - The commands to send for an event
+func (e *Escape) Step(ev Event, m *mapper.Mapper) []string {
+	switch ev.Kind {
+	case Danger:
+		e.tries = 1
+		return []string{e.flee()}
+	case FleeFailed:
+		if e.tries == 0 {
+			return nil
+		}
+		if e.tries >= e.attempts() {
+			e.tries = 0
+			return nil
+		}
+		e.tries++
+		return []string{e.flee()}
+	case Fled:
+		e.tries = 0
+		if len(e.Safe) == 0 {
+			return nil
+		}
+		return m.Route(e.safe)
+	}
+	return nil
+}
+
+// Fleeing - Whether the plan is trying to flee
+func (e *Escape) Fleeing() bool {
+	return e.tries > 0
+}
+
+func (e *Escape) flee() string {
+	if e.Flee == "" {
+		return DefaultFlee
+	}
+	return e.Flee
+}
+
+func (e *Escape) attempts() int {
+	if e.Attempts <= 0 {
+		return DefaultAttempts
+	}
+	return e.Attempts
+}
+
+// safe reports whether room is one of the safe rooms.
+func (e *Escape) safe(room *mapper.Room) bool {
+	for _, name := range e.Safe {
+		if strings.EqualFold(strings.TrimSpace(name), room.Name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+package safety_test
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/huntwj/gofugue/client/clog"
+	"github.com/huntwj/gofugue/wotmud/mapper"
+	"github.com/huntwj/gofugue/wotmud/safety"
+)
+
+// simulation replays a log through a mapper, a monitor and an escape plan as
+// a session does, keeping the events seen and the commands the plan sent.
+type simulation struct {
+	mapper   *mapper.Mapper
+	monitor  *safety.Monitor
+	escape   *safety.Escape
+	events   []safety.Event
+	commands []string
+	home     bool // take the first room seen to be the safe room
+}
+
+func newSimulation(threshold string, safe ...string) *simulation {
+	sim := &simulation{mapper: mapper.New(), monitor: safety.New(), escape: &safety.Escape{Safe: safe}}
+	sim.monitor.SetThreshold(threshold)
+	return sim
+}
+
+func (sim *simulation) replay(t *testing.T, r io.Reader) {
+	reader := clog.NewReader(r)
+	for {
+		entry, err := reader.Read()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Fatalf("Could not read the log: %v", err)
+		}
+		if entry.Sent {
+			sim.mapper.Sent(entry.Command)
+			continue
+		}
+		if room := sim.mapper.Observe(entry.Line); room != nil && sim.home && len(sim.escape.Safe) == 0 {
+			sim.escape.Safe = []string{room.Name}
+		}
+		for _, ev := range sim.monitor.Observe(entry.Line) {
+			sim.events = append(sim.events, ev)
+			sim.commands = append(sim.commands, sim.escape.Step(ev, sim.mapper)...)
+		}
+	}
+}
+
+func (sim *simulation) kinds() []safety.Kind {
+	var kinds []safety.Kind
+	for _, ev := range sim.events {
+		kinds = append(kinds, ev.Kind)
+	}
+	return kinds
+}
+
+// room writes a room as it appears in a log.
+func room(name, exits string) string {
+	return "^[[36m" + name + "^[[0m\r\nA room called " + name + ".\r\n[ obvious exits: " + exits + " ]\r\n\r\n"
+}
+
+func TestSettings(t *testing.T) {
+	t.Parallel()
+
+	sim := newSimulation("")
+	sim.replay(t, strings.NewReader(
+		"* HP:Healthy MV:Fresh > <Sent: stat >\n"+
+			"Your mood is: Brave. You will flee below: 364 Hit Points\r\n"+
+			"* HP:Healthy MV:Fresh > <Sent: cw 50 >\n"+
+			"You will now flee if you go below 50 hit points.\r\n"+
+			"* HP:Healthy MV:Fresh > <Sent: cmw >\n"+
+			"Mood changed to: Wimpy\r\n"+
+			"Wimpy reset to: 182 hit points.\r\n"+
+			"* HP:Healthy MV:Fresh > \r\n"))
+
+	if sim.monitor.Mood() != "Wimpy" || sim.monitor.Wimpy() != 182 {
+		t.Errorf("Expected Wimpy below 182 but found %s below %d", sim.monitor.Mood(), sim.monitor.Wimpy())
+	}
+	if len(sim.events) != 4 || len(sim.commands) != 0 {
+		t.Errorf("Expected four settings events and no commands but found %v and %v", sim.events, sim.commands)
+	}
+
+	sim.replay(t, strings.NewReader("* HP:Healthy MV:Fresh > <Sent: cw >\nYou won't flee from any fight now.\r\n"))
+	if sim.monitor.Wimpy() != 0 {
+		t.Errorf("Expected wimpy to be off but found %d", sim.monitor.Wimpy())
+	}
+}
+
+func TestEscape(t *testing.T) {
+	t.Parallel()
+
+	sim := newSimulation("Wounded", "The Inn")
+	sim.replay(t, strings.NewReader(
+		"* HP:Healthy MV:Fresh > <Sent: l >\n"+room("The Inn", "E")+
+			"* HP:Healthy MV:Fresh > <Sent: e >\n"+room("The Square", "E W")+
+			"* HP:Healthy MV:Fresh > <Sent: e >\n"+room("The Road", "E W")+
+			"* HP:Healthy MV:Fresh > <Sent: kill rat >\n"+
+			"A rat bites your body hard.\r\n"+
+			"* HP:Hurt MV:Fresh - a rat: Healthy > \r\n"+
+			"A rat bites your body very hard.\r\n"+
+			"* HP:Wounded MV:Fresh - a rat: Healthy > <Sent: flee >\n"+
+			"PANIC!  You couldn't escape!\r\n"+
+			"* HP:Wounded MV:Fresh - a rat: Healthy > <Sent: flee >\n"+
+			"You flee head over heels.\r\n"+room("The Square", "E W")+
+			"* HP:Wounded MV:Fresh > \r\n"))
+
+	kinds := []safety.Kind{safety.Danger, safety.FleeFailed, safety.Fled}
+	if !reflect.DeepEqual(sim.kinds(), kinds) {
+		t.Errorf("Expected events %v but found %v", kinds, sim.kinds())
+	}
+	commands := []string{"flee", "flee", "w"}
+	if !reflect.DeepEqual(sim.commands, commands) {
+		t.Errorf("Expected commands %v but found %v", commands, sim.commands)
+	}
+	if sim.escape.Fleeing() {
+		t.Errorf("Expected the plan to be done")
+	}
+}
+
+func TestEscapeGivesUp(t *testing.T) {
+	t.Parallel()
+
+	sim := newSimulation("Battered")
+	sim.escape.Attempts = 2
+	sim.replay(t, strings.NewReader(
+		"* HP:Wounded MV:Fresh - a rat: Healthy > \r\n"+
+			"* HP:Battered MV:Fresh - a rat: Healthy > <Sent: flee >\n"+
+			"PANIC!  You couldn't escape!\r\n"+
+			"* HP:Battered MV:Fresh - a rat: Healthy > <Sent: flee >\n"+
+			"PANIC!  You couldn't escape!\r\n"+
+			"* HP:Beaten MV:Fresh - a rat: Healthy > \r\n"))
+
+	if commands := []string{"flee", "flee"}; !reflect.DeepEqual(sim.commands, commands) {
+		t.Errorf("Expected commands %v but found %v", commands, sim.commands)
+	}
+	if sim.escape.Fleeing() || !sim.monitor.InDanger() {
+		t.Errorf("Expected the plan to have given up while still in danger")
+	}
+}
+
+func TestWimpyFlee(t *testing.T) {
+	t.Parallel()
+
+	// The game's own wimpy flees; the plan only walks to safety.
+	sim := newSimulation("", "The Inn")
+	sim.replay(t, strings.NewReader(
+		"* HP:Healthy MV:Fresh > <Sent: l >\n"+room("The Inn", "N")+
+			"* HP:Healthy MV:Fresh > <Sent: n >\n"+room("The Lane", "N S")+
+			"* HP:Healthy MV:Fresh > <Sent: n >\n"+room("The Field", "S")+
+			"* HP:Scratched MV:Full - a huge mountain lion: Hurt > \\x00      \\x00 You panic and attempt to flee!\r\n"+
+			"You flee head over heels.\r\n"+room("The Lane", "N S")+
+			"* HP:Scratched MV:Full > \r\n"))
+
+	kinds := []safety.Kind{safety.Panicked, safety.Fled}
+	if !reflect.DeepEqual(sim.kinds(), kinds) {
+		t.Errorf("Expected events %v but found %v", kinds, sim.kinds())
+	}
+	if commands := []string{"s"}; !reflect.DeepEqual(sim.commands, commands) {
+		t.Errorf("Expected commands %v but found %v", commands, sim.commands)
+	}
+}
+
+func TestOnLogFiles(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("Skipping log file tests when short.")
+	}
+	logDir := "../testdata"
+	dir, err := ioutil.ReadDir(logDir)
+	if err != nil {
+		t.Fatalf("Error opening directory: %v", err)
+	}
+
+	counts := make(map[safety.Kind]int)
+	routes := 0
+	for _, fileInfo := range dir {
+		if !strings.HasSuffix(fileInfo.Name(), ".gz") {
+			continue
+		}
+		f, err := os.Open(filepath.Join(logDir, fileInfo.Name()))
+		if err != nil {
+			t.Fatalf("Could not open log: %v", err)
+		}
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("Could not open gzip stream: %v", err)
+		}
+
+		// The room each log starts in stands in for a safe room.
+		sim := newSimulation("Battered")
+		sim.home = true
+		sim.replay(t, gr)
+		for _, ev := range sim.events {
+			counts[ev.Kind]++
+		}
+		for _, cmd := range sim.commands {
+			switch cmd {
+			case safety.DefaultFlee:
+			case "n", "e", "s", "w", "u", "d":
+				routes++
+			default:
+				t.Errorf("Unexpected command %q in %s", cmd, fileInfo.Name())
+			}
+		}
+		gr.Close()
+		f.Close()
+	}
+
+	for _, kind := range []safety.Kind{safety.Settings, safety.Danger, safety.Panicked, safety.FleeFailed, safety.Fled} {
+		if counts[kind] == 0 {
+			t.Errorf("Expected the logs to cause some %v events", kind)
+		}
+	}
+	if routes == 0 {
+		t.Errorf("Expected some flights to be followed by a route to safety")
+	}
+}
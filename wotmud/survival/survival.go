@@ -0,0 +1,153 @@
+// Package survival keeps track of the player's hunger and thirst and of the
+// food and water at hand, and decides when it is a good time to eat or drink.
+package survival
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/inventory"
+	"github.com/huntwj/gofugue/wotmud/prompt"
+)
+
+var (
+	hungry    = regexp.MustCompile(`^You are (?:hungry|starving)\.$`)
+	thirsty   = regexp.MustCompile(`^You are (?:thirsty|parched)\.$`)
+	fed       = regexp.MustCompile(`^(?:You eat .+\.|You are full\.|Your stomach can't contain anymore!)$`)
+	quenched  = regexp.MustCompile(`^(?:You don't feel thirsty any more\.|You're not thirsty\.|You do not feel thirsty\.)$`)
+	foods     = regexp.MustCompile(`(?i)\b(?:meat|bread|honeycakes?|cakes?|apples?|fruit|jerky|cheese|rations?|pies?|biscuits?|fish|eggs?|sausages?|loaf)$`)
+	drinkable = regexp.MustCompile(`(?i)\b(?:flask|waterskin|skin|canteen|bottle|jug|cup|mug)$`)
+)
+
+// A Tracker follows the player's hunger and thirst. It is safe for concurrent
+// use.
+type Tracker struct {
+	mu      sync.Mutex
+	hungry  bool
+	thirsty bool
+	eating  bool // food was asked for since the player last felt hungry
+	drink   bool // water was asked for since the player last felt thirsty
+}
+
+// New - Create a Tracker for a player who is neither hungry nor thirsty
+func New() *Tracker {
+	return &Tracker{}
+}
+
+// Observe - Feed a line of output into the Tracker
+func (t *Tracker) Observe(line wotmud.Line) {
+	text := strings.TrimSpace(wotmud.StripANSI(line.Text()))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch {
+	case hungry.MatchString(text):
+		// The game repeats this every tick, which allows another try.
+		t.hungry, t.eating = true, false
+	case thirsty.MatchString(text):
+		t.thirsty, t.drink = true, false
+	case fed.MatchString(text):
+		t.hungry = false
+	case quenched.MatchString(text):
+		t.thirsty = false
+	}
+}
+
+// Hungry - Whether the player is hungry
+func (t *Tracker) Hungry() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.hungry
+}
+
+// Thirsty - Whether the player is thirsty
+func (t *Tracker) Thirsty() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.thirsty
+}
+
+// Due - Whether it is time to eat and to drink, judging from the prompt the
+// game just sent: the player must need to and not be fighting, and each is
+// only due once for every time the game says the player is hungry or
+// thirsty. Callers are expected to eat or drink when told to.
+func (t *Tracker) Due(info *prompt.Info) (eat, drink bool) {
+	if info == nil || info.Combat != nil {
+		return false, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	eat, drink = t.hungry && !t.eating, t.thirsty && !t.drink
+	t.eating = t.eating || eat
+	t.drink = t.drink || drink
+	return eat, drink
+}
+
+// A Supply is food or drink at hand, with the container it is kept in, if
+// any.
+type Supply struct {
+	Name string
+	In   string
+}
+
+// Food - The food the player carries, either loose or in the containers last
+// looked in
+func Food(inv *inventory.Inventory) []Supply {
+	var food []Supply
+	for _, name := range held(inv) {
+		if foods.MatchString(name) {
+			food = append(food, Supply{Name: name})
+		}
+		c, ok := inv.Container(name)
+		if !ok {
+			continue
+		}
+		for _, item := range c.Contents {
+			if foods.MatchString(item.Name) {
+				food = append(food, Supply{Name: item.Name, In: name})
+			}
+		}
+	}
+	return food
+}
+
+// Water - The drink containers the player carries or uses that were not
+// empty when last looked in
+func Water(inv *inventory.Inventory) []Supply {
+	var water []Supply
+	for _, name := range held(inv) {
+		if drinkable.MatchString(name) && inv.Liquid(Keyword(name)) != "empty" {
+			water = append(water, Supply{Name: name})
+		}
+	}
+	return water
+}
+
+// held lists the items the player carries and uses.
+func held(inv *inventory.Inventory) []string {
+	var names []string
+	for _, item := range inv.Carried() {
+		names = append(names, item.Name)
+	}
+	for _, worn := range inv.Used() {
+		names = append(names, worn.Item)
+	}
+	return names
+}
+
+// Keyword - The word naming an item in commands, its last word, such as
+// "meat" for "a large slab of meat"
+func Keyword(name string) string {
+	words := strings.Fields(strings.ToLower(name))
+	if len(words) == 0 {
+		return ""
+	}
+	return words[len(words)-1]
+}
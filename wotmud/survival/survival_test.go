@@ -0,0 +1,97 @@
+package survival_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/inventory"
+	"github.com/huntwj/gofugue/wotmud/survival"
+)
+
+func observe(tracker *survival.Tracker, inv *inventory.Inventory, lines ...string) {
+	for _, raw := range lines {
+		line := wotmud.NewLine(raw)
+		if inv != nil {
+			inv.Observe(line)
+		}
+		tracker.Observe(line)
+	}
+}
+
+func TestHungerAndThirst(t *testing.T) {
+	t.Parallel()
+
+	tracker := survival.New()
+	observe(tracker, nil, "You are hungry.", "You are thirsty.")
+	if !tracker.Hungry() || !tracker.Thirsty() {
+		t.Fatalf("Expected hungry and thirsty, got %v and %v", tracker.Hungry(), tracker.Thirsty())
+	}
+
+	observe(tracker, nil, "You eat the meat.", "You drink the water.")
+	if tracker.Hungry() || !tracker.Thirsty() {
+		t.Errorf("Expected only thirsty after eating and a drink, got %v and %v", tracker.Hungry(), tracker.Thirsty())
+	}
+	observe(tracker, nil, "You don't feel thirsty any more.")
+	if tracker.Thirsty() {
+		t.Errorf("Expected thirst to be gone")
+	}
+}
+
+func TestDue(t *testing.T) {
+	t.Parallel()
+
+	tracker := survival.New()
+	fighting := wotmud.NewLine("* HP:Healthy MV:Fresh - Freddie: Wounded - a rat: Hurt > ").PromptInfo
+	calm := wotmud.NewLine("* HP:Healthy MV:Fresh > ").PromptInfo
+
+	if eat, drink := tracker.Due(calm); eat || drink {
+		t.Errorf("Expected nothing due before feeling hungry")
+	}
+	observe(tracker, nil, "You are hungry.")
+	if eat, _ := tracker.Due(fighting); eat {
+		t.Errorf("Expected no eating in combat")
+	}
+	if eat, drink := tracker.Due(calm); !eat || drink {
+		t.Errorf("Expected eating due, got %v and %v", eat, drink)
+	}
+	if eat, _ := tracker.Due(calm); eat {
+		t.Errorf("Expected eating to be due once until the game says hungry again")
+	}
+	observe(tracker, nil, "You are hungry.")
+	if eat, _ := tracker.Due(calm); !eat {
+		t.Errorf("Expected eating due again")
+	}
+}
+
+func TestSupplies(t *testing.T) {
+	t.Parallel()
+
+	inv := inventory.New()
+	observe(survival.New(), inv,
+		"You are carrying:",
+		"a soft honeycake",
+		"a backpack",
+		"* HP:Healthy MV:Fresh > You are using:",
+		"<worn on belt>      a leather water flask",
+		"<worn on back>      a skin",
+		"* HP:Healthy MV:Fresh > a backpack (carried) :",
+		"[2] a large slab of meat",
+		"a torch",
+		"",
+	)
+	inv.Sent("look in skin")
+	observe(survival.New(), inv, "It's empty.")
+
+	food := []survival.Supply{
+		{Name: "a soft honeycake"},
+		{Name: "a large slab of meat", In: "a backpack"},
+	}
+	if got := survival.Food(inv); !reflect.DeepEqual(got, food) {
+		t.Errorf("Expected food %v, got %v", food, got)
+	}
+	water := []survival.Supply{{Name: "a leather water flask"}}
+	if got := survival.Water(inv); !reflect.DeepEqual(got, water) {
+		t.Errorf("Expected water %v, got %v", water, got)
+	}
+}
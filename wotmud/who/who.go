@@ -0,0 +1,94 @@
+// Package who reads the list of players shown by the who command.
+package who
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/huntwj/gofugue/wotmud"
+)
+
+// A Player is an entry of the who list. Title is the entry as shown without
+// its clan tags and flags, such as "Spruce the Hundredman". Clans holds the
+// tags in brackets or braces, such as "Child of Light", and Flags those in
+// parentheses, such as "Idle".
+type Player struct {
+	Name  string
+	Title string
+	Clans []string
+	Flags []string
+}
+
+var (
+	header    = regexp.MustCompile(`^\s*Players\s*$`)
+	displayed = regexp.MustCompile(`^\s*\d+ players? displayed\.\s*$`)
+	// Entries are indented by three spaces, which tells them apart from
+	// messages that arrive while the list is shown.
+	entry  = regexp.MustCompile(`^   (\S.*)$`)
+	clan   = regexp.MustCompile(`\[([^\]]+)\]|\{([^}]+)\}`)
+	flag   = regexp.MustCompile(`\(([^)]+)\)`)
+	spaces = regexp.MustCompile(`\s+`)
+)
+
+// honorifics are words shown before some players' names.
+var honorifics = map[string]bool{
+	"Lord": true, "Lady": true, "Sir": true, "Dame": true, "Master": true, "Mistress": true,
+}
+
+// Parse - Read an entry of the who list, such as "Heath Greenhand (Idle)"
+func Parse(text string) Player {
+	var p Player
+	for _, match := range clan.FindAllStringSubmatch(text, -1) {
+		p.Clans = append(p.Clans, match[1]+match[2])
+	}
+	text = clan.ReplaceAllString(text, "")
+	for _, match := range flag.FindAllStringSubmatch(text, -1) {
+		p.Flags = append(p.Flags, match[1])
+	}
+	text = flag.ReplaceAllString(text, "")
+	p.Title = strings.TrimSpace(spaces.ReplaceAllString(text, " "))
+
+	words := strings.Fields(p.Title)
+	for len(words) > 1 && honorifics[words[0]] {
+		words = words[1:]
+	}
+	if len(words) > 0 {
+		p.Name = strings.TrimRight(words[0], ",")
+	}
+	return p
+}
+
+// A Parser follows the output of the who command. It is safe for concurrent
+// use.
+type Parser struct {
+	mu      sync.Mutex
+	reading bool
+	players []Player
+}
+
+// New - Create a Parser
+func New() *Parser {
+	return &Parser{}
+}
+
+// Observe - Feed a line of output into the Parser. When the line ends a who
+// list it returns the players listed and true.
+func (p *Parser) Observe(line wotmud.Line) ([]Player, bool) {
+	text := strings.TrimRight(wotmud.StripANSI(line.Text()), " \r")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch {
+	case header.MatchString(text):
+		p.reading, p.players = true, nil
+	case !p.reading:
+	case displayed.MatchString(text):
+		p.reading = false
+		return p.players, true
+	case entry.MatchString(text):
+		p.players = append(p.players, Parse(entry.FindStringSubmatch(text)[1]))
+	}
+	return nil, false
+}
@@ -0,0 +1,71 @@
+package who_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/huntwj/gofugue/wotmud"
+	"github.com/huntwj/gofugue/wotmud/who"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	for text, expected := range map[string]who.Player{
+		"Freddie of Two Rivers": {Name: "Freddie", Title: "Freddie of Two Rivers"},
+		"Heath Greenhand (Linkless) (Idle)": {
+			Name: "Heath", Title: "Heath Greenhand", Flags: []string{"Linkless", "Idle"},
+		},
+		"Synthia Calla Lily, the Tower Accepted  [White Tower]": {
+			Name: "Synthia", Title: "Synthia Calla Lily, the Tower Accepted", Clans: []string{"White Tower"},
+		},
+		"Erulisse Avehelm, Tower Accepted {Brown Ajah Apprentice} [White Tower]": {
+			Name: "Erulisse", Title: "Erulisse Avehelm, Tower Accepted", Clans: []string{"Brown Ajah Apprentice", "White Tower"},
+		},
+		"Lord Vilac Clayton, Heron Repo Man  [Gaidin] (Idle)": {
+			Name: "Vilac", Title: "Lord Vilac Clayton, Heron Repo Man", Clans: []string{"Gaidin"}, Flags: []string{"Idle"},
+		},
+	} {
+		if p := who.Parse(text); !reflect.DeepEqual(p, expected) {
+			t.Errorf("Expected %+v for %q but found %+v", expected, text, p)
+		}
+	}
+}
+
+func TestParser(t *testing.T) {
+	t.Parallel()
+
+	p := who.New()
+	var players []who.Player
+	done := false
+	for _, raw := range []string{
+		"* HP:Healthy MV:Full - a shivering tree: Wounded >   Players",
+		"  -------",
+		"   Kysmias of Illian",
+		"The shivering tree tries to hit you, but you deflect the blow.",
+		"   Spruce the Hundredman  [Child of Light]",
+		"* Press <Return> to continue, q to quit *>",
+		"   Etain a'Conn (Idle)",
+		"",
+		"  3 players displayed.",
+	} {
+		if players, done = p.Observe(wotmud.NewLine(raw)); done {
+			break
+		}
+	}
+	if !done {
+		t.Fatal("Expected the who list to be complete")
+	}
+
+	var names []string
+	for _, player := range players {
+		names = append(names, player.Name)
+	}
+	if expected := []string{"Kysmias", "Spruce", "Etain"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected players %v but found %v", expected, names)
+	}
+
+	if _, done := p.Observe(wotmud.NewLine("  3 players displayed.")); done {
+		t.Errorf("Expected no list without a header")
+	}
+}